@@ -1,10 +0,0 @@
-#!/bin/bash
-rm *.out
-rm Peerster
-rm client/client
-
-cd _Downloads
-rm *1M_file.txt
-rm -rf chunks
-rm *.jpg
-cd ..
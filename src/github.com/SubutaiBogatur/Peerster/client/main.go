@@ -17,6 +17,8 @@ var (
 	request  = flag.String("request", "", "Request a chunk / metafile of this hash")
 	keywords = flag.String("keywords", "", "Specify keywords to init search procedure, eg \"file,txt,jpeg\"")
 	budget   = flag.Int("budget", 0, "Specify budget for search procedure or leave it default (2)")
+	ping     = flag.Bool("ping", false, "Ping origin given with -dest, result is printed by gossiper")
+	trace    = flag.Bool("traceroute", false, "Trace route to origin given with -dest, result is printed by gossiper")
 
 	logger = log.WithField("bin", "clt")
 )
@@ -26,7 +28,9 @@ func main() {
 
 	flag.Parse()
 
-	if *dest != "" && *msg != "" {
+	if *dest != "" && (*ping || *trace) {
+		SendToProbeMessageToLocalPort(*dest, *trace, *UIPort, logger)
+	} else if *dest != "" && *msg != "" {
 		SendPrivateMessageToLocalPort(*msg, *dest, *UIPort, logger)
 	} else if *msg != "" {
 		SendRumorMessageToLocalPort(*msg, *UIPort, logger)
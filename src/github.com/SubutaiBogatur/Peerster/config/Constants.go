@@ -7,11 +7,35 @@ const (
 
 	MaxPacketSize = 16 * 1024 // in bytes
 
+	ProtocolVersion = 2 // 1 is the version without hellos
+
+	FragmentDataSize            = MaxPacketSize - 128 // in bytes, rest of the datagram is left for fragment header
+	MaxFragmentsCount           = 256                 // so, largest packet is 4MB
+	MaxPendingFragmentedPackets = 64                  // packets, which are being reassembled simultaneously
+
+	BatchablePacketSize = MaxPacketSize / 8 // in bytes, only smaller packets are put into batches
+	RumorBundleBudget   = MaxPacketSize / 2 // in bytes, rumors of one bundle, so that it fits into a datagram
+	BatchPacketOverhead = 8                 // in bytes, upper bound on how much encoding of a batch grows with one more packet
+
+	SealedPacketOverhead    = 64   // in bytes, upper bound on how much encoding of a datagram grows, when it's sealed
+	MaxPendingLinkDatagrams = 64   // datagrams waiting for the handshake with the neighbour
+	MaxLinkSessions         = 2    // sessions of the link, which are still accepted, older ones are forgotten
+	MaxSecureLinks          = 1024 // addresses, with which links are kept or being established
+
+	MaxRoutesPerOrigin = 3 // routes through different neighbours, the best is used, others are alternatives
+
+	DefaultSendQueueSize = 256 // packets of one traffic class waiting to be sent to one peer
+
+	ControlTrafficWeight = 8 // packets of the class sent in one round of scheduling, when all the classes have packets
+	MessageTrafficWeight = 4
+	BulkTrafficWeight    = 1
+
 	FileChunkSize = 8 * 1024 // in bytes
 	//FileChunkSize = 64 // in bytes
 
 	SharedFilesPath = "_SharedFiles"
 	DownloadsPath   = "_Downloads"
+	PeerStoreFile   = "_Peers.json" // address book of neighbours, restored after restart
 
 	FileCommonMode = 0755                                 // owner=rwx, all others=rx
 	MaxFileSize    = (FileChunkSize / 32) * FileChunkSize // number of hashes * size of chunk
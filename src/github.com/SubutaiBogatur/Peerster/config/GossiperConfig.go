@@ -6,6 +6,15 @@ const (
 	RumorTimeout       = 1 * time.Second // if peer doesn't answer with status, flip a coin
 	AntiEntropyTimeout = 1 * time.Second // send statuses every time timeout shoots
 
+	MaxMongeringSessionsPerPeer = 16 // rumors mongered to one peer at once, the rest are left to anti-entropy
+
+	MaxBufferedRumorsPerOrigin = 64   // rumors, which came before previous ones of the origin, wait for them, if not too far ahead
+	MaxBufferedRumors          = 1024 // of all the origins, the oldest buffered rumors are evicted above it
+
+	DigestLeafSize  = 16 // range of status digest with so many origins is listed instead of hashed
+	DigestFanout    = 4  // range with different hashes is split into so many ranges
+	DigestMaxRanges = 64 // ranges in one status digest, the rest are reconciled in the next rounds
+
 	FileDownloadReplyTimeout  = 5 * time.Second // if peer doesn't answer with chunk, resend the chunk request
 	FileDownloadTimeoutsLimit = 5               // number of times requests are resent
 
@@ -16,8 +25,42 @@ const (
 
 	RecentSearchRequestTimeout = 500 * time.Millisecond // don't answer to same search-requests for some time
 
+	FragmentReassemblyTimeout = 5 * time.Second // if not all the fragments of the packet arrived in time, the packet is dropped
+
+	BatchCoalescingWindow = 2 * time.Millisecond // peer writer waits so long for more small packets to the same peer
+
+	HelloRetryTimeout = 2 * time.Second // if peer doesn't answer to hello, it's greeted again with next anti-entropy status
+
+	LinkHandshakeTimeout = 1 * time.Second // if neighbour doesn't answer to the handshake, it's started again with next datagram
+
+	RouteFailureTimeout         = 10 * time.Second // route, which got no reply, is not used for so long, unless it's advertised again
+	NeighbourMissedRepliesLimit = 2                // neighbour, which missed so many replies in a row, is avoided as next hop
+
 	DefaultHopLimit = 5 // used eg for private messages, search replies, data replies, etc..
 
+	SimpleHopLimit      = 10   // simple message is flooded so far
+	SimpleSeenCacheSize = 1024 // so many latest simple messages are relayed only once
+
+	LivenessProbePeriod      = 1 * time.Second        // one neighbour is probed per period
+	LivenessAckTimeout       = 300 * time.Millisecond // if neighbour doesn't ack, other neighbours are asked to probe it
+	LivenessIndirectProbes   = 2                      // neighbours, which are asked to probe silent one
+	LivenessSuspicionTimeout = 3 * time.Second        // suspected neighbour, which is not heard for so long, is dead
+	DeadPeerRetryPeriod      = 10 * time.Second       // dead neighbour is probed so often, so it's re-admitted, when it comes back
+	DeadPeerForgetTimeout    = 10 * time.Minute       // dead neighbour is not probed any more
+
+	DefaultTargetDegree     = 4               // peer exchange asks neighbours for their neighbours, until gossiper has so many
+	PeerExchangePeriod      = 3 * time.Second // one neighbour is asked per period, if degree is below target
+	PeerExchangeSampleSize  = 8               // addresses sent in one exchange, the rest of received ones are ignored
+	PeerExchangeMaxAccepted = 2               // new peers added from one exchange, so that one neighbour cannot flood peers
+
+	PeerStoreSavePeriod    = 30 * time.Second   // address book is written to disk so often and when gossiper stops
+	PeerStoreMaxFailures   = 3                  // neighbour, which was found dead so many times in a row, is not restored
+	PeerStoreForgetTimeout = 7 * 24 * time.Hour // neighbour, which was not heard for so long, is removed from address book
+
+	ProbeHopLimit = 16              // pings and traceroutes go further, than other messages, to find long routes
+	ProbeTimeout  = 5 * time.Second // ping or traceroute without answer is considered lost
+	MaxKeptProbes = 100             // only results of the latest probes are kept
+
 	BlockhainBytesForGoodBlock     = 2 // 16 first bits want zeroes
 	BlockchainNoTxTimeout          = 2 * time.Second
 	BlockchainTxHopLimit           = 10
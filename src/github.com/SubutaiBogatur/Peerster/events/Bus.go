@@ -0,0 +1,66 @@
+package events
+
+import "sync"
+
+// Gossiper doesn't print anything itself, instead it publishes typed events to the bus. Whoever is interested
+// (stdout printer, tests, webserver, metrics) subscribes to it
+//
+// Handlers are called synchronously in the goroutine, which published the event, in order of subscription, so
+// events of one goroutine are seen in the same order by everyone. Handler must be quick, must not block and
+// must not (un)subscribe from inside, else it will deadlock
+
+type Handler func(e Event)
+
+type Bus struct {
+	handlers      map[int]Handler
+	order         []int // subscription ids in order of subscription
+	nextHandlerId int
+
+	mux sync.RWMutex
+}
+
+func NewBus() *Bus {
+	return &Bus{handlers: make(map[int]Handler)}
+}
+
+// returns id, which is used to unsubscribe
+func (b *Bus) Subscribe(h Handler) int {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	id := b.nextHandlerId
+	b.nextHandlerId++
+	b.handlers[id] = h
+	b.order = append(b.order, id)
+	return id
+}
+
+func (b *Bus) Unsubscribe(id int) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	if _, ok := b.handlers[id]; !ok {
+		return
+	}
+	delete(b.handlers, id)
+	for i, other := range b.order {
+		if other == id {
+			b.order = append(b.order[:i], b.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// can be called on nil bus, then does nothing
+func (b *Bus) Publish(e Event) {
+	if b == nil {
+		return
+	}
+
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+
+	for _, id := range b.order {
+		b.handlers[id](e)
+	}
+}
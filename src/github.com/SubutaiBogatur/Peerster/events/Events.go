@@ -0,0 +1,206 @@
+package events
+
+import (
+	. "github.com/SubutaiBogatur/Peerster/models"
+	"net"
+	"time"
+)
+
+// Every event is a struct with exported fields, subscriber distinguishes them with type switch
+
+type Event interface {
+	isEvent()
+}
+
+// client sent message to gossiper
+type ClientMessageEvent struct {
+	Message *ClientMessage
+	Peers   []*net.UDPAddr // peers at the moment of receiving
+}
+
+// gossiper received a packet from a peer, published before the packet is processed. Processing can decrease
+// hop limits of the packet, so handlers, which keep the packet, see them already decreased
+type PacketEvent struct {
+	From   *net.UDPAddr
+	Packet *GossipPacket
+	Peers  []*net.UDPAddr // peers at the moment of receiving
+}
+
+// rumor is sent to the peer and gossiper waits for status from it
+type MongeringEvent struct {
+	Peer  *net.UDPAddr
+	Rumor *RumorMessage
+}
+
+// status from the peer shows, that both have the same messages
+type InSyncEvent struct {
+	Peer *net.UDPAddr
+}
+
+// coin said to continue rumor-mongering with the peer
+type CoinFlippedEvent struct {
+	Peer  *net.UDPAddr
+	Rumor *RumorMessage
+}
+
+// message from the peer is dropped, because it's not signed by the key of its origin
+type ForgedMessageEvent struct {
+	From   *net.UDPAddr
+	Origin string
+}
+
+// hello from the peer is received
+type HelloEvent struct {
+	Peer         *net.UDPAddr
+	Version      uint32
+	Capabilities []string
+}
+
+// handshake with the neighbour succeeded, datagrams with it are encrypted and authenticated
+type LinkEstablishedEvent struct {
+	Peer *net.UDPAddr
+	Name string // proven by the neighbour with the key of the origin
+}
+
+// datagram from the address is dropped, because it doesn't belong to a secure link
+type LinkRejectedEvent struct {
+	From   *net.UDPAddr
+	Reason string
+}
+
+// next hop to the origin changed
+type RouteEvent struct {
+	Origin   string
+	NextHop  *net.UDPAddr
+	HopCount uint32 // of the new route
+}
+
+// packet to the origin through the next hop got no reply, other routes are tried
+type RouteFailedEvent struct {
+	Origin  string
+	NextHop *net.UDPAddr
+}
+
+// neighbour missed NeighbourMissedRepliesLimit replies, routes through it are not used until it's heard again
+type NeighbourSilentEvent struct {
+	Peer *net.UDPAddr
+}
+
+// point-to-point packet is dropped, because no route to the destination is known, except the one back
+type UnroutableEvent struct {
+	Destination string
+	Reason      string
+}
+
+// neighbour didn't answer the liveness probe, neither directly, nor through other neighbours
+type PeerSuspectedEvent struct {
+	Peer *net.UDPAddr
+}
+
+// suspected neighbour was not heard for LivenessSuspicionTimeout, it's removed from peers
+type PeerDeadEvent struct {
+	Peer *net.UDPAddr
+}
+
+// suspected or dead neighbour is heard again, dead one is added to peers back
+type PeerAliveEvent struct {
+	Peer *net.UDPAddr
+	Was  string // "suspected" or "dead"
+}
+
+// address of new neighbour came in peer exchange, it's added to peers
+type PeerDiscoveredEvent struct {
+	Peer *net.UDPAddr
+	From *net.UDPAddr // neighbour, who told about it
+}
+
+// ping or traceroute of this gossiper is answered or is given up
+type ProbeFinishedEvent struct {
+	Kind        string // "ping" or "traceroute"
+	Destination string
+	Status      string // "answered", "timeout" or "unroutable"
+	RoundTrip   time.Duration
+	HopCount    uint32
+	Hops        []*TraceHop // traceroute only, from this gossiper to destination
+}
+
+type FileSharedEvent struct {
+	Name     string
+	MetaHash [32]byte
+}
+
+type MetafileDownloadedEvent struct {
+	Name string // name, under which file is saved
+	From string // origin
+}
+
+type ChunkDownloadedEvent struct {
+	Name  string
+	Index int // 1-based number of the chunk in order of downloading
+	From  string
+}
+
+// file is fully downloaded and saved
+type FileReconstructedEvent struct {
+	Name string
+}
+
+// search reply brought a match, full or partial
+type SearchMatchEvent struct {
+	Origin string
+	Result *SearchResult
+}
+
+// enough full matches are found
+type SearchFinishedEvent struct {
+	Keywords []string
+}
+
+// this gossiper mined a block
+type BlockFoundEvent struct {
+	Block Block
+}
+
+// block is added to a chain, which is not the longest one
+type ForkShorterEvent struct {
+	Block Block
+}
+
+// shorter chain became the longest one
+type ForkLongerEvent struct {
+	Rewound int // number of blocks, which are not in the longest chain anymore
+}
+
+// longest chain changed
+type ChainEvent struct {
+	Blocks []Block // from the last block to the first one
+}
+
+func (ClientMessageEvent) isEvent()      {}
+func (PacketEvent) isEvent()             {}
+func (MongeringEvent) isEvent()          {}
+func (InSyncEvent) isEvent()             {}
+func (CoinFlippedEvent) isEvent()        {}
+func (ForgedMessageEvent) isEvent()      {}
+func (HelloEvent) isEvent()              {}
+func (LinkEstablishedEvent) isEvent()    {}
+func (LinkRejectedEvent) isEvent()       {}
+func (RouteEvent) isEvent()              {}
+func (RouteFailedEvent) isEvent()        {}
+func (NeighbourSilentEvent) isEvent()    {}
+func (UnroutableEvent) isEvent()         {}
+func (ProbeFinishedEvent) isEvent()      {}
+func (PeerSuspectedEvent) isEvent()      {}
+func (PeerDeadEvent) isEvent()           {}
+func (PeerAliveEvent) isEvent()          {}
+func (PeerDiscoveredEvent) isEvent()     {}
+func (FileSharedEvent) isEvent()         {}
+func (MetafileDownloadedEvent) isEvent() {}
+func (ChunkDownloadedEvent) isEvent()    {}
+func (FileReconstructedEvent) isEvent()  {}
+func (SearchMatchEvent) isEvent()        {}
+func (SearchFinishedEvent) isEvent()     {}
+func (BlockFoundEvent) isEvent()         {}
+func (ForkShorterEvent) isEvent()        {}
+func (ForkLongerEvent) isEvent()         {}
+func (ChainEvent) isEvent()              {}
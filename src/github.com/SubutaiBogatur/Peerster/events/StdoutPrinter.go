@@ -0,0 +1,177 @@
+package events
+
+import (
+	"encoding/hex"
+	"fmt"
+	. "github.com/SubutaiBogatur/Peerster/models"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Handler, which prints events to stdout in the format, required by homeworks. Subscribe it to see what gossiper does
+func PrintToStdout(e Event) {
+	switch e := e.(type) {
+	case ClientMessageEvent:
+		if printClientMessage(e.Message) {
+			printPeers(e.Peers) // if printed something
+		}
+	case PacketEvent:
+		if printPacket(e.From, e.Packet) {
+			printPeers(e.Peers) // if printed something
+		}
+	case MongeringEvent:
+		fmt.Println("MONGERING with " + e.Peer.String())
+	case InSyncEvent:
+		fmt.Println("IN SYNC WITH " + e.Peer.String())
+	case CoinFlippedEvent:
+		fmt.Println("FLIPPED COIN sending rumor to " + e.Peer.String())
+	case ForgedMessageEvent:
+		fmt.Println("REJECTED message of " + e.Origin + " from " + e.From.String() + ": bad signature")
+	case HelloEvent:
+		fmt.Println("HELLO from " + e.Peer.String() + " version " + strconv.Itoa(int(e.Version)) + " capabilities " + strings.Join(e.Capabilities, ","))
+	case LinkEstablishedEvent:
+		fmt.Println("SECURE LINK with " + e.Peer.String() + " name " + e.Name)
+	case LinkRejectedEvent:
+		fmt.Println("REJECTED datagram from " + e.From.String() + ": " + e.Reason)
+	case RouteEvent:
+		fmt.Println("DSDV " + e.Origin + " " + e.NextHop.String())
+	case RouteFailedEvent:
+		fmt.Println("ROUTE FAILED to " + e.Origin + " through " + e.NextHop.String())
+	case NeighbourSilentEvent:
+		fmt.Println("SILENT neighbour " + e.Peer.String())
+	case UnroutableEvent:
+		fmt.Println("UNROUTABLE packet to " + e.Destination + ": " + e.Reason)
+	case ProbeFinishedEvent:
+		printProbe(e)
+	case PeerSuspectedEvent:
+		fmt.Println("SUSPECT peer " + e.Peer.String())
+	case PeerDeadEvent:
+		fmt.Println("DEAD peer " + e.Peer.String())
+	case PeerAliveEvent:
+		fmt.Println("ALIVE peer " + e.Peer.String() + " was " + e.Was)
+	case PeerDiscoveredEvent:
+		fmt.Println("DISCOVERED peer " + e.Peer.String() + " from " + e.From.String())
+	case FileSharedEvent:
+		fmt.Println("SHARED FILE " + e.Name + " GOT METAHASH " + hex.EncodeToString(e.MetaHash[:]))
+	case MetafileDownloadedEvent:
+		fmt.Println("DOWNLOADING metafile of " + e.Name + " from " + e.From)
+	case ChunkDownloadedEvent:
+		fmt.Println("DOWNLOADING " + e.Name + " chunk " + strconv.Itoa(e.Index) + " from " + e.From)
+	case FileReconstructedEvent:
+		fmt.Println("RECONSTRUCTED file " + e.Name)
+	case SearchMatchEvent:
+		fmt.Println("FOUND match " + e.Result.FileName + " at " + e.Origin +
+			" metafile=" + hex.EncodeToString(e.Result.MetafileHash) +
+			" chunks=" + strings.Trim(strings.Join(strings.Fields(fmt.Sprint(e.Result.ChunkMap)), ","), "[]"))
+	case SearchFinishedEvent:
+		fmt.Println("SEARCH FINISHED")
+	case BlockFoundEvent:
+		fmt.Println("FOUND-BLOCK " + e.Block.String())
+	case ForkShorterEvent:
+		fmt.Println("FORK-SHORTER " + e.Block.String())
+	case ForkLongerEvent:
+		fmt.Println("FORK-LONGER rewind " + strconv.Itoa(e.Rewound) + " blocks")
+	case ChainEvent:
+		printChain(e.Blocks)
+	}
+}
+
+// returns true, if printed something
+func printClientMessage(cmsg *ClientMessage) bool {
+	if cmsg.Rumor != nil {
+		rcmsg := cmsg.Rumor
+		fmt.Println("CLIENT MESSAGE " + rcmsg.Text)
+	} else if cmsg.Private != nil {
+		pcmsg := cmsg.Private
+		fmt.Println("CLIENT PRIVATE TO " + pcmsg.Destination + ": " + pcmsg.Text)
+	} else if cmsg.ToShare != nil {
+		tscmsg := cmsg.ToShare
+		fmt.Println("CLIENT SHARE REQUEST: " + tscmsg.Path)
+	} else if cmsg.ToDownload != nil {
+		tdcmsg := cmsg.ToDownload
+		fmt.Println("CLIENT DOWNLOAD REQUEST: " + tdcmsg.Name + " from " + tdcmsg.Destination + " hash " + hex.EncodeToString(tdcmsg.HashValue[:]))
+	} else {
+		// client route rumor message
+		return false
+	}
+	return true
+}
+
+// returns true, if printed something
+func printPacket(from *net.UDPAddr, gp *GossipPacket) bool {
+	if gp.Rumor != nil {
+		if gp.Rumor.Text == "" {
+			return false
+		}
+		rmsg := gp.Rumor
+		fmt.Println("RUMOR origin " + rmsg.OriginalName + " from " + from.String() + " ID " + strconv.Itoa(int(rmsg.ID)) + " contents " + rmsg.Text)
+	} else if gp.RumorBundle != nil {
+		printed := false
+		for _, rmsg := range gp.RumorBundle.Rumors {
+			if rmsg != nil && rmsg.Text != "" {
+				fmt.Println("RUMOR origin " + rmsg.OriginalName + " from " + from.String() + " ID " + strconv.Itoa(int(rmsg.ID)) + " contents " + rmsg.Text)
+				printed = true
+			}
+		}
+		return printed
+	} else if gp.Status != nil {
+		status := gp.Status
+		fmt.Print("STATUS from " + from.String())
+		for _, peerStatus := range status.Want {
+			fmt.Print(" peer " + peerStatus.Identifier + " nextID " + strconv.Itoa(int(peerStatus.NextID)))
+		}
+		fmt.Println()
+	} else if gp.Simple != nil {
+		smsg := gp.Simple
+		fmt.Println("SIMPLE MESSAGE origin " + smsg.OriginalName + " from " + smsg.RelayPeerAddr + " contents " + smsg.Text)
+	} else if gp.Private != nil {
+		pmsg := gp.Private
+		text := pmsg.Text
+		if pmsg.IsEncrypted() {
+			text = "(encrypted)"
+		}
+		fmt.Println("PRIVATE origin " + pmsg.Origin + " hop-limit " + fmt.Sprint(pmsg.HopLimit) + " contents " + text)
+	} else {
+		return false // other packets are not printed
+	}
+	return true
+}
+
+func printPeers(peers []*net.UDPAddr) {
+	addresses := make([]string, 0, len(peers))
+	for _, peer := range peers {
+		addresses = append(addresses, peer.String())
+	}
+	fmt.Println("PEERS " + strings.Join(addresses, ","))
+}
+
+func printChain(blocks []Block) {
+	str := "CHAIN"
+	for _, block := range blocks {
+		txs := make([]string, 0, len(block.Transactions))
+		for _, tx := range block.Transactions {
+			txs = append(txs, tx.File.Name)
+		}
+
+		str += " " + block.String() + ":" + hex.EncodeToString(block.PrevHash[:]) + ":" + strings.Join(txs, ",")
+	}
+	fmt.Println(str)
+}
+
+func printProbe(e ProbeFinishedEvent) {
+	if e.Status != "answered" {
+		fmt.Println(strings.ToUpper(e.Kind) + " to " + e.Destination + " " + e.Status)
+		return
+	}
+	fmt.Println(strings.ToUpper(e.Kind) + " to " + e.Destination + " hops " + strconv.Itoa(int(e.HopCount)) + " rtt " + e.RoundTrip.String())
+	if len(e.Hops) == 0 {
+		return
+	}
+	start := e.Hops[0].Timestamp
+	for i, hop := range e.Hops {
+		// clocks of gossipers are not synchronized, so offsets are only approximate
+		fmt.Println("  " + strconv.Itoa(i) + " " + hop.Name + " +" + time.Duration(hop.Timestamp-start).String())
+	}
+}
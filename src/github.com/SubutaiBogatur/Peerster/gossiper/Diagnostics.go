@@ -0,0 +1,104 @@
+package gossiper
+
+import (
+	. "github.com/SubutaiBogatur/Peerster/config"
+	. "github.com/SubutaiBogatur/Peerster/models"
+	. "github.com/SubutaiBogatur/Peerster/models/diagnostics"
+	. "net"
+	"strconv"
+)
+
+// Pings and traceroutes are routed with the routing table as private messages are. Destination answers with pong
+// or with the traceroute itself, so origin learns, if destination is reachable, how many hops away it is and what is
+// the round trip. Traceroute also shows names of the gossipers on the way. Probes without answer for ProbeTimeout
+// are given up, see ProbeManager
+
+func (g *Gossiper) processClientToProbe(ctpmsg *ClientToProbeMessage) {
+	gossiperName := g.name.Load().(string)
+	kind := PingProbe
+	if ctpmsg.IsTraceroute {
+		kind = TracerouteProbe
+	}
+
+	id := g.probes.Start(kind, ctpmsg.Destination)
+	var gp *GossipPacket
+	if ctpmsg.IsTraceroute {
+		hops := []*TraceHop{{Name: gossiperName, Timestamp: g.clock.Now().UnixNano()}}
+		gp = &GossipPacket{Traceroute: &Traceroute{Origin: gossiperName, Destination: ctpmsg.Destination, ID: id, HopLimit: ProbeHopLimit, Hops: hops}}
+	} else {
+		gp = &GossipPacket{Ping: &Ping{Origin: gossiperName, Destination: ctpmsg.Destination, ID: id, HopLimit: ProbeHopLimit}}
+	}
+
+	g.l.Info("sending " + kind + " " + strconv.Itoa(int(id)) + " to " + ctpmsg.Destination)
+	if _, err := g.sendPacketWithNextHop(ctpmsg.Destination, gp, nil); err != nil {
+		g.probes.Fail(id, ProbeUnroutable)
+		return
+	}
+	g.spawn(func() {
+		if g.sleep(ProbeTimeout) {
+			g.probes.Fail(id, ProbeTimedOut)
+		}
+	})
+}
+
+func (g *Gossiper) processAddressedPing(ping *Ping, address *UDPAddr) {
+	gossiperName := g.name.Load().(string)
+
+	if ping.Destination == gossiperName {
+		g.l.Info("answering ping " + strconv.Itoa(int(ping.ID)) + " of " + ping.Origin)
+		pong := &Pong{Origin: gossiperName, Destination: ping.Origin, ID: ping.ID, HopLimit: ProbeHopLimit, HopCount: ProbeHopLimit - ping.HopLimit + 1}
+		g.sendPacketWithNextHop(pong.Destination, &GossipPacket{Pong: pong}, nil)
+		return
+	}
+	if ping.HopLimit <= 0 {
+		g.l.Warn("hop limit for forwarding exceeded, drop the ping..")
+		return
+	}
+
+	relayed := *ping
+	relayed.HopLimit--
+	g.sendPacketWithNextHop(relayed.Destination, &GossipPacket{Ping: &relayed}, address)
+}
+
+func (g *Gossiper) processAddressedPong(pong *Pong, address *UDPAddr) {
+	if pong.Destination == g.name.Load().(string) {
+		g.probes.Answer(pong.ID, PingProbe, pong.Origin, pong.HopCount, nil)
+		return
+	}
+	if pong.HopLimit <= 0 {
+		g.l.Warn("hop limit for forwarding exceeded, drop the pong..")
+		return
+	}
+
+	relayed := *pong
+	relayed.HopLimit--
+	g.sendPacketWithNextHop(relayed.Destination, &GossipPacket{Pong: &relayed}, address)
+}
+
+func (g *Gossiper) processAddressedTraceroute(tr *Traceroute, address *UDPAddr) {
+	gossiperName := g.name.Load().(string)
+
+	if tr.IsReply && tr.Destination == gossiperName {
+		g.probes.Answer(tr.ID, TracerouteProbe, tr.Origin, uint32(len(tr.Hops)-1), tr.Hops)
+		return
+	}
+
+	// received traceroute was already published as event, so it's not changed, but copied
+	relayed := *tr
+	if !tr.IsReply {
+		relayed.Hops = append(append([]*TraceHop{}, tr.Hops...), &TraceHop{Name: gossiperName, Timestamp: g.clock.Now().UnixNano()})
+	}
+	if !tr.IsReply && tr.Destination == gossiperName {
+		g.l.Info("answering traceroute " + strconv.Itoa(int(tr.ID)) + " of " + tr.Origin)
+		reply := &Traceroute{Origin: gossiperName, Destination: tr.Origin, ID: tr.ID, HopLimit: ProbeHopLimit, Hops: relayed.Hops, IsReply: true}
+		g.sendPacketWithNextHop(reply.Destination, &GossipPacket{Traceroute: reply}, nil)
+		return
+	}
+	if tr.HopLimit <= 0 {
+		g.l.Warn("hop limit for forwarding exceeded, drop the traceroute..")
+		return
+	}
+
+	relayed.HopLimit--
+	g.sendPacketWithNextHop(relayed.Destination, &GossipPacket{Traceroute: &relayed}, address)
+}
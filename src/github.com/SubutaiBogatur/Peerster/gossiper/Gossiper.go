@@ -1,19 +1,33 @@
 package gossiper
 
 import (
+	"context"
 	"encoding/hex"
-	"fmt"
 	. "github.com/SubutaiBogatur/Peerster/config"
+	. "github.com/SubutaiBogatur/Peerster/events"
 	. "github.com/SubutaiBogatur/Peerster/models"
 	. "github.com/SubutaiBogatur/Peerster/models/blockchain"
+	. "github.com/SubutaiBogatur/Peerster/models/diagnostics"
 	. "github.com/SubutaiBogatur/Peerster/models/filesearching"
 	. "github.com/SubutaiBogatur/Peerster/models/filesharing"
+	. "github.com/SubutaiBogatur/Peerster/models/flooding"
+	. "github.com/SubutaiBogatur/Peerster/models/fragmentation"
+	. "github.com/SubutaiBogatur/Peerster/models/identity"
+	. "github.com/SubutaiBogatur/Peerster/models/links"
+	. "github.com/SubutaiBogatur/Peerster/models/liveness"
+	. "github.com/SubutaiBogatur/Peerster/models/mongering"
+	. "github.com/SubutaiBogatur/Peerster/models/negotiation"
+	. "github.com/SubutaiBogatur/Peerster/models/peerstore"
+	. "github.com/SubutaiBogatur/Peerster/models/routing"
+	. "github.com/SubutaiBogatur/Peerster/models/sending"
+	. "github.com/SubutaiBogatur/Peerster/transport"
 	. "github.com/SubutaiBogatur/Peerster/utils"
+	"github.com/SubutaiBogatur/Peerster/utils/clock"
 	"github.com/dedis/protobuf"
 	log "github.com/sirupsen/logrus"
 	"math/rand"
 	. "net"
-	"sort"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
@@ -25,18 +39,22 @@ import (
 //
 // We will have threads:
 // * client-reader          thread : the only port reading from client socket. Sends client message to message-processor
-// * peer-reader            thread : the only port reading from peer socket. Sends GossipPackets to message-processor
-// * peer-writer            thread : the only port writing to peer socket. Listens to channel for GossipPackets and writes them
+// * peer-reader            thread : the only port reading from peer transport. Reassembles fragmented packets and sends GossipPackets to message-processor
+// * peer-writer            thread : the only port writing to peer transport. Takes GossipPackets from per-peer queues by traffic class and writes them,
+//                                    batching small ones to the same peer and splitting too large ones into fragments
 // * anti-entropy-timer     thread : goroutine sends a status to random peer every timeout seconds
 // * route-rumoring         thread : once in a timer sends empty rumor message to random peers, so everyone will know about this origin (accesses nothing)
 // * message-processor      thread : is an abstraction on client-listener, peer-listener threads. Receives all the messages and for every message:
 //     + rumor-msg      : upd status, send status back, send rumor randomly further, start rumor-mongering thread waiting for status
-//     + status-msg     : push it to one of the rumor-mongering threads (if rumor mongering is not in progress, then compare statuses and start it)
+//     + status-msg     : push it to one of the rumor-mongering threads (if rumor mongering is not in progress, then compare statuses and start it),
+//                        compact statuses are reconciled range by range, see StatusDigests.go
+//     + rumor-bundle   : same as rumor-msg for every rumor, but one status back, see RumorBundles.go
 //     + private        : ezy - forward if needed, else display
 //     + data-request   : just answer with needed data, no state saved
 //     + data-reply     : answer with next request (if needed) and start file-downloading thread to wait for next data-reply or timeout
 //     + search-request : answer with needed data & start search-reply-timeout thread not to answer this request once again
 //     + search-reply   : put message via channel (via struct) to the only search-request thread
+//     + ping/pong/trace: forward if needed, else answer or finish the probe, see Diagnostics.go
 // * rumor-mongering        thread : thread waits either for status-msg to arrive or for timeout and stores rumor-msg, it was initiated for
 //     + status-msg : cmp (store sync-safe Map for VectorClock, which are edited from message-processor) and send new msg via peer-communicator
 //     + timeout    : 1/2 & send new rumor-msg via peer-communicator
@@ -45,26 +63,31 @@ import (
 // * search-request-timeout thread : we don't answer the same search-request for some time after we answered it
 // * search-request         thread : the only goroutine, which maintains current search-request: reads search-replies and repeats search-requests with more budget
 // * mining                 thread : all the time, when exists pending tx, tries to generate new block and then publishes it
+// * probe-timeout          thread : gives up ping or traceroute, if it's not answered in time
+// * liveness-probing       thread : probes one neighbour per period, removes dead neighbours from peers, see Liveness.go
+// * peer-exchange          thread : while there are less neighbours, than target degree, asks one of them for its neighbours, see PeerExchange.go
+// * peer-store-saving      thread : writes address book of neighbours to data directory, rejoins through bootstrap list, if all peers are gone, see PeerStore.go
+//
+// All the threads are started by Run and are registered in the gossiper wait-group. Every thread listens to gossiper context,
+// so Stop (or cancellation of the context passed to Run) makes all of them finish, after that sockets are closed
+
+type Gossiper struct {
+	name atomic.Value // name can be changed by webserver and is accessed from message-processor, thus using atomic string
 
-var (
-	clientMessagesToProcess = make(chan *ClientMessage)
-	peerMessagesToProcess   = make(chan *AddressedGossipPacket)
-	peerMessagesToSend      = make(chan *AddressedGossipPacket)
+	// all the state below is per-gossiper, so many gossipers can live in one process
+	clientMessagesToProcess chan *ClientMessage
+	peerMessagesToProcess   chan *AddressedGossipPacket
+	peerMessagesToSend      *PeerQueues // bounded queue per peer, pushing never blocks, so it's done even under locks
 
-	// map is accessed from message-processor and from rumor-mongering threads, should be converted to sync.Map{}
-	statusesChannels    = make(map[string]chan *StatusPacket) // peerIp -> channel, where rumor-mongering goroutine is waiting for status feedback
-	statusesChannelsMux sync.Mutex
+	// accessed from message-processor and from rumor-mongering threads
+	mongeringSessions *MongeringSessions // rumor-mongering goroutines, which are waiting for status feedback
 
 	// accessed from message-processor and from file-downloading threads
-	downloadingFilesChannels    = make(map[string]chan *DataReply)
+	downloadingFilesChannels    map[string]chan *DataReply
 	downloadingFilesChannelsMux sync.Mutex
-)
-
-type Gossiper struct {
-	name atomic.Value // name can be changed by webserver and is accessed from message-processor, thus using atomic string
 
-	peersAddress     *UDPAddr // peersAddress for peers
-	peersConnection  *UDPConn
+	peersAddress     *UDPAddr  // peersAddress for peers
+	peersTransport   Transport // either real udp socket or endpoint of simulated network
 	clientAddress    *UDPAddr
 	clientConnection *UDPConn
 
@@ -72,39 +95,140 @@ type Gossiper struct {
 	peers         []*UDPAddr // accessed eg from message-processor and from rumor-mongering
 	peersSliceMux sync.Mutex
 
-	// keys are origins. Can be understood as gossipers, who initiated messages we received (not relay!)
-	// values are peers, from whom we received the latest message from the origin
-	nextHop    map[string]*UDPAddr // accessed from message-processor and from webserver (for keys)
-	nextHopMux sync.Mutex
+	peerStore *PeerStore // address book of neighbours, which survives restarts, is hard-synchronized
+	bootstrap []*UDPAddr // neighbours to join the mesh through, if address book has none, never changed after creation
+
+	// routes to origins, ie gossipers, who initiated messages we received (not relay!). Every rumor of the origin
+	// advertises route through the peer, from whom it came
+	routingTable *RoutingTable // accessed from message-processor and from webserver, is hard-synchronized
 
 	messageStorage          *MessageStorage          // accessed eg from message-processor and from rumor-mongering, is hard-synchronized
 	sharedFilesManager      *SharedFilesManager      // accessed eg from message-processor and from search-request, is hard-synchronized
 	downloadingFilesManager *DownloadingFilesManager // accessed eg from message-processor and from file-downloading, is soft-synchronized
 	blockchainManager       *BlockchainManager       // accessed eg from message-processor and from mining-thread, is hard-synchronized
 
+	reassembler *Reassembler // accessed only from peer-reader
+
+	identity *Identity    // keypair, rumors and private messages of this gossiper are signed with it
+	keyStore *KeyStore    // keys of origins, messages not signed by the key of their origin are dropped, is hard-synchronized
+	links    *LinkManager // sessions with neighbours, used only if secureLinks, accessed from peer-reader and peer-writer
+
+	capabilities *CapabilityTable // what peers said in hellos, optional features are used only with peers, which have them
+
+	liveness         *LivenessDetector // which neighbours answer, dead ones are removed from peers
+	livenessProbeSeq uint64            // seq of the last liveness probe, accessed only from liveness-probing
+
+	probes *ProbeManager // pings and traceroutes of this gossiper, accessed from message-processor and from webserver
+
 	currentSearchRequest *CurrentSearchRequest
 
 	recentSearchRequestsMux sync.Mutex
 	recentSearchRequests    map[string]bool // set of recently answered search-requests, don't answer them now, key = "{origin}-{keywords separated with coma}"
 
-	isSimpleMode bool       // in simple mode sending only simple messages
-	l            *log.Entry // logger
+	simpleID   uint32     // id of the last own simple message, accessed only from message-processor
+	seenSimple *SeenCache // simple messages, which were already relayed, accessed only from message-processor
+
+	isSimpleMode    bool // in simple mode sending only simple messages
+	noAntiEntropy   bool // if true, anti-entropy thread is not started
+	routeRumorTimer int  // route rumors sending period in seconds, 0 to disable
+	noBatching      bool // if true, every packet is sent in its own datagram
+	noLiveness      bool // if true, liveness-probing thread is not started
+	noPeerExchange  bool // if true, peer-exchange thread is not started and exchanges of others are ignored
+	noStatusDigests bool // if true, full vector clock is sent to every peer
+	targetDegree    int  // peer exchange looks for new neighbours, until there are so many
+	encryptPrivate  bool // if true, text of own private messages is encrypted for destination
+	secureLinks     bool // if true, every datagram with neighbours is sealed, see SecureLinks.go
+
+	clock clock.Clock // every timeout is measured with it, never use time package directly
+
+	events *Bus // everything interesting, which happens, is published here, gossiper never prints itself
+
+	// lifecycle, see Lifecycle.go
+	ctx      context.Context // is cancelled, when gossiper is being stopped
+	cancel   context.CancelFunc
+	threads  sync.WaitGroup // all the gossiper goroutines are registered here
+	state    int32          // atomic, one of gossiperCreated, gossiperRunning, gossiperStopped
+	finished chan struct{}  // is closed, when all the threads are finished and sockets are closed
+
+	l *log.Entry // logger
 }
 
-func NewGossiper(name string, uiport int, peersAddress string, peers string, isSimpleMode bool) (*Gossiper, error) {
+func NewGossiper(opts GossiperOptions) (*Gossiper, error) {
+	name := opts.Name
+	peersAddress := opts.GossipAddr
+	peers := opts.Peers
 	logger := log.WithField("bin", "gos").WithField("name", name)
 
 	g := &Gossiper{}
+	g.clientMessagesToProcess = make(chan *ClientMessage)
+	g.peerMessagesToProcess = make(chan *AddressedGossipPacket)
+	sendQueueSize := opts.SendQueueSize
+	if sendQueueSize <= 0 {
+		sendQueueSize = DefaultSendQueueSize
+	}
+	g.peerMessagesToSend = InitPeerQueues(sendQueueSize, opts.SendQueuePolicy, logger)
+	g.mongeringSessions = InitMongeringSessions()
+	g.downloadingFilesChannels = make(map[string]chan *DataReply)
+	g.events = NewBus()
 	g.messageStorage = InitMessageStorage(name)
-	g.sharedFilesManager = InitSharedFilesManager(logger)
-	g.downloadingFilesManager = InitDownloadingFilesManager(logger)
-	g.blockchainManager = InitBlockchainManager(logger)
-	g.nextHop = make(map[string]*UDPAddr)
+	g.sharedFilesManager = InitSharedFilesManager(opts.DataDir, g.events, logger)
+	g.downloadingFilesManager = InitDownloadingFilesManager(opts.DataDir, g.events, logger)
+	g.clock = opts.Clock
+	if g.clock == nil {
+		g.clock = clock.NewRealClock()
+	}
+	g.blockchainManager = InitBlockchainManager(g.clock, g.events, logger)
+	g.reassembler = InitReassembler(g.clock, logger)
+	identity, err := LoadOrCreateIdentity(opts.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	g.identity = identity
+	g.keyStore, err = InitKeyStore(opts.TrustFile, logger)
+	if err != nil {
+		return nil, err
+	}
+	g.keyStore.Bind(name, g.identity.PublicKey())
+	g.links = InitLinkManager(g.identity, g.keyStore, g.clock, logger)
+	g.routingTable = InitRoutingTable(g.clock, logger)
+	g.probes = InitProbeManager(g.clock, g.events, logger)
+	g.liveness = InitLivenessDetector(g.clock, logger)
+	g.peerStore, err = InitPeerStore(filepath.Join(opts.DataDir, PeerStoreFile), g.clock, logger)
+	if err != nil {
+		return nil, err
+	}
 	g.recentSearchRequests = make(map[string]bool)
 	g.l = logger
-	g.isSimpleMode = isSimpleMode
+	g.isSimpleMode = opts.IsSimpleMode
+	g.simpleID = rand.Uint32() >> 1 // so that after restart own messages are not taken for already seen ones
+	g.seenSimple = InitSeenCache(SimpleSeenCacheSize)
+	g.noAntiEntropy = opts.NoAntiEntropy
+	g.routeRumorTimer = opts.RouteRumorTimer
+	g.noBatching = opts.NoBatching
+	g.noLiveness = opts.NoLiveness
+	g.noPeerExchange = opts.NoPeerExchange
+	g.noStatusDigests = opts.NoStatusDigests
+	g.targetDegree = opts.TargetDegree
+	if g.targetDegree <= 0 {
+		g.targetDegree = DefaultTargetDegree
+	}
+	g.encryptPrivate = opts.EncryptPrivate
+	g.secureLinks = opts.SecureLinks
+	ownCapabilities := []string{BatchingCapability, FragmentationCapability, EncryptedPrivateCapability, LivenessCapability, RumorBundleCapability}
+	if !g.noStatusDigests {
+		ownCapabilities = append(ownCapabilities, StatusDigestCapability)
+	}
+	if g.secureLinks {
+		ownCapabilities = append(ownCapabilities, SecureLinksCapability)
+	}
+	if !g.noPeerExchange {
+		ownCapabilities = append(ownCapabilities, PeerExchangeCapability)
+	}
+	g.capabilities = InitCapabilityTable(ownCapabilities, g.clock, logger)
+	g.ctx, g.cancel = context.WithCancel(context.Background())
+	g.finished = make(chan struct{})
 
-	clientListenAddress := LocalIp + ":" + strconv.Itoa(uiport)
+	clientListenAddress := LocalIp + ":" + strconv.Itoa(opts.UIPort)
 	clientListenUdpAddress, err := ResolveUDPAddr("udp4", clientListenAddress)
 	if err != nil {
 		g.l.Error("Unable to parse clientListenAddress: " + string(clientListenAddress))
@@ -112,54 +236,67 @@ func NewGossiper(name string, uiport int, peersAddress string, peers string, isS
 	}
 	g.clientAddress = clientListenUdpAddress
 
-	peersListenUdpAddress, err := ResolveUDPAddr("udp4", peersAddress)
-	if err != nil {
-		g.l.Error("Unable to parse peersListenAddress: " + string(peersAddress))
-		return g, err
+	if opts.Transport == nil {
+		peersListenUdpAddress, err := ResolveUDPAddr("udp4", peersAddress)
+		if err != nil {
+			g.l.Error("Unable to parse peersListenAddress: " + string(peersAddress))
+			return g, err
+		}
+		g.peersAddress = peersListenUdpAddress
+	} else {
+		g.peersAddress = opts.Transport.LocalAddr()
 	}
-	g.peersAddress = peersListenUdpAddress
-	g.l = g.l.WithField("a", peersListenUdpAddress.String())
+	g.l = g.l.WithField("a", g.peersAddress.String())
 
 	if name == "" {
 		return g, PeersterError{ErrorMsg: "Empty name provided"}
 	}
 	g.name.Store(name)
 
-	peersArr := strings.Split(peers, ",")
-	for i := 0; i < len(peersArr); i++ {
-		if peersArr[i] == "" {
-			continue
-		}
+	g.peers, err = parseAddresses(peers)
+	if err != nil {
+		g.l.Error("Error when parsing peers")
+		return g, err
+	}
+	g.bootstrap, err = parseAddresses(opts.Bootstrap)
+	if err != nil {
+		g.l.Error("Error when parsing bootstrap list")
+		return g, err
+	}
+	g.restorePeers()
 
-		peerAddr, err := ResolveUDPAddr("udp4", peersArr[i])
+	// command line arguments parsed, start listening:
+	if opts.Transport == nil {
+		peersUdpTransport, err := NewUdpTransport(g.peersAddress.String())
 		if err != nil {
-			g.l.Error("Error when parsing peers")
 			return g, err
 		}
-		g.peers = append(g.peers, peerAddr)
-	}
-
-	// command line arguments parsed, start listening:
-	peersUdpConn, err := ListenUDP("udp4", g.peersAddress)
-	if err != nil {
-		return g, err
+		g.peersTransport = peersUdpTransport
+	} else {
+		g.peersTransport = opts.Transport
 	}
-	g.peersConnection = peersUdpConn
+	g.peersAddress = g.peersTransport.LocalAddr() // port could be chosen by os
 
 	clientUdpConn, err := ListenUDP("udp4", g.clientAddress)
 	if err != nil {
+		g.peersTransport.Close()
 		return g, err
 	}
 	g.clientConnection = clientUdpConn
+	g.clientAddress = clientUdpConn.LocalAddr().(*UDPAddr)
 
 	return g, nil
 }
 
 //helper functions:
+// returns nil, if no peers are known, eg all of them are dead
 func (g *Gossiper) getRandomPeer() *UDPAddr {
 	g.peersSliceMux.Lock()
 	defer g.peersSliceMux.Unlock()
 
+	if len(g.peers) == 0 {
+		return nil
+	}
 	randomInt := rand.Int31n(int32(len(g.peers))) // end not inclusive
 	randomPeer := g.peers[randomInt]
 
@@ -179,7 +316,7 @@ func (g *Gossiper) sendAllPeers(gp *GossipPacket) {
 
 	for _, p := range g.peers {
 		agp := &AddressedGossipPacket{Packet: gp, Address: p}
-		peerMessagesToSend <- agp
+		g.sendToPeer(agp)
 	}
 }
 
@@ -203,35 +340,41 @@ func (g *Gossiper) UpdatePeersIfNeeded(peer *UDPAddr) {
 		}
 	}
 	g.peers = append(g.peers, peer)
+	g.greetPeer(peer)
 }
 
-func (g *Gossiper) printPeers() {
-	g.peersSliceMux.Lock()
-	defer g.peersSliceMux.Unlock()
+// handler is called for every event of this gossiper, see events package. Returns id to unsubscribe
+func (g *Gossiper) Subscribe(h Handler) int {
+	return g.events.Subscribe(h)
+}
 
-	fmt.Print("PEERS ")
-	for i := 0; i < len(g.peers); i++ {
-		if i == len(g.peers)-1 {
-			fmt.Print(g.peers[i].String())
-		} else {
-			fmt.Print(g.peers[i].String() + ",")
-		}
-	}
-	fmt.Println("")
+func (g *Gossiper) Unsubscribe(id int) {
+	g.events.Unsubscribe(id)
 }
 
 func (g *Gossiper) GetOriginsCopy() *[]string {
-	g.nextHopMux.Lock()
-	defer g.nextHopMux.Unlock()
+	origins := g.routingTable.GetOrigins()
+	return &origins
+}
 
-	origins := make([]string, 0, len(g.nextHop))
-	for k := range g.nextHop {
-		origins = append(origins, k) // todo: len + append, seems like bug
-	}
+// origin -> routes to it with sequence numbers and hop counts, the first one is used
+func (g *Gossiper) GetRoutingTable() map[string][]Route {
+	return g.routingTable.GetTableCopy()
+}
+
+// address -> what failure detector thinks about the neighbour, dead ones are not in peers
+func (g *Gossiper) GetPeersLiveness() map[string]PeerLiveness {
+	return g.liveness.GetPeersCopy()
+}
 
-	sort.Strings(origins)
+// neighbours, which are remembered between restarts
+func (g *Gossiper) GetAddressBook() []PeerRecord {
+	return g.peerStore.GetRecordsCopy()
+}
 
-	return &origins
+// pings and traceroutes of this gossiper, from the oldest
+func (g *Gossiper) GetProbes() []Probe {
+	return g.probes.GetProbesCopy()
 }
 
 func (g *Gossiper) GetSharedFiles() []string {
@@ -255,9 +398,20 @@ func (g *Gossiper) GetName() string {
 }
 
 func (g *Gossiper) SetName(name string) {
+	g.keyStore.Bind(name, g.identity.PublicKey())
 	g.name.Store(name)
 }
 
+// hex of the key, other gossipers check messages of this gossiper with it
+func (g *Gossiper) GetPublicKey() string {
+	return hex.EncodeToString(g.identity.PublicKey())
+}
+
+// origin -> hex of its public key, both pinned and learned
+func (g *Gossiper) GetKnownKeys() map[string]string {
+	return g.keyStore.GetKeysCopy()
+}
+
 func (g *Gossiper) GetID(name string) uint32 {
 	return g.messageStorage.GetNextMessageId(name)
 }
@@ -270,6 +424,16 @@ func (g *Gossiper) GetClientAddress() *UDPAddr {
 	return g.clientAddress
 }
 
+// directory, where files to share are taken from
+func (g *Gossiper) GetSharedFilesPath() string {
+	return g.sharedFilesManager.GetSharedFilesPath()
+}
+
+// directory, where downloaded files are saved
+func (g *Gossiper) GetDownloadsPath() string {
+	return g.downloadingFilesManager.GetDownloadsPath()
+}
+
 func (g *Gossiper) GetRumorMessages() *[]RumorMessage {
 	return g.messageStorage.GetRumorMessagesCopy()
 }
@@ -278,137 +442,228 @@ func (g *Gossiper) GetPrivateMessages() *[]PrivateMessage {
 	return g.messageStorage.GetPrivateMessagesCopy()
 }
 
-func (g *Gossiper) updateNextHop(origin string, relay *UDPAddr) {
-	g.nextHopMux.Lock()
-	defer g.nextHopMux.Unlock()
-	addr, ok := g.nextHop[origin]
-	if !ok || ok && addr.String() != relay.String() {
-		fmt.Println("DSDV " + origin + " " + relay.String())
-		g.nextHop[origin] = relay
+// neighbour address -> its authenticated name, for neighbours with secure links
+func (g *Gossiper) GetLinks() map[string]string {
+	return g.links.GetLinksCopy()
+}
+
+// peer address -> version and capabilities of the peer, for peers, which sent hello
+func (g *Gossiper) GetPeersCapabilities() map[string]PeerCapabilities {
+	return g.capabilities.GetPeersCopy()
+}
+
+// peer address -> depth and counters of the queue of packets to this peer
+func (g *Gossiper) GetSendQueuesStats() map[string]QueueStats {
+	return g.peerMessagesToSend.GetStats()
+}
+
+// rumor is an advertisement of the route to its origin through the relay
+func (g *Gossiper) updateNextHop(rmsg *RumorMessage, relay *UDPAddr) {
+	if rmsg.OriginalName == g.name.Load().(string) {
+		return // own rumor came back
+	}
+	if g.routingTable.Update(rmsg.OriginalName, relay, rmsg.ID, rmsg.HopCount) {
+		g.events.Publish(RouteEvent{Origin: rmsg.OriginalName, NextHop: relay, HopCount: rmsg.HopCount})
 	}
 }
 
-func (g *Gossiper) sendPacketWithNextHop(origin string, gp *GossipPacket) {
-	g.nextHopMux.Lock()
-	defer g.nextHopMux.Unlock()
-	if g.nextHop[origin] != nil {
-		g.l.Debug("sending packet with next hop to "+origin+", next hop appeared to be: "+g.nextHop[origin].String()+" whole map is ", g.nextHop)
-		agp := &AddressedGossipPacket{Address: g.nextHop[origin], Packet: gp}
-		peerMessagesToSend <- agp
-	} else {
-		g.l.Warn("unable to send packet, because unknown origin in nextHop function")
+// sends by the best alive route, which doesn't lead back to previousHop (nil for packets of this gossiper), so that
+// packets don't loop between neighbours with stale routes. Returns next hop used, so that caller can mark the route
+// failed, if reply doesn't come. Returns error, if packet is dropped, because no route is known
+func (g *Gossiper) sendPacketWithNextHop(origin string, gp *GossipPacket, previousHop *UDPAddr) (*UDPAddr, error) {
+	nextHop, err := g.routingTable.GetNextHop(origin, previousHop)
+	if err != nil {
+		g.l.Warn("unable to send packet: " + err.Error())
+		g.events.Publish(UnroutableEvent{Destination: origin, Reason: err.Error()})
+		return nil, err
 	}
+	g.l.Debug("sending packet with next hop to " + origin + ", next hop appeared to be: " + nextHop.String())
+	agp := &AddressedGossipPacket{Address: nextHop, Packet: gp}
+	g.sendToPeer(agp)
+	return nextHop, nil
+}
+
+// reply to the packet sent through the next hop didn't come, so next packets to the origin go by an alternative route
+func (g *Gossiper) markRouteFailed(origin string, nextHop *UDPAddr) {
+	g.routingTable.MarkRouteFailed(origin, nextHop)
+	g.events.Publish(RouteFailedEvent{Origin: origin, NextHop: nextHop})
 }
 
 // client-reader thread
-func (g *Gossiper) StartClientReader() {
+func (g *Gossiper) startClientReader() {
 	g.l.Info("starting reading bytes on client: " + g.clientAddress.String() + " thread")
 
 	for {
-		buffer := make([]byte, MaxPacketSize)
-		g.clientConnection.ReadFromUDP(buffer)
+		buffer := make([]byte, MaxPacketSize+1) // one more byte to notice datagrams, which don't fit
+		n, _, err := g.clientConnection.ReadFromUDP(buffer)
+		if err != nil {
+			if g.ctx.Err() != nil {
+				g.l.Info("client connection is closed, client-reader thread finishes")
+				return
+			}
+			g.l.Warn("error when reading from client connection: " + err.Error())
+			continue
+		}
+		if n > MaxPacketSize {
+			g.l.Warn("client message is larger than " + strconv.Itoa(MaxPacketSize) + " bytes and is truncated, dropping it")
+			continue
+		}
 
 		cmsg := &ClientMessage{}
-		if err := protobuf.Decode(buffer, cmsg); err != nil {
+		if err := protobuf.Decode(buffer[:n], cmsg); err != nil {
 			// todo(atukallo): fix some protobuf warnings
 			g.l.Warn("unable to decode message, error: " + err.Error())
 		}
 
 		// ~~~ put into channel ~~~
 		g.l.Debug("put client message into channel")
-		clientMessagesToProcess <- cmsg
+		select {
+		case g.clientMessagesToProcess <- cmsg:
+		case <-g.ctx.Done():
+			return
+		}
 		// ~~~~~~~~~~~~~~~~~~~~~~~~
 	}
 }
 
 // peer-reader thread
-func (g *Gossiper) StartPeerReader() {
+func (g *Gossiper) startPeerReader() {
 	g.l.Info("gossiper " + g.name.Load().(string) + ": starting reading bytes on peer: " + g.peersAddress.String() + " in new thread")
 
 	for {
-		buffer := make([]byte, MaxPacketSize)
+		buffer := make([]byte, MaxPacketSize+1) // one more byte to notice datagrams, which don't fit
 		// here was a terrible bug
 		// before buffer was initalized outside the for-loop, then very strange problems were encountered
 		// thanks to akashuba it was understood, that protobuf, when doing Decoding, saves in inner structures
 		// pointers to buffer. Eg structures somewhere have slices and these slices are not copies of buffer, but pointers
 		// to buffer. As a result, with new message arriving, all the previous structures broke down. Now buffer
 		// is initialized inside and every iteration it's a new variable, which will never be spoiled later
-		_, addr, _ := g.peersConnection.ReadFromUDP(buffer)
+		n, addr, err := g.peersTransport.ReadFrom(buffer)
+		if err != nil {
+			if g.ctx.Err() != nil {
+				g.l.Info("peers connection is closed, peer-reader thread finishes")
+				return
+			}
+			g.l.Warn("error when reading from peers connection: " + err.Error())
+			continue
+		}
+		if n > MaxPacketSize {
+			// senders split large packets into fragments, so it's either broken or malicious peer
+			g.l.Warn("datagram from " + addr.String() + " is larger than " + strconv.Itoa(MaxPacketSize) + " bytes and is truncated, dropping it")
+			continue
+		}
 
 		gp := &GossipPacket{}
-		if err := protobuf.Decode(buffer, gp); err != nil {
+		if err := protobuf.Decode(buffer[:n], gp); err != nil {
 			// todo(atukallo): fix some protobuf warnings
 			g.l.Warn("unable to decode message, error: " + err.Error())
 		}
 
-		apg := &AddressedGossipPacket{Address: addr, Packet: gp}
+		if g.secureLinks {
+			gp = g.unsealPacket(gp, addr)
+			if gp == nil {
+				continue // either handshake or rejected datagram
+			}
+		}
 
-		// ~~~ put into channel ~~~
-		g.l.Debug("put peer message into channel")
-		peerMessagesToProcess <- apg
-		// ~~~~~~~~~~~~~~~~~~~~~~~~
-	}
-}
+		if gp.Fragment != nil {
+			packetBytes := g.reassembler.AddFragment(addr.String(), gp.Fragment)
+			if packetBytes == nil {
+				continue // waiting for other fragments
+			}
 
-// peer-writer thread
-func (g *Gossiper) StartPeerWriter() {
-	g.l.Info("starting peer writer thread")
+			gp = &GossipPacket{}
+			if err := protobuf.Decode(packetBytes, gp); err != nil {
+				g.l.Warn("unable to decode reassembled message, error: " + err.Error())
+				continue
+			}
+			if gp.Fragment != nil {
+				g.l.Warn("reassembled packet is a fragment itself, dropping it")
+				continue
+			}
+		}
 
-	for {
-		// ~~~ read from channel ~~~
-		agp := <-peerMessagesToSend
-		// ~~~~~~~~~~~~~~~~~~~~~~~~~
+		packets := []*GossipPacket{gp}
+		if gp.Batch != nil {
+			packets = g.unpackBatch(gp.Batch, addr)
+		}
 
-		address := agp.Address
-		gp := agp.Packet
+		for _, packet := range packets {
+			apg := &AddressedGossipPacket{Address: addr, Packet: packet}
 
-		packetBytes, err := protobuf.Encode(gp)
-		if err != nil {
-			g.l.Error("unable to encode msg: " + err.Error())
-			continue
+			// ~~~ put into channel ~~~
+			g.l.Debug("put peer message into channel")
+			select {
+			case g.peerMessagesToProcess <- apg:
+			case <-g.ctx.Done():
+				return
+			}
+			// ~~~~~~~~~~~~~~~~~~~~~~~~
 		}
+	}
+}
 
-		g.l.Debug("sending message from " + g.peersConnection.LocalAddr().String() + " to " + address.String())
-
-		n, err := g.peersConnection.WriteToUDP(packetBytes, address)
-		if err != nil {
-			g.l.Error("error when writing to connection: " + err.Error() + " n is " + strconv.Itoa(n))
+// called only by peer-reader thread, returns packets of the batch, which can be processed
+func (g *Gossiper) unpackBatch(batch *Batch, address *UDPAddr) []*GossipPacket {
+	packets := make([]*GossipPacket, 0, len(batch.Packets))
+	for _, packet := range batch.Packets {
+		if packet == nil || packet.Batch != nil || packet.Fragment != nil {
+			g.l.Warn("batch from " + address.String() + " contains malformed packet, skipping it")
 			continue
 		}
+		packets = append(packets, packet)
 	}
+
+	g.l.Debug("got batch of " + strconv.Itoa(len(packets)) + " packets from " + address.String())
+	return packets
 }
 
 // anti-entropy thread
-func (g *Gossiper) StartAntiEntropyTimer() {
+func (g *Gossiper) startAntiEntropyTimer() {
 	g.l.Info("starting anti-entropy timer thread")
 
 	for {
-		time.Sleep(AntiEntropyTimeout)
+		if !g.sleep(AntiEntropyTimeout) {
+			return
+		}
 
 		if g.arePeersEmpty() {
-			time.Sleep(AntiEntropyTimeout * 5) // wait additional time
+			// wait additional time
+			if !g.sleep(AntiEntropyTimeout * 5) {
+				return
+			}
 			continue
 		}
 
 		// send status to a random peer
 		peer := g.getRandomPeer()
-		peerMessagesToSend <- &AddressedGossipPacket{Address: peer, Packet: &GossipPacket{Status: g.messageStorage.GetCurrentStatusPacket()}}
+		if peer == nil {
+			continue // last peer died just now
+		}
+		g.greetPeer(peer) // if previous hello was lost
+		g.sendToPeer(&AddressedGossipPacket{Address: peer, Packet: &GossipPacket{Status: g.getStatusFor(peer)}})
 	}
 }
 
-func (g *Gossiper) StartMiningThread() {
+func (g *Gossiper) startMiningThread() {
 	g.l.Info("starting mining thread")
 
 	for {
 		g.l.Info("starting mining new block..")
-		newblock, timeMining := g.blockchainManager.DoMining()
+		newblock, timeMining := g.blockchainManager.DoMining(g.ctx)
+		if newblock == nil {
+			g.l.Info("mining is interrupted, mining thread finishes")
+			return
+		}
+
+		sleepTime := 2 * timeMining // just for a homework to increase number of blocks & forks, sleep before publishing the block and continuing
 		if g.blockchainManager.GetBlockNumber() == 1 {
 			// then mined block is the first one, hw asks us to sleep more (for fun with pending blocks)
 			g.l.Info("sleeping more after first block is mined")
-			time.Sleep(5 * time.Second)
-		} else {
-			// just for a homework to increase number of blocks & forks, sleep before publishing the block and continuing
-			time.Sleep(2 * timeMining)
+			sleepTime = 5 * time.Second
+		}
+		if !g.sleep(sleepTime) {
+			return
 		}
 
 		gp := &GossipPacket{BlockPublish: &BlockPublish{Block: *newblock, HopLimit: BlockchainBlockPublishHopLimit}}
@@ -421,22 +676,21 @@ func (g *Gossiper) StartMiningThread() {
 // message-processor thread section:
 // --------------------------------------
 
-func (g *Gossiper) StartMessageProcessor() {
+func (g *Gossiper) startMessageProcessor() {
 	g.l.Info("starting message processor")
 
 	for {
 		select {
-		case cmsg := <-clientMessagesToProcess:
+		case <-g.ctx.Done():
+			g.l.Info("message processor finishes")
+			return
+		case cmsg := <-g.clientMessagesToProcess:
 			g.l.Debug("got client message from channel")
-			if cmsg.Print() {
-				g.printPeers() // if printed something
-			}
+			g.events.Publish(ClientMessageEvent{Message: cmsg, Peers: g.GetPeersCopy()})
 			g.processClientMessage(cmsg)
-		case agp := <-peerMessagesToProcess:
+		case agp := <-g.peerMessagesToProcess:
 			g.l.Debug("got peer message from channel")
-			if agp.Print() {
-				g.printPeers() // if printed something
-			}
+			g.events.Publish(PacketEvent{From: agp.Address, Packet: agp.Packet, Peers: g.GetPeersCopy()})
 			g.processAddressedGossipPacket(agp)
 		}
 	}
@@ -448,11 +702,13 @@ func (g *Gossiper) processClientMessage(cmsg *ClientMessage) {
 		g.l.Info("got client rumor message: " + cmsg.Rumor.Text)
 		if g.isSimpleMode {
 			g.l.Info("mode is simple, so client rumor message is distributed as simple one to everyone")
-			smsg := &SimpleMessage{Text: cmsg.Rumor.Text, OriginalName: gossiperName}
+			g.simpleID++
+			smsg := &SimpleMessage{Text: cmsg.Rumor.Text, OriginalName: gossiperName, ID: g.simpleID, HopLimit: SimpleHopLimit}
 			g.processAddressedSimpleMessage(smsg, nil)
 		} else {
 			messageId := g.messageStorage.GetNextMessageId(gossiperName)
 			rmsg := &RumorMessage{OriginalName: gossiperName, ID: messageId, Text: cmsg.Rumor.Text}
+			g.signRumor(rmsg)
 			g.processRumorMessage(rmsg)
 		}
 	} else if cmsg.RouteRumor != nil {
@@ -463,11 +719,16 @@ func (g *Gossiper) processClientMessage(cmsg *ClientMessage) {
 		}
 		messageId := g.messageStorage.GetNextMessageId(gossiperName)
 		rmsg := &RumorMessage{OriginalName: gossiperName, ID: messageId, Text: ""} // distributing message with empty text
+		g.signRumor(rmsg)
 		g.processRumorMessage(rmsg)
 	} else if cmsg.Private != nil {
 		g.l.Info("got client private message")
 		pmsg := &PrivateMessage{Origin: gossiperName, ID: 0, Text: cmsg.Private.Text, Destination: cmsg.Private.Destination, HopLimit: DefaultHopLimit}
-		g.processPrivateMessage(pmsg)
+		if g.encryptPrivate && !g.encryptPrivateText(pmsg) {
+			return
+		}
+		g.signPrivate(pmsg)
+		g.processPrivateMessage(pmsg, nil)
 	} else if cmsg.ToShare != nil {
 		g.l.Info("got client to share message")
 		g.processClientToShare(cmsg.ToShare)
@@ -477,6 +738,9 @@ func (g *Gossiper) processClientMessage(cmsg *ClientMessage) {
 	} else if cmsg.ToSearch != nil {
 		g.l.Info("got client to search message")
 		g.processClientSearchRequest(cmsg.ToSearch)
+	} else if cmsg.ToProbe != nil {
+		g.l.Info("got client to probe message")
+		g.processClientToProbe(cmsg.ToProbe)
 	}
 }
 
@@ -484,7 +748,15 @@ func (g *Gossiper) processAddressedGossipPacket(agp *AddressedGossipPacket) {
 	gp := agp.Packet
 	address := agp.Address
 
+	// hello is answered before the peer is added, so that new peer is not greeted twice
+	if gp.Hello != nil {
+		g.l.Info("got hello from " + address.String())
+		g.processAddressedHello(gp.Hello, address)
+	}
 	g.UpdatePeersIfNeeded(address)
+	g.routingTable.MarkHeardFrom(address)
+	g.markAlive(address)
+	g.peerStore.MarkSeen(address.String())
 
 	if gp.Rumor != nil {
 		g.l.Info("got rumor-msg " + gp.Rumor.String() + " from " + address.String())
@@ -492,6 +764,9 @@ func (g *Gossiper) processAddressedGossipPacket(agp *AddressedGossipPacket) {
 	} else if gp.Status != nil {
 		g.l.Info("got status from " + address.String())
 		g.processAddressedStatusPacket(gp.Status, address)
+	} else if gp.RumorBundle != nil {
+		g.l.Info("got bundle of " + strconv.Itoa(len(gp.RumorBundle.Rumors)) + " rumors from " + address.String())
+		g.processAddressedRumorBundle(gp.RumorBundle, address)
 	} else if gp.Simple != nil {
 		g.l.Info("got simple from " + address.String())
 		g.processAddressedSimpleMessage(gp.Simple, address)
@@ -510,6 +785,21 @@ func (g *Gossiper) processAddressedGossipPacket(agp *AddressedGossipPacket) {
 	} else if gp.SearchReply != nil {
 		g.l.Info("got search reply message")
 		g.processAddressedSearchReply(gp.SearchReply, address)
+	} else if gp.Ping != nil {
+		g.l.Info("got ping from " + address.String())
+		g.processAddressedPing(gp.Ping, address)
+	} else if gp.Pong != nil {
+		g.l.Info("got pong from " + address.String())
+		g.processAddressedPong(gp.Pong, address)
+	} else if gp.Traceroute != nil {
+		g.l.Info("got traceroute from " + address.String())
+		g.processAddressedTraceroute(gp.Traceroute, address)
+	} else if gp.Liveness != nil {
+		g.l.Debug("got liveness from " + address.String())
+		g.processAddressedLiveness(gp.Liveness, address)
+	} else if gp.PeerExchange != nil {
+		g.l.Info("got peer exchange from " + address.String())
+		g.processAddressedPeerExchange(gp.PeerExchange, address)
 	} else if gp.TxPublish != nil {
 		g.l.Info("got tx publish message")
 		g.processTxPublish(gp.TxPublish)
@@ -520,64 +810,98 @@ func (g *Gossiper) processAddressedGossipPacket(agp *AddressedGossipPacket) {
 }
 
 func (g *Gossiper) processAddressedSimpleMessage(smsg *SimpleMessage, address *UDPAddr) {
+	key := smsg.OriginalName + "#" + strconv.FormatUint(uint64(smsg.ID), 10)
+	if smsg.ID == 0 {
+		key += "#" + smsg.Text
+	}
+	if !g.seenSimple.MarkSeen(key) {
+		g.l.Debug("dropping already relayed simple " + key)
+		return
+	}
+
+	// received message was already published as event, so it's not changed, but copied
+	relayed := *smsg
+	relayed.RelayPeerAddr = g.peersAddress.String()
+	if address != nil {
+		if smsg.ID == 0 && smsg.HopLimit == 0 {
+			// message of older gossiper, it's limited from the first relay, which knows about hop limits
+			relayed.HopLimit = SimpleHopLimit
+		}
+		if relayed.HopLimit <= 1 {
+			g.l.Debug("hop limit of simple " + key + " is exhausted")
+			return
+		}
+		relayed.HopLimit--
+	}
+
 	g.peersSliceMux.Lock()
 	defer g.peersSliceMux.Unlock()
 
-	smsg.RelayPeerAddr = g.peersConnection.LocalAddr().String()
-
 	for _, peer := range g.peers {
 		if address != nil && peer.String() == address.String() {
 			continue
 		}
 		g.l.Info("sending simple to " + peer.String())
-		peerMessagesToSend <- &AddressedGossipPacket{Address: peer, Packet: &GossipPacket{Simple: smsg}}
+		g.sendToPeer(&AddressedGossipPacket{Address: peer, Packet: &GossipPacket{Simple: &relayed}})
 	}
 }
 
 func (g *Gossiper) processAddressedStatusPacket(sp *StatusPacket, address *UDPAddr) {
-	statusesChannelsMux.Lock()
-	if val, isPresent := statusesChannels[address.String()]; isPresent {
-		g.l.Info("status from " + address.String() + " found in map, forwarding it to corresponding goroutine...")
-		val <- sp
-		delete(statusesChannels, address.String()) // goroutine cannot eat more, than one status packet, so delete it from map
-		statusesChannelsMux.Unlock()
+	if g.mongeringSessions.Deliver(address.String(), sp) {
+		g.l.Info("status from " + address.String() + " is forwarded to rumor-mongering goroutine")
 		return
 	}
-	statusesChannelsMux.Unlock()
 
-	g.l.Info("got status not from map, interesting")
+	g.l.Info("got status, which no rumor-mongering goroutine waits for, interesting")
+	if sp.IsDigest() {
+		if g.processStatusDigest(sp, address) {
+			g.l.Info("nothing interesting in the digest")
+			g.events.Publish(InSyncEvent{Peer: address})
+		}
+		return
+	}
+	if g.sendRumorBundle(g.messageStorage.GetMissing(sp), address) {
+		return
+	}
 	rmsg, otherHasSomethingNew := g.messageStorage.Diff(sp)
 	if rmsg != nil {
 		g.spreadTheRumor(rmsg, address)
 	} else if otherHasSomethingNew {
-		peerMessagesToSend <- &AddressedGossipPacket{Packet: &GossipPacket{Status: g.messageStorage.GetCurrentStatusPacket()}, Address: address}
+		g.sendToPeer(&AddressedGossipPacket{Packet: &GossipPacket{Status: g.getStatusFor(address)}, Address: address})
 	} else {
 		g.l.Info("nothing interesting in the status")
-		fmt.Println("IN SYNC WITH " + address.String())
+		g.events.Publish(InSyncEvent{Peer: address})
 	}
 	// else do nothing at all
 }
 
 func (g *Gossiper) processAddressedRumorMessage(rmsg *RumorMessage, address *UDPAddr) {
+	if !g.isRumorAuthentic(rmsg, address) {
+		return // rumorer will not get status and will think, that packet is lost
+	}
 
 	// send status back to rumorer:
-	feedbackStatus := &GossipPacket{Status: g.messageStorage.GetCurrentStatusPacket()}
+	feedbackStatus := &GossipPacket{Status: g.getStatusFor(address)}
 	addressedFeedbackStatus := &AddressedGossipPacket{Address: address, Packet: feedbackStatus}
 	g.l.Info("sending status as feedback to " + address.String())
-	peerMessagesToSend <- addressedFeedbackStatus
+	g.sendToPeer(addressedFeedbackStatus)
 
-	if g.messageStorage.IsNewMessage(rmsg) {
-		// update next hop data
-		g.updateNextHop(rmsg.OriginalName, address)
-	}
+	// received rumor was already published as event, so it's not changed, but copied
+	relayed := *rmsg
+	relayed.HopCount++ // counting the hop to this gossiper
+
+	// even old rumors are advertisements, they can bring shorter route
+	g.updateNextHop(&relayed, address)
 
-	g.processRumorMessage(rmsg)
+	g.processRumorMessage(&relayed)
 }
 
 func (g *Gossiper) processRumorMessage(rmsg *RumorMessage) {
-	isNewMessage := g.messageStorage.AddRumorMessage(rmsg)
+	// rumor, which fills a gap, brings the buffered rumors, which followed it. Routes were updated with every
+	// one of them, when it came
+	added := g.messageStorage.AddRumorMessage(rmsg)
 
-	if isNewMessage {
+	if len(added) > 0 {
 		// rumormongering -- choose random peer to send rmsg to
 		// can do optimization of not sending rumour to its sender, but it's not that necessary
 		if g.arePeersEmpty() {
@@ -585,7 +909,9 @@ func (g *Gossiper) processRumorMessage(rmsg *RumorMessage) {
 			return // msg came from client and no peers are known
 		}
 
-		g.spreadTheRumor(rmsg, nil)
+		for _, addedRmsg := range added {
+			g.spreadTheRumor(addedRmsg, nil)
+		}
 	} else {
 		// if we got an old rumor, then let's do nothing, we already have sent a feedback, everything is good
 		g.l.Info("message is not new, skipping it")
@@ -595,14 +921,24 @@ func (g *Gossiper) processRumorMessage(rmsg *RumorMessage) {
 func (g *Gossiper) processAddressedPrivateMessage(pmsg *PrivateMessage, address *UDPAddr) {
 	//  commented in order to have all announcements from rumor message and to pass tests, may uncomment, really not that important
 	//g.updateNextHop(pmsg.Origin, address)
-	g.processPrivateMessage(pmsg)
+	if !g.isPrivateAuthentic(pmsg, address) {
+		return // forged messages are not forwarded too
+	}
+	g.processPrivateMessage(pmsg, address)
 }
 
 // in functions below copy-paste is present, but moving it to abstract functions is too resource-taking
-func (g *Gossiper) processPrivateMessage(pmsg *PrivateMessage) {
+// address is nil, if message is sent by this gossiper
+func (g *Gossiper) processPrivateMessage(pmsg *PrivateMessage, address *UDPAddr) {
 	gossiperName := g.name.Load().(string)
 
 	if pmsg.Destination == gossiperName {
+		if pmsg.IsEncrypted() {
+			pmsg = g.decryptPrivateText(pmsg)
+			if pmsg == nil {
+				return
+			}
+		}
 		g.messageStorage.AddPrivateMessage(pmsg, gossiperName)
 		return
 	}
@@ -612,16 +948,16 @@ func (g *Gossiper) processPrivateMessage(pmsg *PrivateMessage) {
 	}
 
 	pmsg.HopLimit = pmsg.HopLimit - 1
-	g.sendPacketWithNextHop(pmsg.Destination, &GossipPacket{Private: pmsg})
+	g.sendPacketWithNextHop(pmsg.Destination, &GossipPacket{Private: pmsg}, address)
 }
 
 func (g *Gossiper) processAddressedDataRequest(drqmsg *DataRequest, address *UDPAddr) {
 	//g.updateNextHop(drqmsg.Origin, address)
-	g.processDataRequest(drqmsg)
+	g.processDataRequest(drqmsg, address)
 }
 
 // gossiper answers with either (shared file data) or (download(ing|ed) file data)
-func (g *Gossiper) processDataRequest(drqmsg *DataRequest) {
+func (g *Gossiper) processDataRequest(drqmsg *DataRequest, address *UDPAddr) {
 	gossiperName := g.name.Load().(string)
 
 	if drqmsg.Destination == gossiperName {
@@ -636,7 +972,7 @@ func (g *Gossiper) processDataRequest(drqmsg *DataRequest) {
 
 		g.l.Info("answered to data request from " + drqmsg.Origin + " with chunk/metafile")
 		drpmsg := &DataReply{HashValue: drqmsg.HashValue, Origin: gossiperName, Destination: drqmsg.Origin, HopLimit: DefaultHopLimit, Data: requestedData}
-		g.sendPacketWithNextHop(drpmsg.Destination, &GossipPacket{DataReply: drpmsg})
+		g.sendPacketWithNextHop(drpmsg.Destination, &GossipPacket{DataReply: drpmsg}, nil)
 		return
 	}
 
@@ -646,15 +982,15 @@ func (g *Gossiper) processDataRequest(drqmsg *DataRequest) {
 	}
 
 	drqmsg.HopLimit = drqmsg.HopLimit - 1
-	g.sendPacketWithNextHop(drqmsg.Destination, &GossipPacket{DataRequest: drqmsg})
+	g.sendPacketWithNextHop(drqmsg.Destination, &GossipPacket{DataRequest: drqmsg}, address)
 }
 
 func (g *Gossiper) processAddressedDataReply(drpmsg *DataReply, address *UDPAddr) {
 	//g.updateNextHop(drpmsg.Origin, address)
-	g.processDataReply(drpmsg)
+	g.processDataReply(drpmsg, address)
 }
 
-func (g *Gossiper) processDataReply(drpmsg *DataReply) {
+func (g *Gossiper) processDataReply(drpmsg *DataReply, address *UDPAddr) {
 	gossiperName := g.name.Load().(string)
 
 	if drpmsg.Destination != gossiperName {
@@ -664,16 +1000,19 @@ func (g *Gossiper) processDataReply(drpmsg *DataReply) {
 		}
 
 		drpmsg.HopLimit = drpmsg.HopLimit - 1
-		g.sendPacketWithNextHop(drpmsg.Destination, &GossipPacket{DataReply: drpmsg})
+		g.sendPacketWithNextHop(drpmsg.Destination, &GossipPacket{DataReply: drpmsg}, address)
 		return
 	}
 
 	// else msg addressed to this gossiper:
-	downloadingFilesChannelsMux.Lock()
-	defer downloadingFilesChannelsMux.Unlock()
+	g.downloadingFilesChannelsMux.Lock()
+	defer g.downloadingFilesChannelsMux.Unlock()
 
-	if ch, ok := downloadingFilesChannels[drpmsg.Origin]; ok {
-		ch <- drpmsg
+	if ch, ok := g.downloadingFilesChannels[drpmsg.Origin]; ok {
+		select {
+		case ch <- drpmsg:
+		case <-g.ctx.Done():
+		}
 	} else {
 		g.l.Warn("we are downloading nothing from this host! (the host is not present in the map)")
 	}
@@ -692,13 +1031,13 @@ func (g *Gossiper) processAddressedSearchRequest(srqmsg *SearchRequest, address
 
 	// else start processing & schedule removing from map
 	g.recentSearchRequests[setkey] = true
-	go func() {
+	g.spawn(func() {
 		// search-request-timeout function
-		time.Sleep(RecentSearchRequestTimeout)
+		g.sleep(RecentSearchRequestTimeout) // even if gossiper is stopped, clean the map
 		g.recentSearchRequestsMux.Lock()
 		delete(g.recentSearchRequests, setkey)
 		g.recentSearchRequestsMux.Unlock()
-	}()
+	})
 	g.recentSearchRequestsMux.Unlock()
 
 	//g.updateNextHop(srqmsg.Origin, address)
@@ -715,7 +1054,7 @@ func (g *Gossiper) processAddressedSearchReply(srpmsg *SearchReply, address *UDP
 		}
 
 		srpmsg.HopLimit = srpmsg.HopLimit - 1
-		g.sendPacketWithNextHop(srpmsg.Destination, &GossipPacket{SearchReply: srpmsg})
+		g.sendPacketWithNextHop(srpmsg.Destination, &GossipPacket{SearchReply: srpmsg}, address)
 		return
 	}
 
@@ -780,26 +1119,26 @@ func (g *Gossiper) processClientDataRequest(cdrqmsg *ClientToDownloadMessage) {
 		return
 	}
 
-	downloadingFilesChannelsMux.Lock() // if locking later, rc is introduced
+	g.downloadingFilesChannelsMux.Lock() // if locking later, rc is introduced
 	if !g.downloadingFilesManager.StartDownloadingFromOrigin(origin, cdrqmsg.Name, cdrqmsg.HashValue) {
 		g.l.Error("cannot start downloading, because downloading from this peer is already in progress")
-		downloadingFilesChannelsMux.Unlock()
+		g.downloadingFilesChannelsMux.Unlock()
 		return
 	}
 
-	if _, ok := downloadingFilesChannels[origin]; ok {
+	if _, ok := g.downloadingFilesChannels[origin]; ok {
 		g.l.Error("whaaat, map is not clean!") // we trust dfm more
 	}
-	downloadingFilesChannels[origin] = make(chan *DataReply)
-	downloadingFilesChannelsMux.Unlock()
+	g.downloadingFilesChannels[origin] = make(chan *DataReply)
+	g.downloadingFilesChannelsMux.Unlock()
 
 	// send first data request and start file-downloading goroutine
 	g.l.Info("starting file-downloading goroutine & requesting metafile from " + origin + " for file " + cdrqmsg.Name)
 	drqmsg := &DataRequest{Destination: origin, HopLimit: DefaultHopLimit, HashValue: cdrqmsg.HashValue[:], Origin: g.name.Load().(string)}
 	gp := &GossipPacket{DataRequest: drqmsg}
-	g.sendPacketWithNextHop(origin, gp)
+	nextHop, _ := g.sendPacketWithNextHop(origin, gp, nil) // if no route is known now, request is resent after timeout
 
-	go g.startFileDownloadingGoroutine(origin, cdrqmsg.HashValue[:])
+	g.spawn(func() { g.startFileDownloadingGoroutine(origin, cdrqmsg.HashValue[:], nextHop) })
 }
 
 func (g *Gossiper) processClientSearchRequest(csrqmsg *ClientToSearchMessage) {
@@ -811,9 +1150,9 @@ func (g *Gossiper) processClientSearchRequest(csrqmsg *ClientToSearchMessage) {
 	g.currentSearchRequest = nil // feed gc with new victim
 
 	ch := make(chan *SearchReply)
-	g.currentSearchRequest = InitCurrentSearchRequest(ch, g.clientAddress.Port, g.l)
+	g.currentSearchRequest = InitCurrentSearchRequest(ch, g.ctx.Done(), g.clientAddress.Port, g.l)
 
-	go g.startFileSearchingGoroutine(csrqmsg.Keywords, int(csrqmsg.Budget), ch)
+	g.spawn(func() { g.startFileSearchingGoroutine(csrqmsg.Keywords, int(csrqmsg.Budget), ch) })
 }
 
 // -------------------------------------------
@@ -826,23 +1165,23 @@ func (g *Gossiper) spreadTheRumor(rmsg *RumorMessage, peer *UDPAddr) {
 
 	if peer == nil {
 		peer = g.getRandomPeer()
+		if peer == nil {
+			g.l.Warn("no peers are known, cannot do rumor-mongering")
+			return
+		}
 	}
 
-	statusesChannelsMux.Lock()
-	if _, contains := statusesChannels[peer.String()]; contains {
-		g.l.Warn("rumormongering with this peer is already in progress, this case is too hard for me")
-		statusesChannelsMux.Unlock()
+	ch := g.mongeringSessions.Open(peer.String(), rmsg)
+	if ch == nil {
+		g.l.Warn("too many rumors are mongered to " + peer.String() + ", " + rmsg.String() + " is left to anti-entropy")
 		return
 	}
-	ch := make(chan *StatusPacket)
-	statusesChannels[peer.String()] = ch
-	statusesChannelsMux.Unlock()
 
 	g.l.Info("rumor sent further to " + peer.String())
-	peerMessagesToSend <- &AddressedGossipPacket{Address: peer, Packet: &GossipPacket{Rumor: rmsg}}
-	fmt.Println("MONGERING with " + peer.String())
+	g.sendToPeer(&AddressedGossipPacket{Address: peer, Packet: &GossipPacket{Rumor: rmsg}})
+	g.events.Publish(MongeringEvent{Peer: peer, Rumor: rmsg})
 
-	go g.startRumorMongeringThread(rmsg, ch, peer)
+	g.spawn(func() { g.startRumorMongeringThread(rmsg, ch, peer) })
 }
 
 // called both by message-processor & search-request goroutine
@@ -865,7 +1204,7 @@ func (g *Gossiper) processSearchRequest(srqmsg *SearchRequest) {
 		if len(matchedFiles) > 0 {
 			g.l.Info("got some matches on " + gossiperName + " for search-request: " + strings.Join(srqmsg.Keywords, ","))
 			gp := &GossipPacket{SearchReply: &SearchReply{Origin: gossiperName, Destination: srqmsg.Origin, HopLimit: DefaultHopLimit, Results: matchedFiles}}
-			g.sendPacketWithNextHop(srqmsg.Origin, gp)
+			g.sendPacketWithNextHop(srqmsg.Origin, gp, nil)
 		} else {
 			g.l.Info("got no matches on " + gossiperName + " for search-request: " + strings.Join(srqmsg.Keywords, ","))
 		}
@@ -880,6 +1219,10 @@ func (g *Gossiper) processSearchRequest(srqmsg *SearchRequest) {
 	// send search request further:
 	g.peersSliceMux.Lock()
 	defer g.peersSliceMux.Unlock()
+	if len(g.peers) == 0 {
+		g.l.Warn("no peers are known, search-request " + strings.Join(srqmsg.Keywords, ",") + " is not sent further")
+		return // all the neighbours can be evicted by liveness
+	}
 	residual := budget % len(g.peers)
 	for _, peer := range g.peers {
 		newbudget := budget / len(g.peers)
@@ -889,7 +1232,7 @@ func (g *Gossiper) processSearchRequest(srqmsg *SearchRequest) {
 		}
 
 		agp := &AddressedGossipPacket{Address: peer, Packet: &GossipPacket{SearchRequest: &SearchRequest{Budget: uint64(newbudget), Keywords: srqmsg.Keywords, Origin: srqmsg.Origin}}}
-		peerMessagesToSend <- agp
+		g.sendToPeer(agp)
 	}
 }
 
@@ -898,30 +1241,53 @@ func (g *Gossiper) processSearchRequest(srqmsg *SearchRequest) {
 // --------------------------------------
 
 func (g *Gossiper) startRumorMongeringThread(messageBeingRumored *RumorMessage, ch chan *StatusPacket, peer *UDPAddr) {
-	ticker := time.NewTicker(RumorTimeout)
+	timer := g.clock.NewTimer(RumorTimeout)
+	defer timer.Stop()
+
 	select {
-	case <-ticker.C:
+	case <-g.ctx.Done():
+		return
+	case <-timer.C():
 		g.l.Info("peer " + peer.String() + " exceeded the timeout")
+		if g.routingTable.MarkReplyMissed(peer) {
+			g.l.Info("peer " + peer.String() + " is silent, routes through it are not used")
+			g.events.Publish(NeighbourSilentEvent{Peer: peer})
+		}
 
-		// clean the map
-		statusesChannelsMux.Lock()
-		delete(statusesChannels, peer.String())
-		statusesChannelsMux.Unlock()
+		g.mongeringSessions.Close(peer.String(), ch)
 
 		g.flipRumorMongeringCoin(messageBeingRumored)
 	case statusPacket := <-ch:
 		g.l.Info("processing status-response in rumor-mongering thread from " + peer.String())
 
+		if g.mongeringSessions.Count(peer.String()) > 0 {
+			// peer doesn't have other rumors yet, because they are still on the way, their statuses continue
+			// synchronization, but this rumor is done with the peer and goes on as if peer were in sync
+			g.l.Info("other rumors are mongered to " + peer.String() + ", flipping the coin for " + messageBeingRumored.String())
+			g.flipRumorMongeringCoin(messageBeingRumored)
+			return
+		}
+		if statusPacket.IsDigest() {
+			if g.processStatusDigest(statusPacket, peer) {
+				g.events.Publish(InSyncEvent{Peer: peer})
+				g.l.Info("peer " + peer.String() + " has same info as me, flipping the coin")
+				g.flipRumorMongeringCoin(messageBeingRumored)
+			}
+			return // else digests or rumors sent to the peer continue synchronization
+		}
+		if g.sendRumorBundle(g.messageStorage.GetMissing(statusPacket), peer) {
+			return // status for the bundle continues synchronization
+		}
 		rmsg, otherHasSomethingNew := g.messageStorage.Diff(statusPacket)
 		if rmsg != nil {
 			g.l.Info("peer " + peer.String() + " doesn't know rmsg, sending it: " + rmsg.String())
 			g.spreadTheRumor(rmsg, peer)
 		} else if otherHasSomethingNew {
 			g.l.Info("peer " + peer.String() + " knows more, than me, sending status to him")
-			agp := &AddressedGossipPacket{Packet: &GossipPacket{Status: g.messageStorage.GetCurrentStatusPacket()}, Address: peer}
-			peerMessagesToSend <- agp
+			agp := &AddressedGossipPacket{Packet: &GossipPacket{Status: g.getStatusFor(peer)}, Address: peer}
+			g.sendToPeer(agp)
 		} else {
-			fmt.Println("IN SYNC WITH " + peer.String())
+			g.events.Publish(InSyncEvent{Peer: peer})
 			g.l.Info("peer " + peer.String() + " has same info as me, flipping the coin")
 			g.flipRumorMongeringCoin(messageBeingRumored)
 		}
@@ -933,8 +1299,11 @@ func (g *Gossiper) flipRumorMongeringCoin(messageBeingRumored *RumorMessage) {
 	continueMongering := (rand.Int() % 2) == 0
 	if continueMongering {
 		peer := g.getRandomPeer() // crutch because of fmt. requirements in HW1 :(
+		if peer == nil {
+			return
+		}
 		g.l.Info("coin says to continue rumor-mongering")
-		fmt.Println("FLIPPED COIN sending rumor to " + peer.String())
+		g.events.Publish(CoinFlippedEvent{Peer: peer, Rumor: messageBeingRumored})
 		g.spreadTheRumor(messageBeingRumored, peer)
 	} else {
 		g.l.Info("coin says to stop rumor-mongering of " + messageBeingRumored.String())
@@ -946,61 +1315,74 @@ func (g *Gossiper) flipRumorMongeringCoin(messageBeingRumored *RumorMessage) {
 // --------------------------------------
 
 // called only by file-downloading goroutines:
-func (g *Gossiper) startFileDownloadingGoroutine(origin string, latestRequestedHash []byte) {
-	downloadingFilesChannelsMux.Lock()
-	ch, ok := downloadingFilesChannels[origin]
+// nextHop is the one, through which the latest request was sent, nil if it wasn't sent
+func (g *Gossiper) startFileDownloadingGoroutine(origin string, latestRequestedHash []byte, nextHop *UDPAddr) {
+	g.downloadingFilesChannelsMux.Lock()
+	ch, ok := g.downloadingFilesChannels[origin]
 	if !ok {
 		g.l.Error("some terrible race condition occured, channel is not in the map! Downloading goroutine dies..")
+		g.downloadingFilesChannelsMux.Unlock()
 		return
 	}
-	downloadingFilesChannelsMux.Unlock()
+	g.downloadingFilesChannelsMux.Unlock()
 
-	ticker := time.NewTicker(FileDownloadReplyTimeout)
+	ticker := g.clock.NewTicker(FileDownloadReplyTimeout)
+	defer ticker.Stop()
 	timeoutsLimit := FileDownloadTimeoutsLimit
 
 	for {
 		select {
-		case <-ticker.C:
+		case <-g.ctx.Done():
+			g.l.Info("gossiper is stopped, dropping downloading from " + origin)
+			g.downloadingFilesChannelsMux.Lock()
+			delete(g.downloadingFilesChannels, origin)
+			g.downloadingFilesManager.DropDownloading(origin)
+			g.downloadingFilesChannelsMux.Unlock()
+			return
+		case <-ticker.C():
 			g.l.Debug("timeout in file-downloading shot")
 			timeoutsLimit = timeoutsLimit - 1
 			if timeoutsLimit <= 0 {
 				g.l.Error("timeout completely exceeded for receiving the file")
 
-				downloadingFilesChannelsMux.Lock()
-				delete(downloadingFilesChannels, origin)
+				g.downloadingFilesChannelsMux.Lock()
+				delete(g.downloadingFilesChannels, origin)
 				g.downloadingFilesManager.DropDownloading(origin)
-				downloadingFilesChannelsMux.Unlock()
+				g.downloadingFilesChannelsMux.Unlock()
 				return
 			}
 
-			// resend message we didn't receive reply for
+			// resend message we didn't receive reply for, by another route if there is one
+			if nextHop != nil {
+				g.markRouteFailed(origin, nextHop)
+			}
 			g.l.Info("resending msg because of timeout: DataRequest with hash: " + hex.EncodeToString(latestRequestedHash))
 			dataRequest := &DataRequest{Destination: origin, HopLimit: DefaultHopLimit, HashValue: latestRequestedHash, Origin: g.name.Load().(string)}
 			gp := &GossipPacket{DataRequest: dataRequest}
-			g.sendPacketWithNextHop(origin, gp)
+			nextHop, _ = g.sendPacketWithNextHop(origin, gp, nil)
 		case dataReplyPacket := <-ch:
 			g.l.Debug("got chunk/metafile from " + dataReplyPacket.Origin)
-			downloadingFilesChannelsMux.Lock() // locking to do removing from map & dfm synchronicaly
+			g.downloadingFilesChannelsMux.Lock() // locking to do removing from map & dfm synchronicaly
 			isFinished := g.downloadingFilesManager.ProcessDataReply(origin, dataReplyPacket)
 			if isFinished == nil {
 				g.l.Error("an error occured when downloading from the peer, try to request the same chunk/metafile once again, hash is: " + hex.EncodeToString(latestRequestedHash))
 				dataRequest := &DataRequest{Destination: origin, HopLimit: DefaultHopLimit, HashValue: latestRequestedHash, Origin: g.name.Load().(string)}
 				gp := &GossipPacket{DataRequest: dataRequest}
-				g.sendPacketWithNextHop(origin, gp)
-				downloadingFilesChannelsMux.Unlock()
+				nextHop, _ = g.sendPacketWithNextHop(origin, gp, nil)
+				g.downloadingFilesChannelsMux.Unlock()
 				break
 			}
 
 			if *isFinished {
 				g.l.Info("Great, downloading is finished from " + origin)
-				delete(downloadingFilesChannels, origin)
-				downloadingFilesChannelsMux.Unlock()
+				delete(g.downloadingFilesChannels, origin)
+				g.downloadingFilesChannelsMux.Unlock()
 				return
 			}
-			downloadingFilesChannelsMux.Unlock()
+			g.downloadingFilesChannelsMux.Unlock()
 
 			// if not finished, send new message and continue waiting..
-			ticker = time.NewTicker(FileDownloadReplyTimeout) // upd ticker not to shoot to early
+			ticker.Reset(FileDownloadReplyTimeout) // upd ticker not to shoot to early
 			timeoutsLimit = FileDownloadTimeoutsLimit
 
 			dataRequestHash := g.downloadingFilesManager.GetDataRequestHash(origin)
@@ -1013,7 +1395,7 @@ func (g *Gossiper) startFileDownloadingGoroutine(origin string, latestRequestedH
 			latestRequestedHash = dataRequestHash
 			dataRequest := &DataRequest{Destination: origin, HopLimit: DefaultHopLimit, HashValue: dataRequestHash, Origin: g.name.Load().(string)}
 			gp := &GossipPacket{DataRequest: dataRequest}
-			g.sendPacketWithNextHop(origin, gp)
+			nextHop, _ = g.sendPacketWithNextHop(origin, gp, nil)
 		}
 	}
 }
@@ -1028,11 +1410,15 @@ func (g *Gossiper) startFileSearchingGoroutine(keywords []string, budget int, ch
 		maxAllowedBudget = budget
 	}
 
-	ticker := time.NewTicker(1)
+	timer := g.clock.NewTimer(0) // first request is sent immediately
+	defer timer.Stop()
 
 	for {
 		select {
-		case <-ticker.C:
+		case <-g.ctx.Done():
+			g.currentSearchRequest.Shutdown()
+			return
+		case <-timer.C():
 			if budget > maxAllowedBudget {
 				if budget > maxAllowedBudget+1 {
 					g.l.Warn("reached max budget & didn't get enough full matches, leaving the function, defeated...")
@@ -1041,7 +1427,7 @@ func (g *Gossiper) startFileSearchingGoroutine(keywords []string, budget int, ch
 				}
 
 				g.l.Info("reached maximum budget, stopped sending search-requests, just wait a bit more for more answers to arrive...")
-				ticker = time.NewTicker(FileSearchReplyTimeout * 2)
+				timer.Reset(FileSearchReplyTimeout * 2)
 				budget++
 				continue
 			}
@@ -1052,7 +1438,7 @@ func (g *Gossiper) startFileSearchingGoroutine(keywords []string, budget int, ch
 			g.processSearchRequest(searchRequest)
 
 			budget++
-			ticker = time.NewTicker(FileSearchReplyTimeout)
+			timer.Reset(FileSearchReplyTimeout)
 		case dataReplyPacket := <-ch:
 			for _, res := range dataReplyPacket.Results {
 				// here a great question about task formulation comes. I consider only full matches significant.
@@ -1073,17 +1459,14 @@ func (g *Gossiper) startFileSearchingGoroutine(keywords []string, budget int, ch
 					g.l.Debug("got a partial match from " + dataReplyPacket.Origin + " on " + res.FileName + ", skipping it, see code for comment on why so is done")
 				}
 
-				// also do pretty-print:
-				fmt.Println("FOUND match " + res.FileName + " at " + dataReplyPacket.Origin +
-					" metafile=" + hex.EncodeToString(res.MetafileHash) +
-					" chunks=" + strings.Trim(strings.Join(strings.Fields(fmt.Sprint(res.ChunkMap)), ","), "[]"))
+				g.events.Publish(SearchMatchEvent{Origin: dataReplyPacket.Origin, Result: res})
 			}
 
 			if g.currentSearchRequest.GetFullMatchesNumber() >= FileSearchFullMatchesThreshold {
 				g.l.Info("reached threshold for search request: " + strings.Join(keywords, ",") + ", stopping the search-request goroutine")
 				//g.l.Info("initiating download process..")
 				//g.currentSearchRequest.DownloadAnyFile(g.name.Load().(string))
-				fmt.Println("SEARCH FINISHED")
+				g.events.Publish(SearchFinishedEvent{Keywords: keywords})
 				g.currentSearchRequest.Shutdown()
 				return
 			}
@@ -0,0 +1,40 @@
+package gossiper
+
+import (
+	. "github.com/SubutaiBogatur/Peerster/models/sending"
+	. "github.com/SubutaiBogatur/Peerster/transport"
+	"github.com/SubutaiBogatur/Peerster/utils/clock"
+)
+
+// everything gossiper is configured with, when created. Zero value of optional fields means default behaviour
+type GossiperOptions struct {
+	Name       string
+	UIPort     int    // gossiper listens for client on LocalIp:UIPort
+	GossipAddr string // ip:port, where gossiper listens for peers, ignored if Transport is given
+	Peers      string // other gossipers' addresses separated with "," in the form ip:port
+	Bootstrap  string // addresses as in Peers, used on start, if address book in DataDir has no usable neighbours, and when all peers are dead
+
+	IsSimpleMode    bool // in simple mode sending only simple messages
+	NoAntiEntropy   bool // if true, no regular status sending is done
+	RouteRumorTimer int  // route rumors sending period in seconds, 0 to disable
+	NoBatching      bool // if true, small packets to the same peer are not put into one datagram, even if peer understands batches
+	NoLiveness      bool // if true, neighbours are not probed and are never removed from peers
+	NoPeerExchange  bool // if true, gossiper neither asks nor answers neighbours about their neighbours
+	NoStatusDigests bool // if true, full vector clock is sent in every status, even to peers, which understand digests
+	TargetDegree    int  // peer exchange looks for new neighbours, until there are so many, DefaultTargetDegree if not positive
+
+	DataDir string // directory, where _SharedFiles and _Downloads are situated, "" for current directory
+
+	Transport Transport // transport to talk with peers, if nil, udp socket on GossipAddr is opened. Gossiper closes it, when stopped
+
+	Clock clock.Clock // all the protocol timeouts are measured with it, if nil, real clock is used
+
+	KeyFile   string // file with ed25519 key of the gossiper, created if doesn't exist. If "", new key is generated every run
+	TrustFile string // file with pinned keys of other origins, lines "{origin} {hex public key}", "" for none
+
+	EncryptPrivate bool // if true, private messages from client are encrypted for destination, so relays cannot read them
+	SecureLinks    bool // if true, neighbours are authenticated with handshake and datagrams with them are encrypted, all the neighbours should have it
+
+	SendQueueSize   int            // max packets of one traffic class waiting to be sent to one peer, DefaultSendQueueSize if not positive
+	SendQueuePolicy OverflowPolicy // what is dropped, when queue to the peer is full
+}
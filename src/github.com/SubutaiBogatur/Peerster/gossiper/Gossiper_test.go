@@ -0,0 +1,78 @@
+package gossiper
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/SubutaiBogatur/Peerster/config"
+	. "github.com/SubutaiBogatur/Peerster/models"
+	. "github.com/SubutaiBogatur/Peerster/transport"
+	"github.com/SubutaiBogatur/Peerster/utils/clock"
+	"github.com/dedis/protobuf"
+)
+
+// search-request comes, when liveness has evicted all the neighbours
+func TestSearchWithoutPeersIsNotSentFurther(t *testing.T) {
+	sn := NewSimulatedNetwork(1, clock.NewRealClock())
+	defer sn.Close()
+	st, err := sn.Listen("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := NewGossiper(GossiperOptions{Name: "a", Peers: "10.0.0.1:6000", Transport: st, DataDir: t.TempDir(), NoPeerExchange: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Stop()
+
+	if g.removePeer("10.0.0.1:6000") == nil || !g.arePeersEmpty() {
+		t.Fatal("peer is not removed")
+	}
+	g.processSearchRequest(&SearchRequest{Origin: "b", Budget: 4, Keywords: []string{"cat"}})
+	if g.peerMessagesToSend.Len() != 0 {
+		t.Fatal("search-request is sent without peers")
+	}
+}
+
+// hello of the peer hasn't come yet, so packet, which doesn't fit into datagram, is fragmented, not dropped
+func TestOversizePacketIsFragmentedBeforeHello(t *testing.T) {
+	sn := NewSimulatedNetwork(2, clock.NewRealClock())
+	defer sn.Close()
+	st, err := sn.Listen("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	peer, err := sn.Listen("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peer.Close()
+	g, err := NewGossiper(GossiperOptions{Name: "a", Peers: peer.LocalAddr().String(), Transport: st, DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Stop()
+
+	fragmented := make(chan bool, 1)
+	go func() {
+		buffer := make([]byte, MaxPacketSize)
+		for {
+			n, _, err := peer.ReadFrom(buffer)
+			if err != nil {
+				return
+			}
+			gp := &GossipPacket{}
+			if protobuf.Decode(buffer[:n], gp) == nil && gp.Fragment != nil {
+				fragmented <- true
+				return
+			}
+		}
+	}()
+	g.writePacket(make([]byte, MaxPacketSize+1), peer.LocalAddr())
+
+	select {
+	case <-fragmented:
+	case <-time.After(time.Second):
+		t.Fatal("packet is not fragmented for peer, whose hello hasn't come")
+	}
+}
@@ -0,0 +1,32 @@
+package gossiper
+
+import (
+	. "github.com/SubutaiBogatur/Peerster/events"
+	. "github.com/SubutaiBogatur/Peerster/models"
+	. "net"
+)
+
+// Peers exchange hellos on first contact, so that every gossiper knows version and capabilities of its neighbours
+// (see CapabilityTable). Older gossipers don't answer, then only features of the first version are used with them
+
+// sends hello to the peer, if it wasn't greeted yet or didn't answer. Never blocks, so can be called under locks
+func (g *Gossiper) greetPeer(peer *UDPAddr) {
+	if !g.capabilities.ShouldGreet(peer.String()) {
+		return
+	}
+
+	g.l.Debug("greeting " + peer.String())
+	g.sendToPeer(&AddressedGossipPacket{Address: peer, Packet: &GossipPacket{Hello: g.capabilities.GetOwnHello(false)}})
+}
+
+func (g *Gossiper) processAddressedHello(hello *Hello, address *UDPAddr) {
+	g.capabilities.Record(address.String(), hello)
+	g.events.Publish(HelloEvent{Peer: address, Version: hello.Version, Capabilities: hello.Capabilities})
+
+	if hello.IsReply {
+		return
+	}
+	// answer even known peer, it could be restarted and could forget about this gossiper
+	g.capabilities.MarkGreeted(address.String())
+	g.sendToPeer(&AddressedGossipPacket{Address: address, Packet: &GossipPacket{Hello: g.capabilities.GetOwnHello(true)}})
+}
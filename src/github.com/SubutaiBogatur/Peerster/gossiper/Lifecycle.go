@@ -0,0 +1,111 @@
+package gossiper
+
+import (
+	"context"
+	. "github.com/SubutaiBogatur/Peerster/models"
+	. "github.com/SubutaiBogatur/Peerster/utils"
+	"sync/atomic"
+	"time"
+)
+
+// gossiper states, gossiper can be run only once and cannot be restarted after stopping
+const (
+	gossiperCreated int32 = iota
+	gossiperRunning
+	gossiperStopped
+)
+
+// starts all the gossiper threads and blocks until either ctx is cancelled or Stop is called
+// when returns, all the goroutines of the gossiper are finished and both connections are closed
+func (g *Gossiper) Run(ctx context.Context) error {
+	if !atomic.CompareAndSwapInt32(&g.state, gossiperCreated, gossiperRunning) {
+		return PeersterError{ErrorMsg: "gossiper was already run or stopped"}
+	}
+	defer close(g.finished)
+
+	g.spawn(g.startClientReader)
+	g.spawn(g.startPeerReader)
+	g.spawn(g.startPeerWriter)
+	g.spawn(g.startMessageProcessor)
+	g.spawn(g.startMiningThread)
+	if !g.noAntiEntropy {
+		g.spawn(g.startAntiEntropyTimer)
+	}
+	g.spawn(g.startRouteRumorsSpreading)
+	if !g.noLiveness {
+		g.spawn(g.startLivenessProbing)
+	}
+	if !g.noPeerExchange {
+		g.spawn(g.startPeerExchange)
+	}
+	g.spawn(g.startPeerStoreSaving)
+
+	for _, peer := range g.GetPeersCopy() {
+		g.greetPeer(peer) // peers from options are contacted first time
+	}
+
+	select {
+	case <-ctx.Done():
+		g.l.Info("context of the gossiper is cancelled, stopping")
+	case <-g.ctx.Done():
+		g.l.Info("gossiper is asked to stop")
+	}
+	g.cancel()
+
+	// readers are blocked in reading from sockets, so closing sockets wakes them up
+	g.closeConnections()
+	g.threads.Wait()
+	g.savePeerStore() // nobody changes it any more
+
+	g.l.Info("all the gossiper threads are finished")
+	return nil
+}
+
+// asks the gossiper to stop and waits until Run returns. Safe to call many times and from many goroutines
+func (g *Gossiper) Stop() {
+	g.cancel()
+	if atomic.CompareAndSwapInt32(&g.state, gossiperCreated, gossiperStopped) {
+		// Run was never called, so nobody else will release the resources
+		g.closeConnections()
+		close(g.finished)
+	}
+	<-g.finished
+}
+
+// returns channel, which is closed, when gossiper is stopped
+func (g *Gossiper) Done() <-chan struct{} {
+	return g.ctx.Done()
+}
+
+func (g *Gossiper) closeConnections() {
+	CheckError(g.peersTransport.Close(), g.l)
+	CheckError(g.clientConnection.Close(), g.l)
+}
+
+// starts new goroutine, which is waited for, when gossiper stops. Goroutine should listen to g.ctx
+func (g *Gossiper) spawn(f func()) {
+	g.threads.Add(1)
+	go func() {
+		defer g.threads.Done()
+		f()
+	}()
+}
+
+// the only way to pass a packet to peer-writer thread. Never blocks, so can be called while holding locks
+// if the queue to the peer is full, some packet is dropped according to the overflow policy, as udp would do
+func (g *Gossiper) sendToPeer(agp *AddressedGossipPacket) {
+	g.peerMessagesToSend.Push(agp)
+}
+
+// returns false, if gossiper was stopped before timeout passed
+func (g *Gossiper) sleep(timeout time.Duration) bool {
+	timer := g.clock.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C():
+		return true
+	case <-g.ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,172 @@
+package gossiper
+
+import (
+	. "github.com/SubutaiBogatur/Peerster/config"
+	. "github.com/SubutaiBogatur/Peerster/events"
+	. "github.com/SubutaiBogatur/Peerster/models"
+	. "github.com/SubutaiBogatur/Peerster/models/liveness"
+	. "github.com/SubutaiBogatur/Peerster/models/negotiation"
+	"math/rand"
+	. "net"
+)
+
+// Neighbours are watched by failure detector in SWIM manner (see LivenessDetector). Liveness-probing thread pings
+// one neighbour per LivenessProbePeriod in round-robin. If it doesn't answer in LivenessAckTimeout, other neighbours
+// are asked to ping it, so that one lossy link doesn't kill it. Any packet from the neighbour counts as an answer.
+// Dead neighbours are removed from peers and are added back with the first packet from them.
+// Only neighbours, which said in hello, that they support liveness, are probed: older ones wouldn't answer
+
+func (g *Gossiper) startLivenessProbing() {
+	g.l.Info("starting liveness-probing thread")
+
+	next := 0 // round-robin over peers
+	for {
+		start := g.clock.Now()
+		target := g.getLivenessTarget(next)
+		next++
+		if target == nil {
+			if !g.sleep(LivenessProbePeriod) {
+				return
+			}
+		} else {
+			g.livenessProbeSeq++
+			seq := g.livenessProbeSeq
+			g.sendToPeer(&AddressedGossipPacket{Address: target, Packet: &GossipPacket{Liveness: &Liveness{Seq: seq, Target: target.String()}}})
+
+			if !g.sleep(LivenessAckTimeout) {
+				return
+			}
+			if !g.liveness.HeardSince(target.String(), start) {
+				g.requestIndirectProbes(target, seq)
+			}
+			if !g.sleep(LivenessProbePeriod - LivenessAckTimeout) {
+				return
+			}
+			if !g.liveness.HeardSince(target.String(), start) && g.liveness.Suspect(target.String()) {
+				g.events.Publish(PeerSuspectedEvent{Peer: target})
+			}
+		}
+
+		for _, address := range g.liveness.ExpireSuspicions() {
+			if peer := g.removePeer(address); peer != nil {
+				g.peerStore.MarkFailed(address)
+				g.peerMessagesToSend.Remove(address)
+				g.events.Publish(PeerDeadEvent{Peer: peer})
+			}
+		}
+		for _, address := range g.liveness.GetDeadToRetry() {
+			g.l.Debug("probing dead peer " + address)
+			if peer, err := ResolveUDPAddr("udp4", address); err == nil {
+				g.livenessProbeSeq++
+				g.sendToPeer(&AddressedGossipPacket{Address: peer, Packet: &GossipPacket{Liveness: &Liveness{Seq: g.livenessProbeSeq, Target: address}}})
+			}
+		}
+	}
+}
+
+// returns nil, if no peer can be probed
+func (g *Gossiper) getLivenessTarget(index int) *UDPAddr {
+	peers := g.getLivenessPeers()
+	if len(peers) == 0 {
+		return nil
+	}
+	return peers[index%len(peers)]
+}
+
+// peers, which answer liveness probes
+func (g *Gossiper) getLivenessPeers() []*UDPAddr {
+	peers := make([]*UDPAddr, 0)
+	for _, peer := range g.GetPeersCopy() {
+		if g.capabilities.Supports(peer.String(), LivenessCapability) {
+			peers = append(peers, peer)
+		}
+	}
+	return peers
+}
+
+func (g *Gossiper) requestIndirectProbes(target *UDPAddr, seq uint64) {
+	helpers := make([]*UDPAddr, 0)
+	for _, peer := range g.getLivenessPeers() {
+		if peer.String() != target.String() {
+			helpers = append(helpers, peer)
+		}
+	}
+	rand.Shuffle(len(helpers), func(i, j int) { helpers[i], helpers[j] = helpers[j], helpers[i] })
+	if len(helpers) > LivenessIndirectProbes {
+		helpers = helpers[:LivenessIndirectProbes]
+	}
+
+	for _, helper := range helpers {
+		g.l.Debug("asking " + helper.String() + " to probe " + target.String())
+		g.sendToPeer(&AddressedGossipPacket{Address: helper, Packet: &GossipPacket{Liveness: &Liveness{Seq: seq, Target: target.String()}}})
+	}
+}
+
+func (g *Gossiper) processAddressedLiveness(lv *Liveness, address *UDPAddr) {
+	own := g.peersAddress.String()
+
+	if !lv.IsAck {
+		if lv.Target == own {
+			// ack goes back the same way, the probe came
+			ack := &Liveness{Seq: lv.Seq, Target: own, IsAck: true, Requester: lv.Requester}
+			g.sendToPeer(&AddressedGossipPacket{Address: address, Packet: &GossipPacket{Liveness: ack}})
+			return
+		}
+		target := g.getPeer(lv.Target)
+		if lv.Requester != "" || target == nil {
+			g.l.Debug("dropping request to probe " + lv.Target + " from " + address.String())
+			return
+		}
+		probe := &Liveness{Seq: lv.Seq, Target: lv.Target, Requester: address.String()}
+		g.sendToPeer(&AddressedGossipPacket{Address: target, Packet: &GossipPacket{Liveness: probe}})
+		return
+	}
+
+	if lv.Requester != "" && lv.Requester != own {
+		// this gossiper helped with indirect probe
+		if requester := g.getPeer(lv.Requester); requester != nil {
+			ack := &Liveness{Seq: lv.Seq, Target: lv.Target, IsAck: true}
+			g.sendToPeer(&AddressedGossipPacket{Address: requester, Packet: &GossipPacket{Liveness: ack}})
+		}
+		return
+	}
+	if target := g.getPeer(lv.Target); target != nil {
+		g.markAlive(target) // is heard through a helper
+	}
+}
+
+// any packet from the neighbour shows, that it's alive
+func (g *Gossiper) markAlive(peer *UDPAddr) {
+	was := g.liveness.MarkHeardFrom(peer.String())
+	if was == SuspectedPeer || was == DeadPeer {
+		g.events.Publish(PeerAliveEvent{Peer: peer, Was: was})
+	}
+}
+
+// returns nil, if address is not a peer
+func (g *Gossiper) getPeer(address string) *UDPAddr {
+	g.peersSliceMux.Lock()
+	defer g.peersSliceMux.Unlock()
+
+	for _, peer := range g.peers {
+		if peer.String() == address {
+			return peer
+		}
+	}
+	return nil
+}
+
+// returns removed peer, nil if it was not a peer
+func (g *Gossiper) removePeer(address string) *UDPAddr {
+	g.peersSliceMux.Lock()
+	defer g.peersSliceMux.Unlock()
+
+	for i, peer := range g.peers {
+		if peer.String() == address {
+			g.l.Info("removing dead peer " + address)
+			g.peers = append(g.peers[:i:i], g.peers[i+1:]...)
+			return peer
+		}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package gossiper
+
+import (
+	. "github.com/SubutaiBogatur/Peerster/config"
+	. "github.com/SubutaiBogatur/Peerster/events"
+	. "github.com/SubutaiBogatur/Peerster/models"
+	. "github.com/SubutaiBogatur/Peerster/models/liveness"
+	. "github.com/SubutaiBogatur/Peerster/models/negotiation"
+	"math/rand"
+	. "net"
+)
+
+// Neighbours tell each other about their neighbours. While gossiper has less peers, than target degree, peer-exchange
+// thread sends a sample of its peers to one neighbour per PeerExchangePeriod, the neighbour answers with a sample of its
+// own. Receiver of either adds at most PeerExchangeMaxAccepted new addresses and never goes over target degree itself,
+// though others can still add it. Dead neighbours are neither shared, nor accepted. New peer is suspected until it
+// answers, so that wrong address is removed by the failure detector, as any dead neighbour
+
+func (g *Gossiper) startPeerExchange() {
+	g.l.Info("starting peer-exchange thread")
+
+	for {
+		if !g.sleep(PeerExchangePeriod) {
+			return
+		}
+		if g.peersCount() >= g.targetDegree {
+			continue
+		}
+
+		peer := g.getPeerExchangePeer()
+		if peer == nil {
+			continue
+		}
+		g.l.Debug("asking " + peer.String() + " for its neighbours")
+		g.sendToPeer(&AddressedGossipPacket{Address: peer, Packet: &GossipPacket{PeerExchange: &PeerExchange{Peers: g.samplePeers(peer)}}})
+	}
+}
+
+// returns nil, if no neighbour takes part in peer exchange
+func (g *Gossiper) getPeerExchangePeer() *UDPAddr {
+	peers := make([]*UDPAddr, 0)
+	for _, peer := range g.GetPeersCopy() {
+		if g.capabilities.Supports(peer.String(), PeerExchangeCapability) {
+			peers = append(peers, peer)
+		}
+	}
+	if len(peers) == 0 {
+		return nil
+	}
+	return peers[rand.Intn(len(peers))]
+}
+
+// at most PeerExchangeSampleSize random peers, except the receiver of the sample
+func (g *Gossiper) samplePeers(receiver *UDPAddr) []string {
+	sample := make([]string, 0)
+	for _, peer := range g.GetPeersCopy() {
+		if peer.String() != receiver.String() {
+			sample = append(sample, peer.String())
+		}
+	}
+	rand.Shuffle(len(sample), func(i, j int) { sample[i], sample[j] = sample[j], sample[i] })
+	if len(sample) > PeerExchangeSampleSize {
+		sample = sample[:PeerExchangeSampleSize]
+	}
+	return sample
+}
+
+func (g *Gossiper) processAddressedPeerExchange(pe *PeerExchange, address *UDPAddr) {
+	if g.noPeerExchange {
+		g.l.Debug("dropping peer exchange from " + address.String() + ", peer exchange is off")
+		return
+	}
+
+	if !pe.IsReply {
+		g.sendToPeer(&AddressedGossipPacket{Address: address, Packet: &GossipPacket{PeerExchange: &PeerExchange{Peers: g.samplePeers(address), IsReply: true}}})
+	}
+
+	offered := pe.Peers
+	if len(offered) > PeerExchangeSampleSize {
+		offered = offered[:PeerExchangeSampleSize]
+	}
+	accepted := 0
+	for _, candidate := range offered {
+		if accepted >= PeerExchangeMaxAccepted || g.peersCount() >= g.targetDegree {
+			break
+		}
+		peer, err := ResolveUDPAddr("udp4", candidate)
+		if err != nil || peer.IP.IsUnspecified() || peer.Port == 0 {
+			g.l.Debug("dropping malformed address " + candidate + " from " + address.String())
+			continue
+		}
+		if peer.String() == g.peersAddress.String() || g.getPeer(peer.String()) != nil {
+			continue
+		}
+		if g.liveness.GetPeersCopy()[peer.String()].Status == DeadPeer {
+			continue // it's retried by liveness-probing thread itself
+		}
+
+		g.l.Info("adding peer " + peer.String() + " from " + address.String())
+		g.liveness.Suspect(peer.String())
+		g.UpdatePeersIfNeeded(peer)
+		g.events.Publish(PeerDiscoveredEvent{Peer: peer, From: address})
+		accepted++
+	}
+}
+
+func (g *Gossiper) peersCount() int {
+	g.peersSliceMux.Lock()
+	defer g.peersSliceMux.Unlock()
+
+	return len(g.peers)
+}
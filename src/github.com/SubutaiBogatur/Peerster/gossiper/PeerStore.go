@@ -0,0 +1,91 @@
+package gossiper
+
+import (
+	. "github.com/SubutaiBogatur/Peerster/config"
+	. "net"
+	"strings"
+)
+
+// Neighbours, from which something was received, are kept in address book (see PeerStore) in data directory together
+// with origins behind them. When gossiper is created, usable neighbours of the address book become peers and routes
+// to origins behind them are restored with seq 0, so that any advertisement is better. Restored neighbour is suspected
+// until it answers, so that the one, which is gone, is removed by the failure detector and fails in address book.
+// If address book gives no neighbours, bootstrap list is used, it's used again, when all the peers are dead.
+// Peer-store-saving thread writes address book every PeerStoreSavePeriod, it's written also when gossiper stops
+
+func (g *Gossiper) startPeerStoreSaving() {
+	g.l.Info("starting peer-store-saving thread")
+
+	for {
+		if !g.sleep(PeerStoreSavePeriod) {
+			return
+		}
+		g.savePeerStore()
+
+		if g.peersCount() == 0 && len(g.bootstrap) > 0 {
+			g.l.Info("all the peers are gone, rejoining through bootstrap list")
+			for _, peer := range g.bootstrap {
+				g.UpdatePeersIfNeeded(peer)
+			}
+		}
+	}
+}
+
+func (g *Gossiper) savePeerStore() {
+	origins := make(map[string][]string) // neighbour -> origins behind it
+	for origin, routes := range g.routingTable.GetTableCopy() {
+		if len(routes) > 0 && origin != g.GetName() {
+			nextHop := routes[0].NextHop.String()
+			origins[nextHop] = append(origins[nextHop], origin)
+		}
+	}
+	g.peerStore.SetOrigins(origins)
+
+	if err := g.peerStore.Save(); err != nil {
+		g.l.Warn("unable to save address book: " + err.Error())
+	}
+}
+
+// called only from NewGossiper, before any thread is started
+func (g *Gossiper) restorePeers() {
+	usable := g.peerStore.GetUsable()
+	for _, record := range usable {
+		peer, err := ResolveUDPAddr("udp4", record.Address)
+		if err != nil || peer.String() == g.peersAddress.String() {
+			continue
+		}
+		if g.getPeer(peer.String()) == nil {
+			g.l.Info("restoring peer " + peer.String() + " from address book")
+			g.peers = append(g.peers, peer)
+			g.liveness.Suspect(peer.String())
+		}
+		for _, origin := range record.Origins {
+			g.routingTable.Update(origin, peer, 0, 0)
+		}
+	}
+
+	if len(usable) == 0 {
+		for _, peer := range g.bootstrap {
+			if g.getPeer(peer.String()) == nil {
+				g.l.Info("adding bootstrap peer " + peer.String())
+				g.peers = append(g.peers, peer)
+			}
+		}
+	}
+}
+
+// addresses separated with "," in the form ip:port, empty ones are skipped
+func parseAddresses(addresses string) ([]*UDPAddr, error) {
+	parsed := make([]*UDPAddr, 0)
+	for _, address := range strings.Split(addresses, ",") {
+		if address == "" {
+			continue
+		}
+		udpAddr, err := ResolveUDPAddr("udp4", address)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, udpAddr)
+	}
+	return parsed, nil
+}
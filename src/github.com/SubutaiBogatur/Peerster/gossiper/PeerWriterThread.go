@@ -0,0 +1,170 @@
+package gossiper
+
+import (
+	. "github.com/SubutaiBogatur/Peerster/config"
+	. "github.com/SubutaiBogatur/Peerster/models"
+	. "github.com/SubutaiBogatur/Peerster/models/fragmentation"
+	. "github.com/SubutaiBogatur/Peerster/models/negotiation"
+	"github.com/dedis/protobuf"
+	"math/rand"
+	. "net"
+	"strconv"
+)
+
+// peer-writer thread
+func (g *Gossiper) startPeerWriter() {
+	g.l.Info("starting peer writer thread")
+
+	var pending *AddressedGossipPacket // packet, which didn't fit into the previous batch
+	for {
+		// ~~~ read from queues ~~~
+		agp := pending
+		pending = nil
+		if agp == nil {
+			agp = g.peerMessagesToSend.Pop()
+		}
+		if agp == nil {
+			select {
+			case <-g.peerMessagesToSend.Ready():
+			case <-g.ctx.Done():
+				return
+			}
+			continue
+		}
+		// ~~~~~~~~~~~~~~~~~~~~~~~~
+
+		address := agp.Address
+		gp := agp.Packet
+
+		packetBytes, err := protobuf.Encode(gp)
+		if err != nil {
+			g.l.Error("unable to encode msg: " + err.Error())
+			continue
+		}
+
+		g.l.Debug("sending message from " + g.peersAddress.String() + " to " + address.String())
+
+		if !g.noBatching && len(packetBytes) <= BatchablePacketSize && g.capabilities.Supports(address.String(), BatchingCapability) {
+			pending = g.writeBatch(address, gp, packetBytes)
+			continue
+		}
+
+		g.writePacket(packetBytes, address)
+	}
+}
+
+// small packets go one after another in bursts (rumor, then status feedback, then anti-entropy), so writer waits a
+// bit for more packets to the same peer and sends all of them in one datagram. Waiting stops earlier, if batch is
+// full or other peers have packets to send. Returns packet, which was taken from the queue, but didn't fit
+func (g *Gossiper) writeBatch(address *UDPAddr, first *GossipPacket, firstBytes []byte) *AddressedGossipPacket {
+	packets := []*GossipPacket{first}
+	size := BatchPacketOverhead + len(firstBytes) + BatchPacketOverhead // batch itself and the first packet in it
+
+	timer := g.clock.NewTimer(BatchCoalescingWindow)
+	defer timer.Stop()
+
+	var leftover *AddressedGossipPacket
+collecting:
+	for {
+		agp := g.peerMessagesToSend.PopTo(address.String())
+		if agp == nil {
+			if g.peerMessagesToSend.Len() > 0 {
+				break // don't keep other peers waiting
+			}
+
+			select {
+			case <-g.peerMessagesToSend.Ready():
+				continue
+			case <-timer.C():
+				break collecting
+			case <-g.ctx.Done():
+				return nil
+			}
+		}
+
+		packetBytes, err := protobuf.Encode(agp.Packet)
+		if err != nil {
+			g.l.Error("unable to encode msg: " + err.Error())
+			continue
+		}
+
+		if len(packetBytes) > BatchablePacketSize || size+len(packetBytes)+BatchPacketOverhead > g.maxDatagramSize() {
+			leftover = agp
+			break
+		}
+		packets = append(packets, agp.Packet)
+		size += len(packetBytes) + BatchPacketOverhead
+	}
+
+	if len(packets) == 1 {
+		g.writePacket(firstBytes, address)
+		return leftover
+	}
+
+	batchBytes, err := protobuf.Encode(&GossipPacket{Batch: &Batch{Packets: packets}})
+	if err != nil {
+		g.l.Error("unable to encode batch: " + err.Error())
+		return leftover
+	}
+
+	g.l.Debug("sending batch of " + strconv.Itoa(len(packets)) + " packets to " + address.String())
+	g.writePacket(batchBytes, address)
+	return leftover
+}
+
+// called only by peer-writer thread
+func (g *Gossiper) writePacket(packetBytes []byte, address *UDPAddr) {
+	if len(packetBytes) > g.maxDatagramSize() {
+		// until hello of the peer comes, it's fragmented: peer is either of the first version, which would drop
+		// the packet anyway, or its hello is on the way
+		if g.capabilities.HasHello(address.String()) && !g.capabilities.Supports(address.String(), FragmentationCapability) {
+			g.l.Warn("packet of " + strconv.Itoa(len(packetBytes)) + " bytes doesn't fit into datagram and " + address.String() + " doesn't understand fragments, dropping it")
+			return
+		}
+		g.writeFragments(packetBytes, address)
+		return
+	}
+
+	g.writeDatagram(packetBytes, address)
+}
+
+// called only by peer-writer thread
+func (g *Gossiper) writeFragments(packetBytes []byte, address *UDPAddr) {
+	fragments := SplitIntoFragments(packetBytes, rand.Uint64())
+	if fragments == nil {
+		g.l.Error("packet of " + strconv.Itoa(len(packetBytes)) + " bytes is too large even for fragmentation, dropping it")
+		return
+	}
+
+	g.l.Debug("packet of " + strconv.Itoa(len(packetBytes)) + " bytes is split into " + strconv.Itoa(len(fragments)) + " fragments")
+	for _, f := range fragments {
+		fragmentBytes, err := protobuf.Encode(&GossipPacket{Fragment: f})
+		if err != nil {
+			g.l.Error("unable to encode fragment: " + err.Error())
+			return
+		}
+
+		g.writeDatagram(fragmentBytes, address)
+	}
+}
+
+// sealed datagrams are a bit larger, so packets should leave space for it
+func (g *Gossiper) maxDatagramSize() int {
+	if g.secureLinks {
+		return MaxPacketSize - SealedPacketOverhead
+	}
+	return MaxPacketSize
+}
+
+// every datagram to peers is written here
+func (g *Gossiper) writeDatagram(datagram []byte, address *UDPAddr) {
+	if g.secureLinks {
+		g.writeSealed(datagram, address)
+		return
+	}
+
+	n, err := g.peersTransport.WriteTo(datagram, address)
+	if err != nil {
+		g.l.Error("error when writing to connection: " + err.Error() + " n is " + strconv.Itoa(n))
+	}
+}
@@ -0,0 +1,42 @@
+package gossiper
+
+import (
+	. "github.com/SubutaiBogatur/Peerster/models"
+	. "github.com/SubutaiBogatur/Peerster/models/identity"
+)
+
+// Text of private message can be encrypted for the identity key of destination (see Encryption.go), then relays see
+// only Destination and HopLimit, which is enough for forwarding. Key of destination is learned from its signed rumors,
+// so route rumors of destination should reach the origin before it can send anything encrypted
+
+// called before signing, returns false, if text cannot be encrypted and message should not be sent
+func (g *Gossiper) encryptPrivateText(pmsg *PrivateMessage) bool {
+	publicKey := g.keyStore.GetKey(pmsg.Destination)
+	if publicKey == nil {
+		g.l.Warn("key of " + pmsg.Destination + " is unknown, cannot encrypt private message for it, dropping it")
+		return false
+	}
+
+	ephemeralKey, ciphertext, err := EncryptFor(publicKey, []byte(pmsg.Text), pmsg.EncryptionAdditionalData())
+	if err != nil {
+		g.l.Warn("unable to encrypt private message for " + pmsg.Destination + ", error: " + err.Error())
+		return false
+	}
+	pmsg.Text = ""
+	pmsg.EphemeralKey = ephemeralKey
+	pmsg.Ciphertext = ciphertext
+	return true
+}
+
+// returns copy of the message with decrypted text or nil, if it cannot be decrypted
+func (g *Gossiper) decryptPrivateText(pmsg *PrivateMessage) *PrivateMessage {
+	text, err := g.identity.Decrypt(pmsg.EphemeralKey, pmsg.Ciphertext, pmsg.EncryptionAdditionalData())
+	if err != nil {
+		g.l.Warn("unable to decrypt private message of " + pmsg.Origin + ", dropping it, error: " + err.Error())
+		return nil
+	}
+
+	decrypted := *pmsg // packet may be still kept by event handlers
+	decrypted.Text = string(text)
+	return &decrypted
+}
@@ -0,0 +1,68 @@
+package gossiper
+
+import (
+	. "github.com/SubutaiBogatur/Peerster/config"
+	. "github.com/SubutaiBogatur/Peerster/models"
+	. "github.com/SubutaiBogatur/Peerster/models/negotiation"
+	"github.com/dedis/protobuf"
+	. "net"
+	"strconv"
+)
+
+// When status of the peer shows, that it lacks more than one rumor, and the peer understands bundles, rumors are sent
+// in one bundle up to RumorBundleBudget instead of one rumor per status exchange. Peer answers the bundle with its
+// status, which brings the next bundle, so peer far behind catches up in a few rounds. Rumors new for the receiver
+// are not mongered one by one, the newest one is mongered, and statuses of those, who get it, bring the rest
+
+// missing are rumors, which the peer lacks. Returns true, if the bundle was sent to the peer, then status is answered
+func (g *Gossiper) sendRumorBundle(missing []*RumorMessage, peer *UDPAddr) bool {
+	if !g.capabilities.Supports(peer.String(), RumorBundleCapability) {
+		return false
+	}
+	if len(missing) < 2 {
+		return false // single rumor is mongered as usual
+	}
+
+	bundle := &RumorBundle{Rumors: make([]*RumorMessage, 0)}
+	size := 0
+	for _, rmsg := range missing {
+		rumorBytes, err := protobuf.Encode(rmsg)
+		if err != nil {
+			g.l.Warn("unable to encode rumor " + rmsg.String() + ": " + err.Error())
+			continue
+		}
+		if len(bundle.Rumors) > 0 && size+len(rumorBytes) > RumorBundleBudget {
+			break
+		}
+		bundle.Rumors = append(bundle.Rumors, rmsg)
+		size += len(rumorBytes)
+	}
+
+	g.l.Info("sending bundle of " + strconv.Itoa(len(bundle.Rumors)) + " of " + strconv.Itoa(len(missing)) + " missing rumors to " + peer.String())
+	g.sendToPeer(&AddressedGossipPacket{Address: peer, Packet: &GossipPacket{RumorBundle: bundle}})
+	return true
+}
+
+func (g *Gossiper) processAddressedRumorBundle(bundle *RumorBundle, address *UDPAddr) {
+	var newest *RumorMessage
+	for _, rmsg := range bundle.Rumors {
+		if rmsg == nil || !g.isRumorAuthentic(rmsg, address) {
+			continue
+		}
+
+		// received rumor was already published as event, so it's not changed, but copied
+		relayed := *rmsg
+		relayed.HopCount++ // counting the hop to this gossiper
+		g.updateNextHop(&relayed, address)
+		if added := g.messageStorage.AddRumorMessage(&relayed); len(added) > 0 {
+			newest = added[len(added)-1]
+		}
+	}
+
+	g.l.Info("sending status as feedback for bundle to " + address.String())
+	g.sendToPeer(&AddressedGossipPacket{Address: address, Packet: &GossipPacket{Status: g.getStatusFor(address)}})
+
+	if newest != nil {
+		g.spreadTheRumor(newest, nil)
+	}
+}
@@ -0,0 +1,97 @@
+package gossiper
+
+import (
+	. "github.com/SubutaiBogatur/Peerster/events"
+	. "github.com/SubutaiBogatur/Peerster/models"
+	"github.com/dedis/protobuf"
+	. "net"
+	"strconv"
+)
+
+// If links are secured, every datagram between neighbours is either handshake or sealed one (see LinkManager).
+// Neighbour proves with handshake, that it has the key of the name it claims, so only neighbours, whose keys are
+// pinned or trusted on first use, get their packets processed. Sealed datagrams cannot be read, changed or replayed
+
+// called by peer-writer thread instead of writing to transport
+func (g *Gossiper) writeSealed(datagram []byte, address *UDPAddr) {
+	sealed, handshake := g.links.Seal(address.String(), g.name.Load().(string), datagram)
+	if handshake != nil {
+		g.l.Debug("starting handshake with " + address.String())
+		g.writeLinkPacket(&GossipPacket{Handshake: handshake}, address)
+	}
+	if sealed != nil {
+		g.writeLinkPacket(&GossipPacket{Sealed: sealed}, address)
+	}
+}
+
+// called only by peer-reader thread, returns packet inside the sealed one or nil, if there is nothing to process
+func (g *Gossiper) unsealPacket(gp *GossipPacket, address *UDPAddr) *GossipPacket {
+	if gp.Handshake != nil {
+		g.handleHandshake(gp.Handshake, address)
+		return nil
+	}
+	if gp.Sealed == nil {
+		g.rejectDatagram(address, "not sealed")
+		return nil
+	}
+
+	datagram, handshake, err := g.links.Open(address.String(), g.name.Load().(string), gp.Sealed)
+	if handshake != nil {
+		g.l.Debug("restarting handshake with " + address.String())
+		g.writeLinkPacket(&GossipPacket{Handshake: handshake}, address)
+	}
+	if err != nil {
+		g.rejectDatagram(address, err.Error())
+		return nil
+	}
+
+	inner := &GossipPacket{}
+	if err := protobuf.Decode(datagram, inner); err != nil {
+		g.l.Warn("unable to decode sealed message, error: " + err.Error())
+		return nil
+	}
+	if inner.Handshake != nil || inner.Sealed != nil {
+		g.l.Warn("sealed packet from " + address.String() + " is sealed or handshake itself, dropping it")
+		return nil
+	}
+	return inner
+}
+
+// called only by peer-reader thread
+func (g *Gossiper) handleHandshake(hs *Handshake, address *UDPAddr) {
+	result, err := g.links.HandleHandshake(address.String(), g.name.Load().(string), hs)
+	if err != nil {
+		g.rejectDatagram(address, "handshake stage "+strconv.Itoa(int(hs.Stage))+" failed: "+err.Error())
+		return
+	}
+
+	if result.Reply != nil {
+		g.writeLinkPacket(&GossipPacket{Handshake: result.Reply}, address)
+	}
+	for _, sealed := range result.Flushed {
+		g.writeLinkPacket(&GossipPacket{Sealed: sealed}, address)
+	}
+	if result.Name != "" {
+		g.l.Info("secure link with " + address.String() + " is established, neighbour is " + result.Name)
+		g.events.Publish(LinkEstablishedEvent{Peer: address, Name: result.Name})
+	}
+}
+
+func (g *Gossiper) rejectDatagram(address *UDPAddr, reason string) {
+	g.l.Warn("rejecting datagram from " + address.String() + ": " + reason)
+	g.events.Publish(LinkRejectedEvent{From: address, Reason: reason})
+}
+
+// called by both peer-reader and peer-writer, transport can be written concurrently
+func (g *Gossiper) writeLinkPacket(gp *GossipPacket, address *UDPAddr) {
+	packetBytes, err := protobuf.Encode(gp)
+	if err != nil {
+		g.l.Error("unable to encode link packet: " + err.Error())
+		return
+	}
+
+	n, err := g.peersTransport.WriteTo(packetBytes, address)
+	if err != nil {
+		g.l.Error("error when writing to connection: " + err.Error() + " n is " + strconv.Itoa(n))
+	}
+}
@@ -0,0 +1,41 @@
+package gossiper
+
+import (
+	. "github.com/SubutaiBogatur/Peerster/events"
+	. "github.com/SubutaiBogatur/Peerster/models"
+	. "net"
+)
+
+// Origin names are bound to ed25519 keys (see KeyStore), so nobody can speak in the name of another origin.
+// Rumors and private messages are signed by their origin, relays keep the signature untouched. Messages, which
+// are not signed with the key of their origin, are neither stored nor forwarded
+
+func (g *Gossiper) signRumor(rmsg *RumorMessage) {
+	rmsg.PublicKey = g.identity.PublicKey()
+	rmsg.Signature = g.identity.Sign(rmsg.SignedData())
+}
+
+func (g *Gossiper) signPrivate(pmsg *PrivateMessage) {
+	pmsg.PublicKey = g.identity.PublicKey()
+	pmsg.Signature = g.identity.Sign(pmsg.SignedData())
+}
+
+func (g *Gossiper) isRumorAuthentic(rmsg *RumorMessage, address *UDPAddr) bool {
+	if g.keyStore.Verify(rmsg.OriginalName, rmsg.PublicKey, rmsg.SignedData(), rmsg.Signature) {
+		return true
+	}
+
+	g.l.Warn("rumor " + rmsg.String() + " from " + address.String() + " is forged, dropping it")
+	g.events.Publish(ForgedMessageEvent{From: address, Origin: rmsg.OriginalName})
+	return false
+}
+
+func (g *Gossiper) isPrivateAuthentic(pmsg *PrivateMessage, address *UDPAddr) bool {
+	if g.keyStore.Verify(pmsg.Origin, pmsg.PublicKey, pmsg.SignedData(), pmsg.Signature) {
+		return true
+	}
+
+	g.l.Warn("private message of " + pmsg.Origin + " from " + address.String() + " is forged, dropping it")
+	g.events.Publish(ForgedMessageEvent{From: address, Origin: pmsg.Origin})
+	return false
+}
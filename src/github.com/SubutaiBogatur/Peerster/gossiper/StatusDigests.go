@@ -0,0 +1,35 @@
+package gossiper
+
+import (
+	. "github.com/SubutaiBogatur/Peerster/models"
+	. "github.com/SubutaiBogatur/Peerster/models/negotiation"
+	. "net"
+	"strconv"
+)
+
+// Peers, which understand digests, get compact status instead of the full vector clock: hash of the whole clock.
+// If hashes differ, peers exchange hashes of smaller and smaller ranges of origins, until the ranges are small enough
+// to be listed, then the missing rumors are sent (see MessageStorage.Reconcile). So peers in sync exchange a few
+// bytes, whatever the number of origins. Others get full status as before
+
+// status, which should be sent to the peer
+func (g *Gossiper) getStatusFor(peer *UDPAddr) *StatusPacket {
+	if !g.noStatusDigests && g.capabilities.Supports(peer.String(), StatusDigestCapability) {
+		return g.messageStorage.GetStatusDigest()
+	}
+	return g.messageStorage.GetCurrentStatusPacket()
+}
+
+// answers compact status of the peer, returns true, if the peer is in sync with this gossiper
+func (g *Gossiper) processStatusDigest(sp *StatusPacket, peer *UDPAddr) bool {
+	reply, missing := g.messageStorage.Reconcile(sp.Ranges)
+
+	if len(missing) > 0 && !g.sendRumorBundle(missing, peer) {
+		g.spreadTheRumor(missing[0], peer)
+	}
+	if len(reply) > 0 {
+		g.l.Debug("sending digest of " + strconv.Itoa(len(reply)) + " ranges to " + peer.String())
+		g.sendToPeer(&AddressedGossipPacket{Address: peer, Packet: &GossipPacket{Status: &StatusPacket{Ranges: reply}}})
+	}
+	return len(reply) == 0 && len(missing) == 0
+}
@@ -0,0 +1,49 @@
+package integration
+
+import (
+	"strconv"
+	"testing"
+
+	. "github.com/SubutaiBogatur/Peerster/gossiper"
+)
+
+// burst of rumors goes together with statuses, so small packets to the same peer share datagrams,
+// but every rumor is still processed on its own
+func TestSmallPacketsAreBatched(t *testing.T) {
+	for _, noBatching := range []bool{false, true} {
+		t.Run("NoBatching="+strconv.FormatBool(noBatching), func(t *testing.T) {
+			n := StartNetwork(t, Topology{"a": {"b"}, "b": {"a"}}, Options{
+				Seed: 10,
+				Configure: func(name string, opts *GossiperOptions) {
+					opts.NoBatching = noBatching
+				},
+			})
+			a, b := n.Node("a"), n.Node("b")
+
+			const rumorsNumber = 20
+			for i := 0; i < rumorsNumber; i++ {
+				a.SendRumor("rumor_" + strconv.Itoa(i))
+			}
+			n.WaitFor("b gets all the rumors", func() bool {
+				for i := 0; i < rumorsNumber; i++ {
+					if !b.HasRumor("a", "rumor_"+strconv.Itoa(i)) {
+						return false
+					}
+				}
+				return true
+			})
+
+			if noBatching {
+				for _, d := range n.GetDeliveriesCopy() {
+					if d.InBatch {
+						t.Fatal("packet is batched, though batching is off")
+					}
+				}
+				return
+			}
+			n.WaitForDelivery("a sends batch to b", func(d *Delivery) bool {
+				return d.From == "a" && d.To == "b" && d.InBatch
+			})
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package integration
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	. "github.com/SubutaiBogatur/Peerster/config"
+	. "github.com/SubutaiBogatur/Peerster/events"
+	. "github.com/SubutaiBogatur/Peerster/gossiper"
+	. "github.com/SubutaiBogatur/Peerster/models"
+	. "github.com/SubutaiBogatur/Peerster/transport"
+	"github.com/SubutaiBogatur/Peerster/utils/clock"
+)
+
+// burst of client rumors is mongered to the only neighbour at once, not one rumor per status round-trip
+func TestBurstOfRumorsIsMongeredConcurrently(t *testing.T) {
+	const rumors = 10
+	n := StartNetwork(t, Topology{"a": {"b"}, "b": {"a"}}, Options{
+		Seed:  25,
+		Link:  LinkConfig{Latency: 50 * time.Millisecond},
+		Clock: clock.NewFakeClock(time.Unix(0, 0)),
+		Configure: func(name string, opts *GossiperOptions) {
+			opts.NoStatusDigests = true // acknowledgements are seen in full statuses
+		},
+	})
+	a, b := n.Node("a"), n.Node("b")
+
+	for i := 0; i < rumors; i++ {
+		a.SendRumor("rumor " + strconv.Itoa(i))
+	}
+	n.WaitFor("b gets the burst", func() bool {
+		for i := 0; i < rumors; i++ {
+			if !b.HasRumor("a", "rumor "+strconv.Itoa(i)) {
+				return false
+			}
+		}
+		return true
+	})
+
+	// statuses of b acknowledge rumors of a only after all of them are sent
+	sent := make(map[uint32]bool)
+	for _, d := range n.GetDeliveriesCopy() {
+		if d.From == "a" && d.To == "b" && d.Packet.Rumor != nil && d.Packet.Rumor.OriginalName == "a" {
+			sent[d.Packet.Rumor.ID] = true
+		}
+		if d.From == "b" && d.To == "a" && d.Packet.Status != nil && acknowledgesAny(d.Packet.Status, "a") {
+			break
+		}
+	}
+	if len(sent) < rumors/2 {
+		t.Fatal("only " + strconv.Itoa(len(sent)) + " rumors are mongered before the first status")
+	}
+	if len(sent) > MaxMongeringSessionsPerPeer {
+		t.Fatal("more rumors are mongered at once, than sessions are allowed")
+	}
+}
+
+// true, if status shows, that some rumors of the origin are received
+func acknowledgesAny(sp *StatusPacket, origin string) bool {
+	for _, ps := range sp.Want {
+		if ps.Identifier == origin && ps.NextID > 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// every rumor of the burst goes on after b acknowledges it, not only the last one, and c, which hears a only
+// through b, gets all of them
+func TestBurstOfRumorsReachesPeerBehindNeighbour(t *testing.T) {
+	const rumors = MaxMongeringSessionsPerPeer
+	n := StartNetwork(t, Topology{"a": {"b"}, "b": {"a", "c"}, "c": {"b"}}, Options{
+		Seed:  26,
+		Link:  LinkConfig{Latency: 50 * time.Millisecond},
+		Clock: clock.NewFakeClock(time.Unix(0, 0)),
+	})
+	a, c := n.Node("a"), n.Node("c")
+
+	for i := 0; i < rumors; i++ {
+		a.SendRumor("rumor " + strconv.Itoa(i))
+	}
+	n.WaitFor("c gets the burst", func() bool {
+		for i := 0; i < rumors; i++ {
+			if !c.HasRumor("a", "rumor "+strconv.Itoa(i)) {
+				return false
+			}
+		}
+		return true
+	})
+
+	// coin is fair, so some of the acknowledged rumors go on, while the others are still on the way
+	flipped := make(map[uint32]bool)
+	for _, e := range n.GetEventsCopy() {
+		if coin, ok := e.Event.(CoinFlippedEvent); ok && e.Node == "a" && coin.Rumor.OriginalName == "a" {
+			flipped[coin.Rumor.ID] = true
+		}
+	}
+	if len(flipped) < 2 {
+		t.Fatal("mongering goes on only for " + strconv.Itoa(len(flipped)) + " rumors of the burst")
+	}
+}
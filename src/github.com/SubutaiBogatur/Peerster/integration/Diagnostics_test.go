@@ -0,0 +1,72 @@
+package integration
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/SubutaiBogatur/Peerster/events"
+	. "github.com/SubutaiBogatur/Peerster/gossiper"
+	. "github.com/SubutaiBogatur/Peerster/models/diagnostics"
+	. "github.com/SubutaiBogatur/Peerster/transport"
+	"github.com/SubutaiBogatur/Peerster/utils/clock"
+)
+
+// line a - b - c - d: a pings and traces d, then d becomes unreachable
+func TestPingAndTracerouteInLine(t *testing.T) {
+	n := StartNetwork(t, lineTopology(), Options{
+		Seed:  17,
+		Clock: clock.NewFakeClock(time.Unix(0, 0)),
+		Configure: func(name string, opts *GossiperOptions) {
+			opts.RouteRumorTimer = 100
+		},
+	})
+	a, c, d := n.Node("a"), n.Node("c"), n.Node("d")
+
+	a.SendRumor("LetsKnowEachOther1")
+	d.SendRumor("LetsKnowEachOther2")
+	n.WaitFor("a and d know routes to each other", func() bool {
+		return a.KnowsOrigin("d") && d.KnowsOrigin("a")
+	})
+
+	a.Ping("d")
+	ping := waitForProbe(n, "a", PingProbe, "d")
+	if ping.Status != ProbeAnswered || ping.HopCount != 3 {
+		t.Fatalf("unexpected ping result: %+v", ping)
+	}
+
+	a.Traceroute("d")
+	trace := waitForProbe(n, "a", TracerouteProbe, "d")
+	if trace.Status != ProbeAnswered || trace.HopCount != 3 || len(trace.Hops) != 4 {
+		t.Fatalf("unexpected traceroute result: %+v", trace)
+	}
+	for i, name := range []string{"a", "b", "c", "d"} {
+		if trace.Hops[i].Name != name || i > 0 && trace.Hops[i].Timestamp < trace.Hops[i-1].Timestamp {
+			t.Fatalf("unexpected hop %d of traceroute: %+v", i, trace.Hops[i])
+		}
+	}
+	if probes := a.Gossiper.GetProbes(); len(probes) != 2 || probes[1].Kind != TracerouteProbe || probes[1].Status != ProbeAnswered {
+		t.Fatalf("probes are not kept: %+v", probes)
+	}
+
+	n.Sim.SetBidirectionalLink(c.Address(), d.Address(), LinkConfig{Loss: 1})
+	a.Ping("d")
+	n.WaitForEvent("ping of a to unreachable d times out", func(e *NodeEvent) bool {
+		finished, ok := e.Event.(ProbeFinishedEvent)
+		return ok && e.Node == "a" && finished.Destination == "d" && finished.Status == ProbeTimedOut
+	})
+
+	a.Traceroute("nobody")
+	n.WaitForEvent("traceroute to unknown origin is unroutable", func(e *NodeEvent) bool {
+		finished, ok := e.Event.(ProbeFinishedEvent)
+		return ok && e.Node == "a" && finished.Destination == "nobody" && finished.Status == ProbeUnroutable
+	})
+}
+
+// waits for the first finished probe of the node of the kind to the destination
+func waitForProbe(n *Network, node string, kind string, destination string) ProbeFinishedEvent {
+	e := n.WaitForEvent(node+" finishes "+kind+" to "+destination, func(e *NodeEvent) bool {
+		finished, ok := e.Event.(ProbeFinishedEvent)
+		return ok && e.Node == node && finished.Kind == kind && finished.Destination == destination
+	})
+	return e.Event.(ProbeFinishedEvent)
+}
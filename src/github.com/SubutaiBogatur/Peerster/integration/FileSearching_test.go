@@ -0,0 +1,70 @@
+package integration
+
+import (
+	"testing"
+
+	. "github.com/SubutaiBogatur/Peerster/events"
+	. "github.com/SubutaiBogatur/Peerster/gossiper"
+)
+
+// tl - tm - tr
+// |          |
+// bl ------ br
+// file is shared at tl, downloaded at tr and bl, then br searches for it and downloads from the found nodes
+func TestFileSearching(t *testing.T) {
+	topology := Topology{
+		"tl": {"tm", "bl"},
+		"tr": {"tm", "br"},
+		"bl": {"tl", "br"},
+		"br": {"bl", "tr"},
+		"tm": {"tl", "tr"},
+	}
+	n := StartNetwork(t, topology, Options{
+		Seed: 5,
+		Configure: func(name string, opts *GossiperOptions) {
+			// anti-entropy is kept on, otherwise rumor can die out after coin flip before reaching everyone
+			opts.RouteRumorTimer = 10000
+		},
+	})
+	tl, tr, bl, br := n.Node("tl"), n.Node("tr"), n.Node("bl"), n.Node("br")
+
+	// let everyone know routes to everyone
+	names := []string{"tl", "tr", "bl", "br", "tm"}
+	for _, name := range names {
+		n.Node(name).SendRumor("I_am_" + name)
+	}
+	n.WaitFor("everyone knows routes to everyone", func() bool {
+		for _, name := range names {
+			for _, origin := range names {
+				if name != origin && !n.Node(name).KnowsOrigin(origin) {
+					return false
+				}
+			}
+		}
+		return true
+	})
+
+	content := randomContent(6, 300*1024)
+	tl.WriteSharedFile("pic1.jpg", content)
+	hash := tl.ShareAndWait("pic1.jpg")
+
+	tr.Download("tr-downloaded-pic1.jpg", hash, "tl")
+	bl.Download("bl-downloaded-pic1.jpg", hash, "tl")
+	tr.WaitForDownloadedFile("tr-downloaded-pic1.jpg", content)
+	bl.WaitForDownloadedFile("bl-downloaded-pic1.jpg", content)
+
+	br.Search([]string{"pic"}, 0)
+	n.WaitForEvent("br finishes search", func(e *NodeEvent) bool {
+		_, ok := e.Event.(SearchFinishedEvent)
+		return ok && e.Node == "br"
+	})
+	for _, origin := range []string{"tr", "bl"} {
+		n.WaitForEvent("br finds full match at "+origin, func(e *NodeEvent) bool {
+			match, ok := e.Event.(SearchMatchEvent)
+			return ok && e.Node == "br" && match.Origin == origin && uint64(len(match.Result.ChunkMap)) == match.Result.ChunkCount
+		})
+	}
+
+	br.Download("br-searched-downloaded-pic1.jpg", hash, "")
+	br.WaitForDownloadedFile("br-searched-downloaded-pic1.jpg", content)
+}
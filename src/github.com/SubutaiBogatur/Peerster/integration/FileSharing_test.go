@@ -0,0 +1,77 @@
+package integration
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	. "github.com/SubutaiBogatur/Peerster/gossiper"
+	. "github.com/SubutaiBogatur/Peerster/transport"
+	"github.com/SubutaiBogatur/Peerster/utils/clock"
+)
+
+// line a - b - c - d: file is shared at a, downloaded at d from a and then twice at b from d
+func TestFileSharingInLine(t *testing.T) {
+	n := StartNetwork(t, lineTopology(), Options{
+		Seed: 4,
+		Configure: func(name string, opts *GossiperOptions) {
+			// anti-entropy is kept on, otherwise rumor can die out after coin flip before reaching everyone
+			opts.RouteRumorTimer = 100
+		},
+	})
+	a, b, d := n.Node("a"), n.Node("b"), n.Node("d")
+
+	a.SendRumor("LetsKnowEachOther1")
+	d.SendRumor("LetsKnowEachOther2")
+	n.WaitFor("a, b and d know routes to each other", func() bool {
+		return a.KnowsOrigin("d") && d.KnowsOrigin("a") && b.KnowsOrigin("d") && d.KnowsOrigin("b")
+	})
+
+	content := randomContent(5, 1010*1024)
+	a.WriteSharedFile("1M_file.txt", content)
+	hash := a.ShareAndWait("1M_file.txt")
+
+	d.Download("d-downloaded-1M_file.txt", hash, "a")
+	d.WaitForDownloadedFile("d-downloaded-1M_file.txt", content)
+
+	// downloaded files are shared too
+	b.Download("b1-downloaded-1M_file.txt", hash, "d")
+	b.WaitForDownloadedFile("b1-downloaded-1M_file.txt", content)
+
+	b.Download("b2-downloaded-1M_file.txt", hash, "d")
+	b.WaitForDownloadedFile("b2-downloaded-1M_file.txt", content)
+}
+
+func randomContent(seed int64, size int) []byte {
+	content := make([]byte, size)
+	rand.New(rand.NewSource(seed)).Read(content)
+	return content
+}
+
+// every tenth datagram is lost, so downloading relies on resending requests after FileDownloadReplyTimeout.
+// Time is fake, so waiting for the timeouts takes milliseconds
+func TestFileSharingOverLossyLinks(t *testing.T) {
+	n := StartNetwork(t, Topology{"a": {"b"}, "b": {"a"}}, Options{
+		Seed:  7,
+		Link:  LinkConfig{Loss: 0.1},
+		Clock: clock.NewFakeClock(time.Unix(0, 0)),
+	})
+	a, b := n.Node("a"), n.Node("b")
+
+	a.SendRumor("LetsKnowEachOther1")
+	b.SendRumor("LetsKnowEachOther2")
+	n.WaitFor("a and b know routes to each other", func() bool {
+		return a.KnowsOrigin("b") && b.KnowsOrigin("a")
+	})
+
+	content := randomContent(8, 200*1024)
+	a.WriteSharedFile("lossy.txt", content)
+	hash := a.ShareAndWait("lossy.txt")
+
+	b.Download("b-downloaded-lossy.txt", hash, "a")
+	b.WaitForDownloadedFile("b-downloaded-lossy.txt", content)
+
+	if n.Sim.GetStats().Lost == 0 {
+		t.Fatal("nothing was lost, test checks nothing")
+	}
+}
@@ -0,0 +1,78 @@
+package integration
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/SubutaiBogatur/Peerster/config"
+	. "github.com/SubutaiBogatur/Peerster/events"
+	. "github.com/SubutaiBogatur/Peerster/gossiper"
+	. "github.com/SubutaiBogatur/Peerster/transport"
+)
+
+// a shares lots of files with long names, so search reply with all of them doesn't fit into one datagram
+// and is sent in fragments
+func TestLargePacketsAreFragmented(t *testing.T) {
+	n := StartNetwork(t, Topology{"a": {"b"}, "b": {"a"}}, Options{
+		Seed: 7,
+		Link: LinkConfig{Latency: time.Millisecond, Jitter: time.Millisecond}, // fragments are reordered by jitter
+		Configure: func(name string, opts *GossiperOptions) {
+			opts.RouteRumorTimer = 10000
+		},
+	})
+	a, b := n.Node("a"), n.Node("b")
+
+	a.SendRumor("I_am_a")
+	b.SendRumor("I_am_b")
+	n.WaitFor("a and b know routes to each other", func() bool {
+		return a.KnowsOrigin("b") && b.KnowsOrigin("a")
+	})
+
+	const filesNumber = 300
+	const filesInGroup = 20 // larger burst of client messages can overflow socket buffer of client port
+	names := make(map[string]bool)
+	for i := 0; i < filesNumber; i += filesInGroup {
+		group := make(map[string]bool)
+		for j := i; j < i+filesInGroup; j++ {
+			name := fmt.Sprintf("quarterly_report_with_a_really_long_and_descriptive_name_%03d.txt", j)
+			names[name], group[name] = true, true
+			a.WriteSharedFile(name, randomContent(int64(j), 100))
+			a.Share(name)
+		}
+		n.WaitFor(fmt.Sprintf("a shares files from %d", i), func() bool {
+			shared := 0
+			for _, e := range n.GetEventsCopy() {
+				if fse, ok := e.Event.(FileSharedEvent); ok && e.Node == "a" && group[fse.Name] {
+					shared++
+				}
+			}
+			return shared == filesInGroup
+		})
+	}
+
+	b.Search([]string{"report"}, 2)
+	n.WaitFor("b finds all the files at a", func() bool {
+		found := make(map[string]bool)
+		for _, e := range n.GetEventsCopy() {
+			if match, ok := e.Event.(SearchMatchEvent); ok && e.Node == "b" && match.Origin == "a" && names[match.Result.FileName] {
+				found[match.Result.FileName] = true
+			}
+		}
+		return len(found) == filesNumber
+	})
+
+	// whole reply is received as one packet, so it was larger, than datagram
+	n.WaitForEvent("b gets search reply larger than datagram", func(e *NodeEvent) bool {
+		packet, ok := e.Event.(PacketEvent)
+		return ok && e.Node == "b" && packet.Packet.SearchReply != nil && len(packet.Packet.SearchReply.Results) == filesNumber
+	})
+	for _, d := range n.GetDeliveriesCopy() {
+		if d.Packet.Fragment != nil && len(d.Packet.Fragment.Data) > FragmentDataSize {
+			t.Fatal("fragment is larger than FragmentDataSize")
+		}
+	}
+	n.WaitForDelivery("a sends fragments to b", func(d *Delivery) bool {
+		return d.From == "a" && d.To == "b" && d.Packet.Fragment != nil && d.Packet.Fragment.Count > 1
+	})
+}
@@ -0,0 +1,398 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/SubutaiBogatur/Peerster/events"
+	. "github.com/SubutaiBogatur/Peerster/gossiper"
+	. "github.com/SubutaiBogatur/Peerster/models"
+	. "github.com/SubutaiBogatur/Peerster/transport"
+	"github.com/SubutaiBogatur/Peerster/utils/clock"
+	. "github.com/SubutaiBogatur/Peerster/utils/send-utils"
+	"github.com/dedis/protobuf"
+	log "github.com/sirupsen/logrus"
+)
+
+// Harness for integration tests: starts several gossipers in one process on a simulated network with
+// declared topology, drives them through their client ports (exactly as cli client does) and records
+// every event of every node and every packet delivered between nodes. Tests wait for typed events, packets
+// and for nodes' state with deadlines, instead of sleeping for fixed time and grepping stdout afterwards
+
+const (
+	DefaultWaitTimeout = 20 * time.Second
+	DefaultTimeStep    = 100 * time.Millisecond
+	pollInterval       = 10 * time.Millisecond
+)
+
+// node name -> names of nodes, which it knows on start (as -peers flag)
+type Topology map[string][]string
+
+// every node knows the next one, last knows the first, as in the original test scripts
+func Ring(names ...string) Topology {
+	topology := make(Topology)
+	for i, name := range names {
+		topology[name] = []string{names[(i+1)%len(names)]}
+	}
+	return topology
+}
+
+type Options struct {
+	Seed      int64
+	Link      LinkConfig                               // default link of simulated network
+	Configure func(name string, opts *GossiperOptions) // can be nil, tunes options of every node before start
+
+	// if set, all the nodes and simulated network share this clock. Fake clock is advanced by TimeStep on every poll of WaitFor,
+	// so protocol timeouts pass much faster, than in reality
+	Clock    clock.Clock
+	TimeStep time.Duration // DefaultTimeStep, if 0
+}
+
+// event, published by a node
+type NodeEvent struct {
+	Node  string
+	Event Event
+}
+
+// packet, which was delivered from one node to another. Batches are unpacked, every packet of a batch is a delivery
+type Delivery struct {
+	From    string // node names
+	To      string
+	Packet  *GossipPacket
+	InBatch bool // packet came in one datagram with others
+}
+
+type Network struct {
+	t   testing.TB
+	Sim *SimulatedNetwork
+
+	fakeClock *clock.FakeClock // nil, if nodes use real clock
+	timeStep  time.Duration
+
+	nodes map[string]*Node
+	names map[string]string // peer address -> node name
+
+	events     []*NodeEvent
+	deliveries []*Delivery
+	recordsMux sync.Mutex // protects both events and deliveries
+}
+
+type Node struct {
+	Name     string
+	Gossiper *Gossiper
+	network  *Network
+}
+
+// starts one gossiper per topology node, all of them are stopped, when test finishes
+func StartNetwork(t testing.TB, topology Topology, opts Options) *Network {
+	t.Helper()
+
+	if !testing.Verbose() {
+		log.SetLevel(log.WarnLevel)
+	}
+
+	networkClock := opts.Clock
+	if networkClock == nil {
+		networkClock = clock.NewRealClock()
+	}
+	n := &Network{t: t, Sim: NewSimulatedNetwork(opts.Seed, networkClock), nodes: make(map[string]*Node), names: make(map[string]string)}
+	t.Cleanup(n.Sim.Close) // cleanups run in reverse, so network is closed after all the gossipers are stopped
+	n.Sim.SetDefaultLink(opts.Link)
+	n.fakeClock, _ = opts.Clock.(*clock.FakeClock)
+	n.timeStep = opts.TimeStep
+	if n.timeStep == 0 {
+		n.timeStep = DefaultTimeStep
+	}
+
+	names := make([]string, 0, len(topology))
+	for name := range topology {
+		names = append(names, name)
+	}
+	sort.Strings(names) // addresses are given in the same order every run
+
+	transports := make(map[string]*SimulatedTransport)
+	for _, name := range names {
+		st, err := n.Sim.Listen("")
+		if err != nil {
+			t.Fatal("unable to listen in simulated network: " + err.Error())
+		}
+		transports[name] = st
+		n.names[st.LocalAddr().String()] = name
+	}
+
+	// observer is set before any node starts, so no delivery is missed
+	n.Sim.SetObserver(n.observe)
+
+	for _, name := range names {
+		peers := make([]string, 0)
+		for _, peer := range topology[name] {
+			st, ok := transports[peer]
+			if !ok {
+				t.Fatal("topology mentions unknown node " + peer)
+			}
+			peers = append(peers, st.LocalAddr().String())
+		}
+
+		gossiperOpts := GossiperOptions{
+			Name:      name,
+			Peers:     strings.Join(peers, ","),
+			DataDir:   t.TempDir(),
+			Transport: transports[name],
+			Clock:     opts.Clock,
+
+			NoPeerExchange: true, // neighbours are exactly the topology, unless test turns peer exchange on
+		}
+		if opts.Configure != nil {
+			opts.Configure(name, &gossiperOpts)
+		}
+
+		g, err := NewGossiper(gossiperOpts)
+		if err != nil {
+			t.Fatal("unable to create gossiper " + name + ": " + err.Error())
+		}
+		n.nodes[name] = &Node{Name: name, Gossiper: g, network: n}
+
+		nodeName := name
+		g.Subscribe(func(e Event) {
+			n.recordsMux.Lock()
+			defer n.recordsMux.Unlock()
+			n.events = append(n.events, &NodeEvent{Node: nodeName, Event: e})
+		})
+	}
+
+	for _, name := range names {
+		g := n.nodes[name].Gossiper
+		go g.Run(context.Background())
+		t.Cleanup(g.Stop)
+	}
+
+	return n
+}
+
+// is called by simulated network under its lock
+func (n *Network) observe(from *net.UDPAddr, to *net.UDPAddr, data []byte) {
+	gp := &GossipPacket{}
+	if err := protobuf.Decode(data, gp); err != nil {
+		return // not a gossip packet, nothing to record
+	}
+
+	n.recordsMux.Lock()
+	defer n.recordsMux.Unlock()
+	if gp.Batch == nil {
+		n.deliveries = append(n.deliveries, &Delivery{From: n.names[from.String()], To: n.names[to.String()], Packet: gp})
+		return
+	}
+	for _, packet := range gp.Batch.Packets {
+		n.deliveries = append(n.deliveries, &Delivery{From: n.names[from.String()], To: n.names[to.String()], Packet: packet, InBatch: true})
+	}
+}
+
+func (n *Network) Node(name string) *Node {
+	node, ok := n.nodes[name]
+	if !ok {
+		n.t.Fatal("no node " + name + " in the network")
+	}
+	return node
+}
+
+// waits until condition becomes true, fails the test after DefaultWaitTimeout of real time
+func (n *Network) WaitFor(description string, condition func() bool) {
+	n.t.Helper()
+
+	deadline := time.Now().Add(DefaultWaitTimeout)
+	for !condition() {
+		if time.Now().After(deadline) {
+			n.t.Fatal("timeout waiting for: " + description)
+		}
+		time.Sleep(pollInterval)
+		if n.fakeClock != nil {
+			n.fakeClock.Advance(n.timeStep)
+		}
+	}
+}
+
+// waits until some packet, satisfying the predicate, is delivered (or was delivered before the call)
+func (n *Network) WaitForDelivery(description string, matches func(d *Delivery) bool) *Delivery {
+	n.t.Helper()
+
+	var found *Delivery
+	checked := 0
+	n.WaitFor(description, func() bool {
+		n.recordsMux.Lock()
+		defer n.recordsMux.Unlock()
+
+		for ; checked < len(n.deliveries); checked++ {
+			if matches(n.deliveries[checked]) {
+				found = n.deliveries[checked]
+				return true
+			}
+		}
+		return false
+	})
+	return found
+}
+
+// waits until some node publishes an event, satisfying the predicate (or published it before the call)
+func (n *Network) WaitForEvent(description string, matches func(e *NodeEvent) bool) *NodeEvent {
+	n.t.Helper()
+
+	var found *NodeEvent
+	checked := 0
+	n.WaitFor(description, func() bool {
+		n.recordsMux.Lock()
+		defer n.recordsMux.Unlock()
+
+		for ; checked < len(n.events); checked++ {
+			if matches(n.events[checked]) {
+				found = n.events[checked]
+				return true
+			}
+		}
+		return false
+	})
+	return found
+}
+
+func (n *Network) GetEventsCopy() []*NodeEvent {
+	n.recordsMux.Lock()
+	defer n.recordsMux.Unlock()
+
+	ret := make([]*NodeEvent, len(n.events))
+	copy(ret, n.events)
+	return ret
+}
+
+func (n *Network) GetDeliveriesCopy() []*Delivery {
+	n.recordsMux.Lock()
+	defer n.recordsMux.Unlock()
+
+	ret := make([]*Delivery, len(n.deliveries))
+	copy(ret, n.deliveries)
+	return ret
+}
+
+// client api, same messages as cli client sends:
+
+func (node *Node) clientPort() int {
+	return node.Gossiper.GetClientAddress().Port
+}
+
+func (node *Node) SendRumor(text string) {
+	SendRumorMessageToLocalPort(text, node.clientPort(), nil)
+}
+
+func (node *Node) SendPrivate(destination string, text string) {
+	SendPrivateMessageToLocalPort(text, destination, node.clientPort(), nil)
+}
+
+// file should be already situated in node's shared files directory
+func (node *Node) Share(fileName string) {
+	SendToShareMessageToLocalPort(fileName, node.clientPort(), nil)
+}
+
+// if destination is "", file is downloaded from the nodes found by previous search
+func (node *Node) Download(fileName string, hashString string, destination string) {
+	SendToDownloadMessageToLocalPort(fileName, hashString, destination, node.clientPort(), nil)
+}
+
+// budget 0 means gradually increasing budget
+func (node *Node) Search(keywords []string, budget uint64) {
+	SendToSearchMessaageToLocalPort(keywords, budget, node.clientPort(), nil)
+}
+
+func (node *Node) Ping(destination string) {
+	SendToProbeMessageToLocalPort(destination, false, node.clientPort(), nil)
+}
+
+func (node *Node) Traceroute(destination string) {
+	SendToProbeMessageToLocalPort(destination, true, node.clientPort(), nil)
+}
+
+// helpers to inspect node state:
+
+func (node *Node) Address() string {
+	return node.Gossiper.GetPeerAddress().String()
+}
+
+func (node *Node) KnowsOrigin(origin string) bool {
+	for _, o := range *node.Gossiper.GetOriginsCopy() {
+		if o == origin {
+			return true
+		}
+	}
+	return false
+}
+
+func (node *Node) KnowsPeer(other *Node) bool {
+	for _, peer := range node.Gossiper.GetPeersCopy() {
+		if peer.String() == other.Address() {
+			return true
+		}
+	}
+	return false
+}
+
+func (node *Node) HasRumor(origin string, text string) bool {
+	for _, rmsg := range *node.Gossiper.GetRumorMessages() {
+		if rmsg.OriginalName == origin && rmsg.Text == text {
+			return true
+		}
+	}
+	return false
+}
+
+func (node *Node) HasPrivate(origin string, text string) bool {
+	for _, pmsg := range *node.Gossiper.GetPrivateMessages() {
+		if pmsg.Origin == origin && pmsg.Text == text {
+			return true
+		}
+	}
+	return false
+}
+
+// puts file to node's shared files directory, so it can be shared later
+func (node *Node) WriteSharedFile(fileName string, data []byte) {
+	err := ioutil.WriteFile(filepath.Join(node.Gossiper.GetSharedFilesPath(), fileName), data, 0644)
+	if err != nil {
+		node.network.t.Fatal("unable to write shared file: " + err.Error())
+	}
+}
+
+// shares file, which is already in node's shared files directory, and returns its hex metahash
+func (node *Node) ShareAndWait(fileName string) string {
+	node.Share(fileName)
+	e := node.network.WaitForEvent(fileName+" is shared at "+node.Name, func(e *NodeEvent) bool {
+		shared, ok := e.Event.(FileSharedEvent)
+		return ok && e.Node == node.Name && shared.Name == fileName
+	})
+	metahash := e.Event.(FileSharedEvent).MetaHash
+	return hex.EncodeToString(metahash[:])
+}
+
+// waits until file is reconstructed at node and checks its content
+func (node *Node) WaitForDownloadedFile(fileName string, expected []byte) {
+	t := node.network.t
+	t.Helper()
+
+	node.network.WaitForEvent(fileName+" is reconstructed at "+node.Name, func(e *NodeEvent) bool {
+		reconstructed, ok := e.Event.(FileReconstructedEvent)
+		return ok && e.Node == node.Name && reconstructed.Name == fileName
+	})
+
+	data, err := ioutil.ReadFile(filepath.Join(node.Gossiper.GetDownloadsPath(), fileName))
+	if err != nil {
+		t.Fatal("unable to read downloaded file: " + err.Error())
+	}
+	if !bytes.Equal(data, expected) {
+		t.Fatal("downloaded file " + fileName + " at " + node.Name + " differs from the shared one")
+	}
+}
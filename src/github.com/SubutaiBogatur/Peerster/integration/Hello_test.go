@@ -0,0 +1,79 @@
+package integration
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/SubutaiBogatur/Peerster/config"
+	. "github.com/SubutaiBogatur/Peerster/models"
+	. "github.com/SubutaiBogatur/Peerster/models/negotiation"
+	"github.com/SubutaiBogatur/Peerster/utils/clock"
+	"github.com/dedis/protobuf"
+)
+
+func TestPeersExchangeHellos(t *testing.T) {
+	n := StartNetwork(t, Topology{"a": {"b"}, "b": {"a"}}, Options{Seed: 16})
+	a, b := n.Node("a"), n.Node("b")
+
+	n.WaitFor("a and b know capabilities of each other", func() bool {
+		ofB, knowsB := a.Gossiper.GetPeersCapabilities()[b.Address()]
+		ofA, knowsA := b.Gossiper.GetPeersCapabilities()[a.Address()]
+		return knowsA && knowsB && ofA.Version == ProtocolVersion && ofB.Version == ProtocolVersion
+	})
+	for _, capability := range a.Gossiper.GetPeersCapabilities()[b.Address()].Capabilities {
+		if capability == SecureLinksCapability {
+			t.Fatal("b says it has secure links, though they are off")
+		}
+	}
+}
+
+// peer of the first protocol version never answers to hellos, so it gets no batches. It could get fragments only
+// instead of packets, which don't fit into datagram, but rumors fit
+func TestLegacyPeerGetsOnlyFirstVersionPackets(t *testing.T) {
+	n := StartNetwork(t, Topology{"b": {}}, Options{Seed: 17, Clock: clock.NewFakeClock(time.Unix(0, 0))})
+	b := n.Node("b")
+
+	legacy, err := n.Sim.Listen("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer legacy.Close()
+	go func() {
+		buffer := make([]byte, MaxPacketSize)
+		for {
+			if _, _, err := legacy.ReadFrom(buffer); err != nil {
+				return
+			}
+		}
+	}()
+
+	statusBytes, _ := protobuf.Encode(&GossipPacket{Status: &StatusPacket{}})
+	if _, err := legacy.WriteTo(statusBytes, b.Gossiper.GetPeerAddress()); err != nil {
+		t.Fatal(err)
+	}
+
+	n.WaitFor("b greets silent legacy peer again", func() bool {
+		hellos := 0
+		for _, d := range n.GetDeliveriesCopy() {
+			if d.From == "b" && d.To == "" && d.Packet.Hello != nil {
+				hellos++
+			}
+		}
+		return hellos >= 2
+	})
+
+	for i := 0; i < 10; i++ {
+		b.SendRumor("OldButGold")
+	}
+	n.WaitForDelivery("b sends rumor to legacy peer", func(d *Delivery) bool {
+		return d.From == "b" && d.To == "" && d.Packet.Rumor != nil
+	})
+	for _, d := range n.GetDeliveriesCopy() {
+		if d.To == "" && (d.InBatch || d.Packet.Fragment != nil) {
+			t.Fatal("legacy peer gets packets of the second protocol version")
+		}
+	}
+	if _, ok := b.Gossiper.GetPeersCapabilities()[legacy.LocalAddr().String()]; ok {
+		t.Fatal("capabilities of legacy peer are known")
+	}
+}
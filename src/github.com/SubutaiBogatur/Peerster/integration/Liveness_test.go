@@ -0,0 +1,59 @@
+package integration
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/SubutaiBogatur/Peerster/config"
+	. "github.com/SubutaiBogatur/Peerster/events"
+	. "github.com/SubutaiBogatur/Peerster/models/liveness"
+	. "github.com/SubutaiBogatur/Peerster/transport"
+	"github.com/SubutaiBogatur/Peerster/utils/clock"
+)
+
+// triangle a, b, c: lossy link between a and b is covered by indirect probes through c, isolated c is removed
+// from peers and is added back, when it returns
+func TestDeadPeerIsRemovedAndReadmitted(t *testing.T) {
+	fakeClock := clock.NewFakeClock(time.Unix(0, 0))
+	n := StartNetwork(t, Topology{"a": {"b", "c"}, "b": {"a", "c"}, "c": {"a", "b"}}, Options{Seed: 18, Clock: fakeClock})
+	a, b, c := n.Node("a"), n.Node("b"), n.Node("c")
+
+	n.WaitFor("everyone is probed", func() bool {
+		for _, node := range []*Node{a, b, c} {
+			if len(node.Gossiper.GetPeersLiveness()) != 2 {
+				return false
+			}
+		}
+		return true
+	})
+
+	n.Sim.SetBidirectionalLink(a.Address(), b.Address(), LinkConfig{Loss: 1})
+	start := fakeClock.Now()
+	n.WaitFor("several suspicion timeouts pass", func() bool {
+		return fakeClock.Since(start) > 3*LivenessSuspicionTimeout
+	})
+	for _, e := range n.GetEventsCopy() {
+		if dead, ok := e.Event.(PeerDeadEvent); ok && (dead.Peer.String() == a.Address() || dead.Peer.String() == b.Address()) {
+			t.Fatal(e.Node + " thinks, that " + dead.Peer.String() + " is dead, though it's reachable through c")
+		}
+	}
+	n.Sim.SetBidirectionalLink(a.Address(), b.Address(), LinkConfig{})
+
+	n.Sim.Partition([]string{a.Address(), b.Address()}, []string{c.Address()})
+	n.WaitFor("a and b remove c", func() bool {
+		return !a.KnowsPeer(c) && !b.KnowsPeer(c) &&
+			a.Gossiper.GetPeersLiveness()[c.Address()].Status == DeadPeer && b.Gossiper.GetPeersLiveness()[c.Address()].Status == DeadPeer
+	})
+	if !a.KnowsPeer(b) || !b.KnowsPeer(a) {
+		t.Fatal("alive peers are removed")
+	}
+
+	n.Sim.Heal()
+	n.WaitFor("c is admitted back", func() bool {
+		return a.KnowsPeer(c) && b.KnowsPeer(c) && c.KnowsPeer(a) && c.KnowsPeer(b)
+	})
+	n.WaitForEvent("a sees, that c is alive again", func(e *NodeEvent) bool {
+		alive, ok := e.Event.(PeerAliveEvent)
+		return ok && e.Node == "a" && alive.Peer.String() == c.Address() && alive.Was == DeadPeer
+	})
+}
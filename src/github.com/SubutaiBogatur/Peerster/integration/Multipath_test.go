@@ -0,0 +1,71 @@
+package integration
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/SubutaiBogatur/Peerster/events"
+	. "github.com/SubutaiBogatur/Peerster/gossiper"
+	. "github.com/SubutaiBogatur/Peerster/transport"
+	"github.com/SubutaiBogatur/Peerster/utils/clock"
+)
+
+// diamond a - b - d, a - c - d: link from a to the next hop of its best route to d is cut, so data request through
+// it gets no reply, then the route is marked failed and downloading goes on through the other one
+func TestDownloadingFailsOverToAlternativeRoute(t *testing.T) {
+	n := StartNetwork(t, Topology{"a": {"b", "c"}, "b": {"a", "d"}, "c": {"a", "d"}, "d": {"b", "c"}}, Options{
+		Seed:  16,
+		Clock: clock.NewFakeClock(time.Unix(0, 0)),
+		Configure: func(name string, opts *GossiperOptions) {
+			opts.RouteRumorTimer = 10000 // route to d changes only with rumors of the test
+		},
+	})
+	a, d := n.Node("a"), n.Node("d")
+
+	// rumors come first through b or c, so after several of them a and d know both routes to each other.
+	// Replies of d don't fail over, so they should go not through the cut link
+	for i := 0; ; i++ {
+		toD, toA := a.Gossiper.GetRoutingTable()["d"], d.Gossiper.GetRoutingTable()["a"]
+		if len(toD) == 2 && len(toA) == 2 && toD[0].NextHop.String() != toA[0].NextHop.String() {
+			break
+		}
+		if i == 30 {
+			t.Fatal("a and d don't learn disjoint routes to each other")
+		}
+		textA, textD := fmt.Sprintf("I_am_a_%d", i), fmt.Sprintf("I_am_d_%d", i)
+		a.SendRumor(textA)
+		d.SendRumor(textD)
+		n.WaitFor("a and d get "+textA+" and "+textD, func() bool {
+			return a.HasRumor("d", textD) && d.HasRumor("a", textA)
+		})
+	}
+
+	routes := a.Gossiper.GetRoutingTable()["d"]
+	best, alternative := routes[0].NextHop, routes[1].NextHop
+	n.Sim.SetBidirectionalLink(a.Address(), best.String(), LinkConfig{Loss: 1})
+
+	content := randomContent(16, 20*1024)
+	d.WriteSharedFile("diamond.txt", content)
+	hash := d.ShareAndWait("diamond.txt")
+	a.Download("a-downloaded-diamond.txt", hash, "d")
+	a.WaitForDownloadedFile("a-downloaded-diamond.txt", content)
+
+	n.WaitForEvent("a marks the cut route failed", func(e *NodeEvent) bool {
+		failed, ok := e.Event.(RouteFailedEvent)
+		return ok && e.Node == "a" && failed.Origin == "d" && failed.NextHop.String() == best.String()
+	})
+	n.WaitForDelivery("data reply comes by the alternative route", func(delivery *Delivery) bool {
+		return delivery.To == "a" && delivery.From == n.names[alternative.String()] && delivery.Packet.DataReply != nil
+	})
+	if routes := a.Gossiper.GetRoutingTable()["d"]; !routes[0].Failed || routes[1].Failed {
+		t.Fatalf("unexpected routes from a to d: %+v", routes)
+	}
+
+	// private message to unknown destination is reported, not lost silently
+	a.SendPrivate("nobody", "AnybodyHere?")
+	n.WaitForEvent("a reports unroutable private message", func(e *NodeEvent) bool {
+		unroutable, ok := e.Event.(UnroutableEvent)
+		return ok && e.Node == "a" && unroutable.Destination == "nobody"
+	})
+}
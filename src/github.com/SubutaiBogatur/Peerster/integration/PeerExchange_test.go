@@ -0,0 +1,53 @@
+package integration
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	. "github.com/SubutaiBogatur/Peerster/events"
+	. "github.com/SubutaiBogatur/Peerster/gossiper"
+	"github.com/SubutaiBogatur/Peerster/utils/clock"
+)
+
+// every leaf of a star knows only the hub on start and finds other leaves through it
+func TestLeavesOfStarFindEachOther(t *testing.T) {
+	const targetDegree = 3
+	n := StartNetwork(t, Topology{"hub": {}, "a": {"hub"}, "b": {"hub"}, "c": {"hub"}, "d": {"hub"}, "e": {"hub"}}, Options{
+		Seed:  19,
+		Clock: clock.NewFakeClock(time.Unix(0, 0)),
+		Configure: func(name string, opts *GossiperOptions) {
+			opts.NoPeerExchange = false
+			opts.TargetDegree = targetDegree
+		},
+	})
+	leaves := []string{"a", "b", "c", "d", "e"}
+
+	n.WaitFor("every leaf has target degree", func() bool {
+		for _, leaf := range leaves {
+			if len(n.Node(leaf).Gossiper.GetPeersCopy()) < targetDegree {
+				return false
+			}
+		}
+		return true
+	})
+
+	discovered := make(map[string]int)
+	for _, e := range n.GetEventsCopy() {
+		if d, ok := e.Event.(PeerDiscoveredEvent); ok {
+			if n.names[d.Peer.String()] == "" || d.Peer.String() == n.Node(e.Node).Address() {
+				t.Fatal(e.Node + " discovered unknown address " + d.Peer.String())
+			}
+			discovered[e.Node]++
+		}
+	}
+	// leaf can also be added by others, when they contact it, so some leaves discover nobody
+	for _, leaf := range leaves {
+		if discovered[leaf] > targetDegree-1 {
+			t.Fatal(leaf + " discovered " + strconv.Itoa(discovered[leaf]) + " peers, though it knew 1 and wanted " + strconv.Itoa(targetDegree))
+		}
+	}
+	if discovered["hub"] != 0 {
+		t.Fatal("hub discovered peers, though all the leaves contacted it themselves")
+	}
+}
@@ -0,0 +1,55 @@
+package integration
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/SubutaiBogatur/Peerster/gossiper"
+	"github.com/SubutaiBogatur/Peerster/utils/clock"
+)
+
+// line a - b - c: restarted a without -peers rejoins through b and reaches c by the restored route
+func TestRestartedNodeRejoinsFromAddressBook(t *testing.T) {
+	fakeClock := clock.NewFakeClock(time.Unix(0, 0))
+	dataDir := t.TempDir()
+	keyFile := filepath.Join(dataDir, "a.key") // c accepts messages of a only with the same key
+	n := StartNetwork(t, Topology{"a": {"b"}, "b": {"c"}, "c": {}}, Options{
+		Seed:  20,
+		Clock: fakeClock,
+		Configure: func(name string, opts *GossiperOptions) {
+			if name == "a" {
+				opts.DataDir = dataDir
+				opts.KeyFile = keyFile
+			}
+		},
+	})
+	a, c := n.Node("a"), n.Node("c")
+
+	c.SendRumor("hello from c")
+	n.WaitFor("a knows origin c", func() bool { return a.KnowsOrigin("c") })
+	a.SendRumor("hello from a")
+	n.WaitFor("c knows origin a", func() bool { return c.KnowsOrigin("a") })
+
+	address := a.Address()
+	a.Gossiper.Stop()
+
+	transport, err := n.Sim.Listen(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := NewGossiper(GossiperOptions{Name: "a", DataDir: dataDir, KeyFile: keyFile, Transport: transport, Clock: fakeClock, NoPeerExchange: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	restarted := &Node{Name: "a", Gossiper: g, network: n}
+	if !restarted.KnowsPeer(n.Node("b")) || !restarted.KnowsOrigin("c") {
+		t.Fatal("restarted a doesn't remember b and c behind it")
+	}
+	go g.Run(context.Background())
+	t.Cleanup(g.Stop)
+
+	restarted.SendPrivate("c", "hello after restart")
+	n.WaitFor("c gets private from restarted a", func() bool { return c.HasPrivate("a", "hello after restart") })
+}
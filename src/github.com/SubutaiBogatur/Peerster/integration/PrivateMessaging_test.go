@@ -0,0 +1,107 @@
+package integration
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/SubutaiBogatur/Peerster/gossiper"
+)
+
+// line of nodes a - b - c - d, a and d send private messages to each other through b and c
+func TestPrivateMessagingInLine(t *testing.T) {
+	n := StartNetwork(t, lineTopology(), Options{
+		Seed: 3,
+		Configure: func(name string, opts *GossiperOptions) {
+			// anti-entropy is kept on, otherwise rumor can die out after coin flip before reaching everyone
+			opts.RouteRumorTimer = 100
+		},
+	})
+	a, b, c, d := n.Node("a"), n.Node("b"), n.Node("c"), n.Node("d")
+
+	// let a and d know routes to each other
+	a.SendRumor("LetsKnowEachOther1")
+	d.SendRumor("LetsKnowEachOther2")
+	n.WaitFor("a and d know routes to each other", func() bool {
+		return a.KnowsOrigin("d") && d.KnowsOrigin("a")
+	})
+
+	// the only route in the line is the longest one
+	routes := a.Gossiper.GetRoutingTable()["d"]
+	if len(routes) != 1 || routes[0].NextHop.String() != b.Address() || routes[0].HopCount != 3 || routes[0].SeqNumber == 0 {
+		t.Fatalf("unexpected routes from a to d: %+v", routes)
+	}
+
+	a.SendPrivate("d", "WhyAreYouSoRight?")
+	d.SendPrivate("a", "BecauseIDontLikeCommies!")
+	a.SendPrivate("d", "ButHelpingOtherIsGood!")
+	d.SendPrivate("a", "AreYouSure?ICareOnlyAboutMyself")
+	c.SendRumor("StopArguingGuys!")
+	b.SendRumor("CIsRight,CentristsAreBest")
+	a.SendPrivate("d", "WeWillStillMakeRevolutionInYourLands")
+	d.SendPrivate("a", "Try")
+
+	n.WaitFor("rumors of b and c arrive", func() bool {
+		return a.HasRumor("b", "CIsRight,CentristsAreBest") && c.HasRumor("b", "CIsRight,CentristsAreBest") &&
+			a.HasRumor("c", "StopArguingGuys!") && d.HasRumor("c", "StopArguingGuys!")
+	})
+
+	n.WaitFor("private messages arrive", func() bool {
+		return a.HasPrivate("d", "BecauseIDontLikeCommies!") && a.HasPrivate("d", "Try") &&
+			d.HasPrivate("a", "ButHelpingOtherIsGood!") && d.HasPrivate("a", "WeWillStillMakeRevolutionInYourLands")
+	})
+
+	// private messages are never shown to the nodes in between
+	if b.HasPrivate("a", "WhyAreYouSoRight?") || c.HasPrivate("d", "Try") {
+		t.Fatal("private message is stored by relaying node")
+	}
+}
+
+// the same line, but relays b and c see only ciphertext of private messages
+func TestEncryptedPrivateMessagingInLine(t *testing.T) {
+	n := StartNetwork(t, lineTopology(), Options{
+		Seed: 13,
+		Configure: func(name string, opts *GossiperOptions) {
+			opts.RouteRumorTimer = 100
+			opts.EncryptPrivate = true
+		},
+	})
+	a, d := n.Node("a"), n.Node("d")
+
+	// rumors bring not only routes, but also keys
+	a.SendRumor("LetsKnowEachOther1")
+	d.SendRumor("LetsKnowEachOther2")
+	n.WaitFor("a and d know routes to each other", func() bool {
+		return a.KnowsOrigin("d") && d.KnowsOrigin("a")
+	})
+
+	a.SendPrivate("d", "MeetMeAtMidnight")
+	d.SendPrivate("a", "IWillBeThere")
+	n.WaitFor("private messages arrive decrypted", func() bool {
+		return d.HasPrivate("a", "MeetMeAtMidnight") && a.HasPrivate("d", "IWillBeThere")
+	})
+
+	privates := 0
+	for _, delivery := range n.GetDeliveriesCopy() {
+		pmsg := delivery.Packet.Private
+		if pmsg == nil {
+			continue
+		}
+		privates++
+		if pmsg.Text != "" || !pmsg.IsEncrypted() || bytes.Contains(pmsg.Ciphertext, []byte("Midnight")) || bytes.Contains(pmsg.Ciphertext, []byte("There")) {
+			t.Fatal("private message from " + delivery.From + " to " + delivery.To + " is readable on the wire")
+		}
+	}
+	if privates != 6 {
+		t.Fatalf("expected 6 hops of private messages, got %d", privates)
+	}
+}
+
+// a - b - c - d
+func lineTopology() Topology {
+	return Topology{
+		"a": {"b"},
+		"b": {"a", "c"},
+		"c": {"b", "d"},
+		"d": {"c"},
+	}
+}
@@ -0,0 +1,121 @@
+package integration
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+
+	. "github.com/SubutaiBogatur/Peerster/config"
+	. "github.com/SubutaiBogatur/Peerster/gossiper"
+	. "github.com/SubutaiBogatur/Peerster/models"
+	"github.com/dedis/protobuf"
+)
+
+// rumors of mallory come to b in reverse order, they are shown only when the first one comes, and then all in order.
+// All of them are sent further then, and c gets them
+func TestRumorsOutOfOrderAreBuffered(t *testing.T) {
+	n := StartNetwork(t, Topology{"b": {"c"}, "c": {"b"}}, Options{
+		Seed: 22,
+		Configure: func(name string, opts *GossiperOptions) {
+			opts.NoAntiEntropy = name == "b" // only mongering of b spreads rumors of mallory, c asks for those, which are lost
+		},
+	})
+	b, c := n.Node("b"), n.Node("c")
+
+	// mallory stays in the network, so that statuses of b are delivered to it
+	mallory, err := n.Sim.Listen("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mallory.Close()
+	go func() {
+		buffer := make([]byte, MaxPacketSize)
+		for {
+			if _, _, err := mallory.ReadFrom(buffer); err != nil {
+				return
+			}
+		}
+	}()
+
+	malloryKey, malloryPrivateKey, _ := ed25519.GenerateKey(rand.Reader)
+	texts := []string{"first", "second", "third"}
+	for i := len(texts) - 1; i >= 0; i-- {
+		rmsg := &RumorMessage{OriginalName: "mallory", ID: uint32(i + 1), Text: texts[i], PublicKey: malloryKey}
+		rmsg.Signature = ed25519.Sign(malloryPrivateKey, rmsg.SignedData())
+		packetBytes, _ := protobuf.Encode(&GossipPacket{Rumor: rmsg})
+		// status is sent back before the rumor is processed, so it's the status for the copy, which has rumor buffered
+		for copies := 0; copies < 2; copies++ {
+			if _, err := mallory.WriteTo(packetBytes, b.Gossiper.GetPeerAddress()); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if i > 0 {
+			n.WaitFor("b answers rumor "+texts[i]+" with status, which has it buffered", func() bool {
+				return statusesWithBuffered(n, "b", "mallory", uint32(i+1)) > 0
+			})
+			if b.HasRumor("mallory", texts[i]) {
+				t.Fatal("rumor " + texts[i] + " is shown before the previous ones")
+			}
+		}
+	}
+
+	n.WaitFor("b shows all the rumors", func() bool { return b.HasRumor("mallory", texts[0]) && b.HasRumor("mallory", texts[2]) })
+	shown := make([]string, 0)
+	for _, rmsg := range *b.Gossiper.GetRumorMessages() {
+		if rmsg.OriginalName == "mallory" {
+			shown = append(shown, rmsg.Text)
+		}
+	}
+	if len(shown) != len(texts) || shown[0] != texts[0] || shown[1] != texts[1] || shown[2] != texts[2] {
+		t.Fatal("rumors are not shown in order")
+	}
+
+	for _, text := range texts {
+		text := text
+		n.WaitFor("b sends "+text+" to c", func() bool { return sentRumor(n, "b", "c", "mallory", text) })
+		n.WaitFor("c gets "+text, func() bool { return c.HasRumor("mallory", text) })
+	}
+}
+
+// rumor is either mongered alone or sent in a bundle, if the peer lacks many
+func sentRumor(n *Network, from string, to string, origin string, text string) bool {
+	for _, d := range n.GetDeliveriesCopy() {
+		if d.From != from || d.To != to {
+			continue
+		}
+		rumors := make([]*RumorMessage, 0)
+		if d.Packet.Rumor != nil {
+			rumors = append(rumors, d.Packet.Rumor)
+		} else if d.Packet.RumorBundle != nil {
+			rumors = d.Packet.RumorBundle.Rumors
+		}
+		for _, rmsg := range rumors {
+			if rmsg != nil && rmsg.OriginalName == origin && rmsg.Text == text {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// statuses sent by the node, where nothing of the origin is added, and the id is buffered
+func statusesWithBuffered(n *Network, from string, origin string, id uint32) int {
+	count := 0
+	for _, d := range n.GetDeliveriesCopy() {
+		if d.From != from || d.Packet.Status == nil {
+			continue
+		}
+		for _, peerStatus := range d.Packet.Status.Want {
+			if peerStatus.Identifier != origin || peerStatus.NextID != 1 {
+				continue
+			}
+			for _, buffered := range peerStatus.Buffered {
+				if buffered == id {
+					count++
+				}
+			}
+		}
+	}
+	return count
+}
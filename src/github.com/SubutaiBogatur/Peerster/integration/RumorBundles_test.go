@@ -0,0 +1,51 @@
+package integration
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	. "github.com/SubutaiBogatur/Peerster/config"
+	"github.com/SubutaiBogatur/Peerster/utils/clock"
+)
+
+// b misses 200 rumors of a during partition and gets them in bundles in a few anti-entropy rounds, not in 200
+func TestPeerCatchesUpInBundlesAfterPartition(t *testing.T) {
+	const rumors = 200
+	fakeClock := clock.NewFakeClock(time.Unix(0, 0))
+	n := StartNetwork(t, Topology{"a": {"b"}, "b": {"a"}}, Options{Seed: 23, Clock: fakeClock})
+	a, b := n.Node("a"), n.Node("b")
+
+	a.SendRumor("before partition")
+	n.WaitFor("b gets the first rumor", func() bool { return b.HasRumor("a", "before partition") })
+
+	n.Sim.Partition([]string{a.Address()}, []string{b.Address()})
+	for i := 0; i < rumors; i++ {
+		a.SendRumor("rumor " + strconv.Itoa(i))
+	}
+	n.WaitFor("a has all the rumors", func() bool { return a.HasRumor("a", "rumor "+strconv.Itoa(rumors-1)) })
+	n.Sim.Heal()
+
+	healed := fakeClock.Now()
+	n.WaitFor("b catches up", func() bool {
+		for i := 0; i < rumors; i++ {
+			if !b.HasRumor("a", "rumor "+strconv.Itoa(i)) {
+				return false
+			}
+		}
+		return true
+	})
+	if rounds := fakeClock.Since(healed) / AntiEntropyTimeout; rounds > 10 {
+		t.Fatal("b catches up in " + strconv.Itoa(int(rounds)) + " anti-entropy rounds")
+	}
+
+	bundles := 0
+	for _, d := range n.GetDeliveriesCopy() {
+		if d.From == "a" && d.To == "b" && d.Packet.RumorBundle != nil {
+			bundles++
+		}
+	}
+	if bundles == 0 {
+		t.Fatal("rumors are not bundled")
+	}
+}
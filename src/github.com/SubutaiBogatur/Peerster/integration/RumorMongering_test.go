@@ -0,0 +1,76 @@
+package integration
+
+import (
+	"testing"
+
+	. "github.com/SubutaiBogatur/Peerster/events"
+	. "github.com/SubutaiBogatur/Peerster/gossiper"
+	. "github.com/SubutaiBogatur/Peerster/models"
+)
+
+// 10 nodes in a ring, rumors from E, B and G should reach everyone with rumor-mongering and anti-entropy
+func TestRumorMongeringInRing(t *testing.T) {
+	n := StartNetwork(t, Ring(ringNames...), Options{
+		Seed: 2,
+		Configure: func(name string, opts *GossiperOptions) {
+			opts.NoStatusDigests = true // full statuses are checked, as in the original test scripts
+		},
+	})
+
+	rumors := []struct{ origin, text string }{
+		{"E", "Weather_is_clear"},
+		{"B", "Winter_is_coming"},
+		{"E", "No_clouds_really"},
+		{"B", "Let's_go_skiing"},
+		{"G", "Is_anybody_here?"},
+	}
+	for _, r := range rumors {
+		n.Node(r.origin).SendRumor(r.text)
+	}
+
+	expectedWant := map[string]uint32{"E": 3, "B": 3, "G": 2}
+
+	for i, name := range ringNames {
+		node := n.Node(name)
+		prev := n.Node(ringNames[(i+len(ringNames)-1)%len(ringNames)])
+		next := n.Node(ringNames[(i+1)%len(ringNames)])
+
+		for _, r := range rumors {
+			n.WaitFor(name+" has rumor "+r.text, func() bool {
+				return node.HasRumor(r.origin, r.text)
+			})
+		}
+
+		n.WaitForEvent(name+" mongers with a neighbour", func(e *NodeEvent) bool {
+			mongering, ok := e.Event.(MongeringEvent)
+			return ok && e.Node == name && (mongering.Peer.String() == prev.Address() || mongering.Peer.String() == next.Address())
+		})
+
+		for _, neighbour := range []*Node{prev, next} {
+			n.WaitForEvent(name+" gets full status from "+neighbour.Name, func(e *NodeEvent) bool {
+				packet, ok := e.Event.(PacketEvent)
+				return ok && e.Node == name && packet.From.String() == neighbour.Address() &&
+					packet.Packet.Status != nil && hasNextIDs(packet.Packet.Status, expectedWant)
+			})
+			n.WaitForEvent(name+" is in sync with "+neighbour.Name, func(e *NodeEvent) bool {
+				inSync, ok := e.Event.(InSyncEvent)
+				return ok && e.Node == name && inSync.Peer.String() == neighbour.Address()
+			})
+		}
+
+		n.WaitFor(name+" knows both neighbours", func() bool {
+			return node.KnowsPeer(prev) && node.KnowsPeer(next)
+		})
+	}
+}
+
+// true, if status has all the expected origins with exactly expected next ids
+func hasNextIDs(sp *StatusPacket, expected map[string]uint32) bool {
+	found := 0
+	for _, ps := range sp.Want {
+		if nextID, ok := expected[ps.Identifier]; ok && nextID == ps.NextID {
+			found++
+		}
+	}
+	return found == len(expected)
+}
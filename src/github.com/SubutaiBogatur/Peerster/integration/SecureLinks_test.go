@@ -0,0 +1,66 @@
+package integration
+
+import (
+	"testing"
+
+	. "github.com/SubutaiBogatur/Peerster/events"
+	. "github.com/SubutaiBogatur/Peerster/gossiper"
+	. "github.com/SubutaiBogatur/Peerster/models"
+	. "github.com/SubutaiBogatur/Peerster/transport"
+)
+
+func TestSecureLinksCarryGossip(t *testing.T) {
+	n := StartNetwork(t, Ring("a", "b", "c"), Options{
+		Seed: 14,
+		Link: LinkConfig{Duplication: 0.2}, // duplicates are dropped as replayed
+		Configure: func(name string, opts *GossiperOptions) {
+			opts.SecureLinks = true
+		},
+	})
+	a, b, c := n.Node("a"), n.Node("b"), n.Node("c")
+
+	a.SendRumor("BehindClosedDoors")
+	n.WaitFor("rumor reaches everyone", func() bool {
+		return b.HasRumor("a", "BehindClosedDoors") && c.HasRumor("a", "BehindClosedDoors")
+	})
+
+	for _, delivery := range n.GetDeliveriesCopy() {
+		if delivery.Packet.Handshake == nil && delivery.Packet.Sealed == nil {
+			t.Fatal("datagram from " + delivery.From + " to " + delivery.To + " is not sealed")
+		}
+	}
+	n.WaitForEvent("b authenticates a", func(e *NodeEvent) bool {
+		established, ok := e.Event.(LinkEstablishedEvent)
+		return ok && e.Node == "b" && established.Name == "a"
+	})
+	if b.Gossiper.GetLinks()[a.Address()] != "a" {
+		t.Fatal("b doesn't have secure link with a")
+	}
+
+	// not sealed packet is rejected, even if the rumor in it is signed correctly
+	rumor := &RumorMessage{OriginalName: "a", ID: 1, Text: "BehindClosedDoors"}
+	injectPacket(t, n, c, &GossipPacket{Rumor: rumor})
+	n.WaitForEvent("c rejects not sealed datagram", func(e *NodeEvent) bool {
+		rejected, ok := e.Event.(LinkRejectedEvent)
+		return ok && e.Node == "c" && rejected.Reason == "not sealed"
+	})
+}
+
+func TestPeerWithoutSecureLinkIsRejected(t *testing.T) {
+	n := StartNetwork(t, Topology{"a": {"b"}, "b": {"a"}}, Options{
+		Seed: 15,
+		Configure: func(name string, opts *GossiperOptions) {
+			opts.SecureLinks = name == "b"
+		},
+	})
+	a, b := n.Node("a"), n.Node("b")
+
+	a.SendRumor("LetMeIn")
+	n.WaitForEvent("b rejects a", func(e *NodeEvent) bool {
+		_, ok := e.Event.(LinkRejectedEvent)
+		return ok && e.Node == "b"
+	})
+	if b.HasRumor("a", "LetMeIn") || len(b.Gossiper.GetLinks()) != 0 {
+		t.Fatal("b talks with a, which has no secure link")
+	}
+}
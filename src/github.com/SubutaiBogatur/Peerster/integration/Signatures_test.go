@@ -0,0 +1,110 @@
+package integration
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	. "github.com/SubutaiBogatur/Peerster/events"
+	. "github.com/SubutaiBogatur/Peerster/gossiper"
+	. "github.com/SubutaiBogatur/Peerster/models"
+	"github.com/dedis/protobuf"
+)
+
+// sends packet to the node from an endpoint, which is not a gossiper
+func injectPacket(t *testing.T, n *Network, to *Node, gp *GossipPacket) {
+	t.Helper()
+	st, err := n.Sim.Listen("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+
+	packetBytes, err := protobuf.Encode(gp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.WriteTo(packetBytes, to.Gossiper.GetPeerAddress()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func waitForForged(n *Network, node *Node, origin string) {
+	n.WaitForEvent(node.Name+" rejects forged message of "+origin, func(e *NodeEvent) bool {
+		forged, ok := e.Event.(ForgedMessageEvent)
+		return ok && e.Node == node.Name && forged.Origin == origin
+	})
+}
+
+func TestForgedMessagesAreRejected(t *testing.T) {
+	n := StartNetwork(t, Topology{"a": {"b"}, "b": {"a"}}, Options{Seed: 11})
+	a, b := n.Node("a"), n.Node("b")
+
+	a.SendRumor("I_am_a")
+	n.WaitFor("b learns a", func() bool {
+		return b.HasRumor("a", "I_am_a")
+	})
+
+	malloryKey, malloryPrivateKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	// rumor in the name of a, signed with another key
+	forgedRumor := &RumorMessage{OriginalName: "a", ID: 2, Text: "forged", PublicKey: malloryKey}
+	forgedRumor.Signature = ed25519.Sign(malloryPrivateKey, forgedRumor.SignedData())
+	injectPacket(t, n, b, &GossipPacket{Rumor: forgedRumor})
+	waitForForged(n, b, "a")
+
+	// private message without signature at all
+	injectPacket(t, n, b, &GossipPacket{Private: &PrivateMessage{Origin: "a", Text: "unsigned", Destination: "b", HopLimit: 5}})
+	n.WaitFor("b rejects unsigned private message", func() bool {
+		rejected := 0
+		for _, e := range n.GetEventsCopy() {
+			if _, ok := e.Event.(ForgedMessageEvent); ok && e.Node == "b" {
+				rejected++
+			}
+		}
+		return rejected == 2
+	})
+
+	// new origin is trusted on first use
+	malloryRumor := &RumorMessage{OriginalName: "mallory", ID: 1, Text: "hi", PublicKey: malloryKey}
+	malloryRumor.Signature = ed25519.Sign(malloryPrivateKey, malloryRumor.SignedData())
+	injectPacket(t, n, b, &GossipPacket{Rumor: malloryRumor})
+
+	a.SendRumor("still_me")
+	n.WaitFor("b gets genuine rumors", func() bool {
+		return b.HasRumor("a", "still_me") && b.HasRumor("mallory", "hi")
+	})
+	if b.HasRumor("a", "forged") || b.HasPrivate("a", "unsigned") {
+		t.Fatal("forged message is stored")
+	}
+	if b.Gossiper.GetKnownKeys()["a"] != a.Gossiper.GetPublicKey() {
+		t.Fatal("b doesn't know the key of a")
+	}
+}
+
+func TestPinnedKeyOverridesFirstSeen(t *testing.T) {
+	otherKey, _, _ := ed25519.GenerateKey(rand.Reader)
+	trustFile := filepath.Join(t.TempDir(), "trust")
+	if err := ioutil.WriteFile(trustFile, []byte("a "+hex.EncodeToString(otherKey)+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	n := StartNetwork(t, Topology{"a": {"b"}, "b": {"a"}}, Options{
+		Seed: 12,
+		Configure: func(name string, opts *GossiperOptions) {
+			if name == "b" {
+				opts.TrustFile = trustFile // b expects a to have another key
+			}
+		},
+	})
+	a, b := n.Node("a"), n.Node("b")
+
+	a.SendRumor("I_am_a")
+	waitForForged(n, b, "a")
+	if b.HasRumor("a", "I_am_a") {
+		t.Fatal("rumor signed with not pinned key is stored")
+	}
+}
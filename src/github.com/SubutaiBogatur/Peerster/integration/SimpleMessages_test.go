@@ -0,0 +1,149 @@
+package integration
+
+import (
+	"strconv"
+	"testing"
+
+	. "github.com/SubutaiBogatur/Peerster/config"
+	. "github.com/SubutaiBogatur/Peerster/events"
+	. "github.com/SubutaiBogatur/Peerster/gossiper"
+	. "github.com/SubutaiBogatur/Peerster/models"
+	"github.com/dedis/protobuf"
+)
+
+var ringNames = []string{"A", "B", "C", "D", "E", "F", "G", "H", "I", "J"}
+
+// 10 nodes in a ring in simple mode, E and B broadcast messages, which should reach everyone else
+func TestSimpleMessagesInRing(t *testing.T) {
+	n := StartNetwork(t, Ring(ringNames...), Options{
+		Seed: 1,
+		Configure: func(name string, opts *GossiperOptions) {
+			opts.IsSimpleMode = true
+		},
+	})
+
+	message1 := "Weather_is_clear"
+	message2 := "Winter_is_coming"
+	n.Node("E").SendRumor(message1)
+	n.Node("B").SendRumor(message2)
+
+	for i, name := range ringNames {
+		node := n.Node(name)
+		prev := n.Node(ringNames[(i+len(ringNames)-1)%len(ringNames)])
+		next := n.Node(ringNames[(i+1)%len(ringNames)])
+
+		for _, expected := range []struct{ origin, text string }{{"E", message1}, {"B", message2}} {
+			if name == expected.origin {
+				continue // message doesn't come back to its origin, as every node relays it once
+			}
+			n.WaitForEvent("simple message from "+expected.origin+" at "+name, func(e *NodeEvent) bool {
+				packet, ok := e.Event.(PacketEvent)
+				if !ok || e.Node != name || packet.Packet.Simple == nil {
+					return false
+				}
+				smsg := packet.Packet.Simple
+				return smsg.OriginalName == expected.origin && smsg.Text == expected.text &&
+					(smsg.RelayPeerAddr == prev.Address() || smsg.RelayPeerAddr == next.Address())
+			})
+		}
+
+		n.WaitFor(name+" knows both neighbours", func() bool {
+			return node.KnowsPeer(prev) && node.KnowsPeer(next)
+		})
+	}
+}
+
+// in full mesh every node gets every message from many neighbours, but relays it only once, so flooding stops
+func TestSimpleMessagesInMeshAreRelayedOnce(t *testing.T) {
+	names := []string{"a", "b", "c", "d", "e"}
+	topology := make(Topology)
+	for _, name := range names {
+		for _, other := range names {
+			if other != name {
+				topology[name] = append(topology[name], other)
+			}
+		}
+	}
+	n := StartNetwork(t, topology, Options{
+		Seed: 21,
+		Configure: func(name string, opts *GossiperOptions) {
+			opts.IsSimpleMode = true
+		},
+	})
+
+	n.Node("a").SendRumor("hello mesh")
+	simples := func() int {
+		count := 0
+		for _, d := range n.GetDeliveriesCopy() {
+			if d.Packet.Simple != nil {
+				count++
+			}
+		}
+		return count
+	}
+	// a sends to 4 neighbours, each of the others relays to 3 neighbours except the one it got message from.
+	// When all the copies are processed and sent packets are delivered, every relay, which could happen, has happened
+	n.WaitFor("message is flooded", func() bool {
+		processed := 0
+		for _, e := range n.GetEventsCopy() {
+			if packet, ok := e.Event.(PacketEvent); ok && packet.Packet.Simple != nil {
+				processed++
+			}
+		}
+		return processed >= 4+4*3 && isNetworkQuiet(n)
+	})
+	if simples() != 4+4*3 {
+		t.Fatal("simple message is relayed more than once: " + strconv.Itoa(simples()) + " deliveries")
+	}
+	for _, d := range n.GetDeliveriesCopy() {
+		if d.Packet.Simple != nil && (d.Packet.Simple.ID == 0 || d.Packet.Simple.HopLimit == 0) {
+			t.Fatal("simple message has neither id, nor hop limit")
+		}
+	}
+}
+
+// message of older gossiper has neither id, nor hop limit, the first relay limits it
+func TestLegacySimpleMessageGetsHopLimit(t *testing.T) {
+	n := StartNetwork(t, Topology{"a": {"b"}, "b": {"a", "c"}, "c": {"b"}}, Options{
+		Seed: 27,
+		Configure: func(name string, opts *GossiperOptions) {
+			opts.IsSimpleMode = true
+		},
+	})
+
+	legacy, err := n.Sim.Listen("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer legacy.Close()
+	packetBytes, _ := protobuf.Encode(&GossipPacket{Simple: &SimpleMessage{OriginalName: "legacy", Text: "no_id"}})
+	if _, err := legacy.WriteTo(packetBytes, n.Node("a").Gossiper.GetPeerAddress()); err != nil {
+		t.Fatal(err)
+	}
+
+	n.WaitForDelivery("c gets message of legacy gossiper", func(d *Delivery) bool {
+		return d.To == "c" && d.Packet.Simple != nil && d.Packet.Simple.Text == "no_id"
+	})
+	for _, d := range n.GetDeliveriesCopy() {
+		if d.Packet.Simple == nil || d.Packet.Simple.Text != "no_id" {
+			continue
+		}
+		if expected := map[string]uint32{"b": SimpleHopLimit - 1, "c": SimpleHopLimit - 2}[d.To]; d.Packet.Simple.HopLimit != expected {
+			t.Fatal("hop limit of message to " + d.To + " is " + strconv.Itoa(int(d.Packet.Simple.HopLimit)))
+		}
+	}
+}
+
+// true, if no packet waits in send queues and every packet taken from them is delivered
+func isNetworkQuiet(n *Network) bool {
+	taken := 0
+	for _, node := range n.nodes {
+		for _, stats := range node.Gossiper.GetSendQueuesStats() {
+			if stats.Depth > 0 {
+				return false
+			}
+			taken += int(stats.Sent)
+		}
+	}
+	return taken == len(n.GetDeliveriesCopy())
+}
@@ -0,0 +1,83 @@
+package integration
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/SubutaiBogatur/Peerster/config"
+	. "github.com/SubutaiBogatur/Peerster/models"
+	"github.com/SubutaiBogatur/Peerster/utils/clock"
+	"github.com/dedis/protobuf"
+)
+
+// peers in sync exchange only hashes of vector clocks, peer of the first protocol version still gets full statuses
+func TestPeersInSyncExchangeDigests(t *testing.T) {
+	fakeClock := clock.NewFakeClock(time.Unix(0, 0))
+	n := StartNetwork(t, Topology{"a": {"b"}, "b": {"a", "c"}, "c": {"b"}}, Options{Seed: 24, Clock: fakeClock})
+	a, b, c := n.Node("a"), n.Node("b"), n.Node("c")
+
+	legacy, err := n.Sim.Listen("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer legacy.Close()
+	go func() {
+		buffer := make([]byte, MaxPacketSize)
+		for {
+			if _, _, err := legacy.ReadFrom(buffer); err != nil {
+				return
+			}
+		}
+	}()
+	statusBytes, _ := protobuf.Encode(&GossipPacket{Status: &StatusPacket{}})
+	if _, err := legacy.WriteTo(statusBytes, b.Gossiper.GetPeerAddress()); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, node := range []*Node{a, b, c} {
+		node.SendRumor("hi from " + node.Name)
+		node.SendRumor("bye from " + node.Name)
+	}
+	n.WaitFor("everyone has all the rumors", func() bool {
+		for _, node := range []*Node{a, b, c} {
+			for _, origin := range []string{"a", "b", "c"} {
+				if !node.HasRumor(origin, "bye from "+origin) {
+					return false
+				}
+			}
+		}
+		return true
+	})
+
+	// digests, which were sent before the last rumors came, are still answered for a while
+	start := fakeClock.Now()
+	n.WaitFor("answers to old digests are delivered", func() bool { return fakeClock.Since(start) > 3*AntiEntropyTimeout })
+	synced := len(n.GetDeliveriesCopy())
+	n.WaitFor("b sends some statuses to a and to legacy peer", func() bool {
+		toA, toLegacy := 0, 0
+		for _, d := range n.GetDeliveriesCopy()[synced:] {
+			if d.From == "b" && d.Packet.Status != nil {
+				if d.To == "a" {
+					toA++
+				} else if d.To == "" {
+					toLegacy++
+				}
+			}
+		}
+		return toA >= 3 && toLegacy >= 1
+	})
+
+	for _, d := range n.GetDeliveriesCopy()[synced:] {
+		if d.From != "b" || d.Packet.Status == nil {
+			continue
+		}
+		sp := d.Packet.Status
+		if d.To == "" {
+			if sp.IsDigest() || len(sp.Want) != 3 {
+				t.Fatal("legacy peer gets no full status")
+			}
+		} else if !sp.IsDigest() || len(sp.Want) != 0 || len(sp.Ranges) != 1 || len(sp.Ranges[0].Want) != 0 {
+			t.Fatal("b sends not a digest to " + d.To + ", though they are in sync")
+		}
+	}
+}
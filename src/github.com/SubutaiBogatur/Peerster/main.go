@@ -1,27 +1,48 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	. "github.com/SubutaiBogatur/Peerster/config"
+	. "github.com/SubutaiBogatur/Peerster/events"
 	. "github.com/SubutaiBogatur/Peerster/gossiper"
+	. "github.com/SubutaiBogatur/Peerster/models/sending"
 	. "github.com/SubutaiBogatur/Peerster/utils"
 	. "github.com/SubutaiBogatur/Peerster/webserver"
 	log "github.com/sirupsen/logrus"
 	"math/rand"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 )
 
 // command line arguments
 var (
-	uiport        = flag.Int("UIPort", 4848, "Port, where gossiper listens for client. Client is situated on the same machine, so gossiper listens to "+LocalIp+":port for client")
-	gossipAddr    = flag.String("gossipAddr", "127.0.0.1:1212", "Address, where gossiper is launched: ip:port. Other peers will contact gossiper through this peersAddress")
-	name          = flag.String("name", "go_rbachev", "Gossiper name")
-	peers         = flag.String("peers", "", "Other gossipers' addresses separated with \",\" in the form ip:port")
-	rtimer        = flag.Int("rtimer", 0, "route rumors sending period in seconds, 0 to disable")
-	simpleMode    = flag.Bool("simple", false, "True, if mode is simple")
-	noWebserver   = flag.Bool("noWebserver", false, "True, if webserver is not needed")
-	noAntiEntropy = flag.Bool("noAntiEntropy", false, "True, if no regular pinging is needed")
+	uiport          = flag.Int("UIPort", 4848, "Port, where gossiper listens for client. Client is situated on the same machine, so gossiper listens to "+LocalIp+":port for client")
+	gossipAddr      = flag.String("gossipAddr", "127.0.0.1:1212", "Address, where gossiper is launched: ip:port. Other peers will contact gossiper through this peersAddress")
+	name            = flag.String("name", "go_rbachev", "Gossiper name")
+	peers           = flag.String("peers", "", "Other gossipers' addresses separated with \",\" in the form ip:port")
+	bootstrap       = flag.String("bootstrap", "", "Addresses to join the mesh through, if no neighbours are remembered from previous runs, separated with \",\" in the form ip:port")
+	rtimer          = flag.Int("rtimer", 0, "route rumors sending period in seconds, 0 to disable")
+	simpleMode      = flag.Bool("simple", false, "True, if mode is simple")
+	noWebserver     = flag.Bool("noWebserver", false, "True, if webserver is not needed")
+	webserverPort   = flag.Int("webserverPort", 8080, "Port, where webserver listens for http-requests")
+	dataDir         = flag.String("dataDir", "", "Directory, where _SharedFiles and _Downloads are situated, current directory by default")
+	noAntiEntropy   = flag.Bool("noAntiEntropy", false, "True, if no regular pinging is needed")
+	keyFile         = flag.String("keyFile", "", "File with ed25519 key of the gossiper, created if doesn't exist. New key every run by default")
+	trustFile       = flag.String("trustFile", "", "File with pinned keys of other origins, lines in the form \"origin hex-public-key\"")
+	encrypt         = flag.Bool("encrypt", false, "True, if text of private messages should be encrypted for destination, so that relays cannot read it")
+	secureLinks     = flag.Bool("secureLinks", false, "True, if neighbours should be authenticated and datagrams with them encrypted, all the neighbours should have it")
+	noBatching      = flag.Bool("noBatching", false, "True, if every packet should be sent in its own datagram, eg when peers don't understand batches")
+	noLiveness      = flag.Bool("noLiveness", false, "True, if neighbours should not be probed and dead ones should not be removed from peers")
+	noStatusDigests = flag.Bool("noStatusDigests", false, "True, if statuses should always carry the full vector clock instead of its hashes")
+	noPeerExchange  = flag.Bool("noPeerExchange", false, "True, if neighbours should be added only with -peers, /addPeer or when they send something")
+	targetDegree    = flag.Int("targetDegree", DefaultTargetDegree, "Number of neighbours, which peer exchange tries to keep")
+	quiet           = flag.Bool("quiet", false, "True, if protocol events should not be printed to stdout")
+	sendQueueSize   = flag.Int("sendQueueSize", DefaultSendQueueSize, "Max number of packets of one traffic class waiting to be sent to one peer")
+	sendQueuePolicy = flag.String("sendQueuePolicy", DropOldest.String(), "What is dropped, when queue to a peer is full: "+DropOldest.String()+" or "+DropNewest.String())
 )
 
 func main() {
@@ -34,32 +55,61 @@ func main() {
 
 	flag.Parse()
 
+	policy, ok := ParseOverflowPolicy(*sendQueuePolicy)
+	if !ok {
+		log.Error("unknown send queue policy: " + *sendQueuePolicy)
+		return
+	}
+
 	//models.ShareFile(SharedFilesPath + "/carlton.txt")
 
-	g, err := NewGossiper(*name, *uiport, *gossipAddr, *peers, *simpleMode)
+	g, err := NewGossiper(GossiperOptions{
+		Name:            *name,
+		UIPort:          *uiport,
+		GossipAddr:      *gossipAddr,
+		Peers:           *peers,
+		Bootstrap:       *bootstrap,
+		IsSimpleMode:    *simpleMode,
+		NoAntiEntropy:   *noAntiEntropy,
+		RouteRumorTimer: *rtimer,
+		NoBatching:      *noBatching,
+		NoLiveness:      *noLiveness,
+		NoPeerExchange:  *noPeerExchange,
+		NoStatusDigests: *noStatusDigests,
+		TargetDegree:    *targetDegree,
+		KeyFile:         *keyFile,
+		TrustFile:       *trustFile,
+		EncryptPrivate:  *encrypt,
+		SecureLinks:     *secureLinks,
+		DataDir:         *dataDir,
+		SendQueueSize:   *sendQueueSize,
+		SendQueuePolicy: policy,
+	})
 	if CheckErr(err) {
 		return
 	}
 
+	if !*quiet {
+		g.Subscribe(PrintToStdout)
+	}
+
 	// set random seed
 	rand.Seed(time.Now().Unix())
 
-	go g.StartClientReader()
-	go g.StartPeerReader()
-	go g.StartPeerWriter()
-
-	if !*noAntiEntropy {
-		go g.StartAntiEntropyTimer()
-	}
+	// gossiper is stopped gracefully on ctrl+c or on kill
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		cancel()
+	}()
 
-	go StartRouteRumorsSpreading(g, *rtimer)
 	if !*noWebserver {
-		go StartWebserver(g)
+		go StartWebserver(*webserverPort, g)
 	}
 
-	go g.StartMiningThread()
-
-	g.StartMessageProcessor() // goroutine dies, when app dies, so blocking function is called in main thread
+	CheckErr(g.Run(ctx)) // blocks until gossiper is stopped
 
 	fmt.Println("gossiper finished")
 }
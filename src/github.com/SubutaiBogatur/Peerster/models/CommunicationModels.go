@@ -1,10 +1,10 @@
 package models
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"encoding/binary"
 	"encoding/hex"
-	"fmt"
 	. "github.com/SubutaiBogatur/Peerster/config"
 	"net"
 	"strconv"
@@ -27,36 +27,80 @@ type GossipPacket struct {
 	SearchReply   *SearchReply
 	TxPublish     *TxPublish
 	BlockPublish  *BlockPublish
+	Fragment      *Fragment  // piece of another packet, whose encoding didn't fit into MaxPacketSize
+	Batch         *Batch     // several small packets to the same peer, sent in one datagram
+	Handshake     *Handshake // establishes secure link with the neighbour, sent only when links are secured
+	Sealed        *Sealed    // datagram of secure link, encrypted and authenticated
+	Hello         *Hello     // version and capabilities of the sender, sent on first contact
+	Ping          *Ping
+	Pong          *Pong
+	Traceroute    *Traceroute
+	Liveness      *Liveness     // failure detection between neighbours, see LivenessDetector
+	PeerExchange  *PeerExchange // neighbour discovery
+	RumorBundle   *RumorBundle  // many rumors at once, when peer is far behind
 }
 
+// Simple message is flooded to all the peers. Every gossiper relays message with the same origin and ID only once
+// and while HopLimit is not exhausted. Messages of older gossipers have neither ID, nor HopLimit, the first relay
+// sets the default hop limit. They are told apart only by the text, so for them it's best-effort: the same text
+// sent again by the origin is not relayed, while it's remembered, and the limit starts again after older relays
 type SimpleMessage struct {
 	OriginalName  string // name of original gossiper sender
 	RelayPeerAddr string // address of latest peer retranslator in the form ip:port
 	Text          string
+	ID            uint32 // counter per origin, 0 if origin doesn't number messages
+	HopLimit      uint32 // is decremented by every relay, 0 from older gossipers
 }
 
 type RumorMessage struct {
 	OriginalName string // name of original gossiper sender
 	ID           uint32 // id assigned by original sender ie counter per sender
 	Text         string
+	PublicKey    []byte // ed25519 key of original sender
+	Signature    []byte // of SignedData() with the key
+	HopCount     uint32 // hops from original sender to the gossiper, which sends the rumor, not signed, because relays increase it
 }
 
+// Rumors, which the receiver lacks according to its status, are sent together, when there are many of them.
+// Receiver takes every rumor as if it came alone, but answers with one status for the whole bundle
+type RumorBundle struct {
+	Rumors []*RumorMessage // by origin, then by id
+}
+
+// Full status lists the whole vector clock. Compact one (digest) has only hashes of ranges of origins, it's sent
+// instead of full one to peers, which understand it, see MessageStorage.Reconcile
 type StatusPacket struct {
-	Want []PeerStatus // vector clock
+	Want   []PeerStatus   // vector clock
+	Ranges []*DigestRange // compact status, Want is empty then
+}
+
+// Origins of the vector clock from From (inclusive) to To (exclusive) in lexicographical order, "" means no bound.
+// Either Hash of the range is given, or the range is listed in Want, when it's small
+type DigestRange struct {
+	From string
+	To   string
+	Hash []byte       // of origins of the range with their next ids, empty, if range is listed
+	Want []PeerStatus // all the origins of the range, if Hash is empty
 }
 
 // depicts gossiper's information about another peer
 type PeerStatus struct {
 	Identifier string
 	NextID     uint32
+	Buffered   []uint32 // ids after NextID, which peer already has, but waits for the previous ones to add them
 }
 
 type PrivateMessage struct {
 	Origin      string
 	ID          uint32
-	Text        string
+	Text        string // "" on the wire, if text is encrypted
 	Destination string
-	HopLimit    uint32
+	HopLimit    uint32 // not signed, because is changed by every hop
+	PublicKey   []byte // ed25519 key of origin
+	Signature   []byte // of SignedData() with the key
+
+	EphemeralKey []byte // x25519 key, generated by origin for this message, empty if text is not encrypted
+	Ciphertext   []byte // text encrypted for the key of destination, only destination can read it
 }
 
 type DataRequest struct {
@@ -94,11 +138,102 @@ type SearchResult struct {
 	ChunkCount   uint64
 }
 
+// Origin checks, if Destination is reachable, Destination answers with Pong
+type Ping struct {
+	Origin      string
+	Destination string
+	ID          uint32 // chosen by origin
+	HopLimit    uint32
+}
+
+// answer to the ping, Origin is the pinged gossiper, Destination is the one, who pinged
+type Pong struct {
+	Origin      string
+	Destination string
+	ID          uint32 // of the ping
+	HopLimit    uint32
+	HopCount    uint32 // hops, which the ping made
+}
+
+// Goes from Origin to Destination, every gossiper on the way (origin and destination too) appends itself to Hops.
+// Destination sends it back with IsReply, swapped Origin and Destination and all the hops, the way back is not recorded
+type Traceroute struct {
+	Origin      string
+	Destination string
+	ID          uint32 // chosen by the one, who traces
+	HopLimit    uint32
+	Hops        []*TraceHop
+	IsReply     bool
+}
+
+type TraceHop struct {
+	Name      string
+	Timestamp int64 // unix nanoseconds, when the gossiper got the traceroute, by its own clock
+}
+
+// Ping or ack of failure detector. Receiver of a ping with Target equal to its own address answers with ack,
+// with another Target forwards it to the Target, if it's its neighbour, as indirect probe, and forwards the ack back
+type Liveness struct {
+	Seq       uint64 // chosen by the prober, is echoed in ack
+	Target    string // address of the neighbour being probed
+	IsAck     bool
+	Requester string // address of the prober, if probe is indirect, else ""
+}
+
+// Sample of neighbours of the sender. Request is answered with reply, receiver of both adds some of the addresses
+// to its peers, if it has less neighbours, than it wants
+type PeerExchange struct {
+	Peers   []string // addresses in the form ip:port
+	IsReply bool
+}
+
 type TxPublish struct {
 	File     File
 	HopLimit uint32
 }
 
+// packets are processed by receiver one by one, as if they came in separate datagrams. They are neither batches nor fragments
+type Batch struct {
+	Packets []*GossipPacket
+}
+
+// nodes of the first protocol version don't send hellos and ignore them
+type Hello struct {
+	Version      uint32
+	Capabilities []string // optional features, which sender understands, see negotiation package
+	IsReply      bool     // if false, receiver answers with its own hello
+}
+
+// Neighbours establish secure link in three messages:
+// 1. initiator sends its ephemeral x25519 key and the name it claims
+// 2. responder sends its ephemeral key, its name, ed25519 key and signature of both ephemeral keys and both names
+// 3. initiator sends its name, ed25519 key and signature of both ephemeral keys and both names
+// Keys of the link are derived from shared secret of ephemeral keys and from both names, so they are new for every
+// session, and side, which thinks, that link is with someone else, cannot use it
+type Handshake struct {
+	Stage        uint32 // 1, 2 or 3
+	SessionID    uint64 // chosen by initiator
+	EphemeralKey []byte // stages 1 and 2
+	Name         string // origin name of the signer, its key is checked as the key of the origin, is only claimed in stage 1
+	PublicKey    []byte // stages 2 and 3
+	Signature    []byte // stages 2 and 3
+}
+
+// encoded gossip packet (possibly batch or fragment), encrypted with the key of the session
+type Sealed struct {
+	SessionID  uint64
+	Counter    uint64 // grows with every datagram of the session, receiver drops datagrams with seen counters
+	Ciphertext []byte
+}
+
+// encoded gossip packet is split into Count fragments, every fragment is sent in its own datagram
+type Fragment struct {
+	ID    uint64 // chosen by sender, same for all the fragments of one packet
+	Index uint32 // in [0, Count)
+	Count uint32
+	Data  []byte
+}
+
 type BlockPublish struct {
 	Block    Block
 	HopLimit uint32
@@ -123,6 +258,7 @@ type ClientMessage struct {
 	ToShare    *ClientToShareMessage
 	ToDownload *ClientToDownloadMessage
 	ToSearch   *ClientToSearchMessage
+	ToProbe    *ClientToProbeMessage
 }
 
 type ClientRumorMessage struct {
@@ -151,64 +287,61 @@ type ClientToSearchMessage struct {
 	Budget   uint64 // 0 = no budget provided
 }
 
+type ClientToProbeMessage struct {
+	Destination  string
+	IsTraceroute bool // else ping
+}
+
 func (rmsg *RumorMessage) String() string {
 	return rmsg.OriginalName + ":" + strconv.Itoa(int(rmsg.ID))
 }
 
-func (cmsg *ClientMessage) Print() bool {
-	if cmsg.Rumor != nil {
-		rcmsg := cmsg.Rumor
-		fmt.Println("CLIENT MESSAGE " + rcmsg.Text)
-	} else if cmsg.Private != nil {
-		pcmsg := cmsg.Private
-		fmt.Println("CLIENT PRIVATE TO " + pcmsg.Destination + ": " + pcmsg.Text)
-	} else if cmsg.ToShare != nil {
-		tscmsg := cmsg.ToShare
-		fmt.Println("CLIENT SHARE REQUEST: " + tscmsg.Path)
-	} else if cmsg.ToDownload != nil {
-		tdcmsg := cmsg.ToDownload
-		fmt.Println("CLIENT DOWNLOAD REQUEST: " + tdcmsg.Name + " from " + tdcmsg.Destination + " hash " + hex.EncodeToString(tdcmsg.HashValue[:]))
-	} else {
-		// client route rumor message
-		return false
-	}
-	return true
+// everything, what origin signs in rumor
+func (rmsg *RumorMessage) SignedData() []byte {
+	buf := new(bytes.Buffer)
+	buf.WriteString("rumor")
+	writeLengthPrefixed(buf, rmsg.OriginalName)
+	binary.Write(buf, binary.LittleEndian, rmsg.ID)
+	writeLengthPrefixed(buf, rmsg.Text)
+	return buf.Bytes()
 }
 
-func (agp *AddressedGossipPacket) Print() bool {
-	gp := agp.Packet
+// everything, what origin signs in private message
+func (pmsg *PrivateMessage) SignedData() []byte {
+	buf := new(bytes.Buffer)
+	buf.WriteString("private")
+	writeLengthPrefixed(buf, pmsg.Origin)
+	binary.Write(buf, binary.LittleEndian, pmsg.ID)
+	writeLengthPrefixed(buf, pmsg.Text)
+	writeLengthPrefixed(buf, pmsg.Destination)
+	writeLengthPrefixed(buf, string(pmsg.EphemeralKey))
+	writeLengthPrefixed(buf, string(pmsg.Ciphertext))
+	return buf.Bytes()
+}
 
-	if gp.Rumor != nil {
-		if gp.Rumor.Text == "" {
-			return false
-		}
-		rmsg := gp.Rumor
-		fmt.Println("RUMOR origin " + rmsg.OriginalName + " from " + agp.Address.String() + " ID " + strconv.Itoa(int(rmsg.ID)) + " contents " + rmsg.Text)
-	} else if gp.Status != nil {
-		status := gp.Status
-		fmt.Print("STATUS from " + agp.Address.String())
-		for _, peerStatus := range status.Want {
-			fmt.Print(" peer " + peerStatus.Identifier + " nextID " + strconv.Itoa(int(peerStatus.NextID)))
-		}
-		fmt.Println()
-	} else if gp.Simple != nil {
-		smsg := gp.Simple
-		fmt.Println("SIMPLE MESSAGE origin " + smsg.OriginalName + " from " + smsg.RelayPeerAddr + " contents " + smsg.Text)
-	} else if gp.Private != nil {
-		pmsg := gp.Private
-		fmt.Println("PRIVATE origin " + pmsg.Origin + " hop-limit " + fmt.Sprint(pmsg.HopLimit) + " contents " + pmsg.Text)
-	} else if gp.DataReply != nil {
-		//drpmsg := gp.DataReply
-		//fmt.Println("REPLY origin " + drpmsg.Origin + " hash " + hex.EncodeToString(drpmsg.HashValue[:]))
-		return false
-	} else if gp.DataRequest != nil {
-		//drqmsg := gp.DataRequest
-		//fmt.Println("REQUEST destination " + drqmsg.Destination + " hash " + hex.EncodeToString(drqmsg.HashValue[:]))
-		return false
-	} else {
-		return false // never should happen
-	}
-	return true
+func (pmsg *PrivateMessage) IsEncrypted() bool {
+	return len(pmsg.EphemeralKey) > 0 // decoder may give empty slice instead of nil
+}
+
+// authenticated together with encrypted text, so that ciphertext cannot be resent in another message
+func (pmsg *PrivateMessage) EncryptionAdditionalData() []byte {
+	buf := new(bytes.Buffer)
+	buf.WriteString("private")
+	writeLengthPrefixed(buf, pmsg.Origin)
+	binary.Write(buf, binary.LittleEndian, pmsg.ID)
+	writeLengthPrefixed(buf, pmsg.Destination)
+	return buf.Bytes()
+}
+
+// so that concatenation of different strings is never the same
+func writeLengthPrefixed(buf *bytes.Buffer, s string) {
+	binary.Write(buf, binary.LittleEndian, uint32(len(s)))
+	buf.WriteString(s)
+}
+
+// true, if status has hashes of ranges of origins instead of the full vector clock
+func (sp *StatusPacket) IsDigest() bool {
+	return len(sp.Ranges) > 0
 }
 
 func (t *TxPublish) Hash() (out [32]byte) {
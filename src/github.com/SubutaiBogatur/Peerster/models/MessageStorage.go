@@ -1,20 +1,29 @@
 package models
 
 import (
+	"bytes"
+	"crypto/sha256"
+	. "github.com/SubutaiBogatur/Peerster/config"
 	log "github.com/sirupsen/logrus"
+	"sort"
 	"strconv"
 	"sync"
 )
 
 // struct is thread-safe, because it uses hard synchronization
+// rumor, which came before some previous rumors of its origin, is buffered and is added, as soon as the gap is filled.
+// Status advertises added rumors and ids of the buffered ones, so peers send only the missing ones
 type MessageStorage struct {
 	// invariant: VectorClock[name] = len(RumorMessages[name])
-	VectorClock                map[string]uint32          // stores nextId value
-	RumorMessages              map[string][]*RumorMessage // string -> (array of RumorMessages, where array index is ID)
-	NonEmptyMessagesChronOrder []*RumorMessage            // all the non-rumor-routing msgs in chronological order to display in frontend
-	PrivateMessages            []*PrivateMessage          // invariant: destination = this gossiper
-
-	mux sync.Mutex
+	VectorClock                map[string]uint32                   // stores nextId value
+	RumorMessages              map[string][]*RumorMessage          // string -> (array of RumorMessages, where array index is ID)
+	BufferedRumors             map[string]map[uint32]*RumorMessage // origin -> id -> rumor, which waits for previous ones, at most MaxBufferedRumorsPerOrigin ids ahead
+	BufferedOrder              []*RumorMessage                     // buffered rumors from the oldest, may have already added ones, the oldest are evicted above MaxBufferedRumors
+	NonEmptyMessagesChronOrder []*RumorMessage                     // all the non-rumor-routing msgs in chronological order to display in frontend
+	PrivateMessages            []*PrivateMessage                   // invariant: destination = this gossiper
+
+	bufferedCount int // in BufferedRumors
+	mux           sync.Mutex
 }
 
 func InitMessageStorage(gossiperName string) *MessageStorage{
@@ -23,6 +32,8 @@ func InitMessageStorage(gossiperName string) *MessageStorage{
 	//ms.VectorClock[gossiperName] = 0 // protobuf doesn't like to deal with empty arrays, so let's never have empty vector clock
 
 	ms.RumorMessages = make(map[string][]*RumorMessage)
+	ms.BufferedRumors = make(map[string]map[uint32]*RumorMessage)
+	ms.BufferedOrder = make([]*RumorMessage, 0)
 	ms.NonEmptyMessagesChronOrder = make([]*RumorMessage, 0)
 	ms.PrivateMessages = make([]*PrivateMessage, 0)
 
@@ -44,11 +55,132 @@ func (ms *MessageStorage) GetCurrentStatusPacket() *StatusPacket {
 	want := make([]PeerStatus, 0)
 	for name, nextId := range ms.VectorClock {
 		currentNextId := nextId + 1 // numeration from 1
-		want = append(want, PeerStatus{Identifier: name, NextID: currentNextId})
+		want = append(want, PeerStatus{Identifier: name, NextID: currentNextId, Buffered: ms.getBufferedIds(name)})
+	}
+	for name := range ms.BufferedRumors {
+		if _, ok := ms.VectorClock[name]; !ok {
+			// nothing of the origin is added yet
+			want = append(want, PeerStatus{Identifier: name, NextID: 1, Buffered: ms.getBufferedIds(name)})
+		}
 	}
 	return &StatusPacket{Want: want}
 }
 
+// compact status with hash of the whole vector clock
+func (ms *MessageStorage) GetStatusDigest() *StatusPacket {
+	ms.mux.Lock()
+	defer ms.mux.Unlock()
+
+	return &StatusPacket{Ranges: []*DigestRange{{Hash: ms.hashOrigins(ms.getOriginsInRange("", ""))}}}
+}
+
+// Answers compact status of another peer. Ranges with equal hashes are in sync. Range with different hash is listed,
+// if this peer has few origins there, else it's split into DigestFanout ranges with their hashes. When the other peer
+// listed its range, rumors it lacks are returned, and for every origin, where the other peer is ahead, the origin is
+// listed back alone. Returns ranges to send back and rumors the other peer lacks, both are empty, if peers are in sync
+func (ms *MessageStorage) Reconcile(ranges []*DigestRange) ([]*DigestRange, []*RumorMessage) {
+	ms.mux.Lock()
+	defer ms.mux.Unlock()
+
+	reply := make([]*DigestRange, 0)
+	missing := make([]*RumorMessage, 0)
+	for _, r := range ranges {
+		if r == nil {
+			continue
+		}
+		origins := ms.getOriginsInRange(r.From, r.To)
+
+		if len(r.Hash) == 0 {
+			othersMap := make(map[string]uint32)
+			othersBuffered := make(map[string][]uint32)
+			for _, peerStatus := range r.Want {
+				if isInRange(peerStatus.Identifier, r.From, r.To) && peerStatus.NextID > 0 {
+					othersMap[peerStatus.Identifier] = peerStatus.NextID - 1 // numeration from 1
+					othersBuffered[peerStatus.Identifier] = peerStatus.Buffered
+				}
+			}
+			for _, name := range origins {
+				if othersMap[name] < ms.VectorClock[name] {
+					missing = appendNotBuffered(missing, ms.RumorMessages[name][othersMap[name]:], othersBuffered[name])
+				}
+			}
+			for _, peerStatus := range r.Want {
+				name := peerStatus.Identifier
+				if nextId, ok := othersMap[name]; ok && nextId > ms.VectorClock[name] {
+					// the next possible name is the end of the range with the only origin
+					reply = append(reply, &DigestRange{From: name, To: name + "\x00", Want: ms.listOrigins([]string{name})})
+					delete(othersMap, name)
+				}
+			}
+			continue
+		}
+
+		if bytes.Equal(r.Hash, ms.hashOrigins(origins)) {
+			continue
+		}
+		if len(origins) <= DigestLeafSize {
+			reply = append(reply, &DigestRange{From: r.From, To: r.To, Want: ms.listOrigins(origins)})
+			continue
+		}
+		chunk := (len(origins) + DigestFanout - 1) / DigestFanout
+		for i := 0; i < len(origins); i += chunk {
+			from, to, end := r.From, r.To, i+chunk
+			if i > 0 {
+				from = origins[i]
+			}
+			if end < len(origins) {
+				to = origins[end]
+			} else {
+				end = len(origins)
+			}
+			if end-i <= DigestLeafSize {
+				reply = append(reply, &DigestRange{From: from, To: to, Want: ms.listOrigins(origins[i:end])})
+			} else {
+				reply = append(reply, &DigestRange{From: from, To: to, Hash: ms.hashOrigins(origins[i:end])})
+			}
+		}
+	}
+
+	if len(reply) > DigestMaxRanges {
+		reply = reply[:DigestMaxRanges]
+	}
+	return reply, missing
+}
+
+// called under lock, sorted
+func (ms *MessageStorage) getOriginsInRange(from string, to string) []string {
+	origins := make([]string, 0)
+	for name := range ms.VectorClock {
+		if isInRange(name, from, to) {
+			origins = append(origins, name)
+		}
+	}
+	sort.Strings(origins)
+	return origins
+}
+
+// called under lock, origins should be sorted
+func (ms *MessageStorage) hashOrigins(origins []string) []byte {
+	hash := sha256.New()
+	for _, name := range origins {
+		hash.Write([]byte(name + "\x00" + strconv.Itoa(int(ms.VectorClock[name]+1)) + "\n")) // numeration from 1
+	}
+	return hash.Sum(nil)
+}
+
+// called under lock
+func (ms *MessageStorage) listOrigins(origins []string) []PeerStatus {
+	want := make([]PeerStatus, 0, len(origins))
+	for _, name := range origins {
+		want = append(want, PeerStatus{Identifier: name, NextID: ms.VectorClock[name] + 1, Buffered: ms.getBufferedIds(name)}) // numeration from 1
+	}
+	return want
+}
+
+func isInRange(name string, from string, to string) bool {
+	return name >= from && (to == "" || name < to)
+}
+
 func (ms *MessageStorage) GetRumorMessagesCopy() *[]RumorMessage {
 	ms.mux.Lock()
 	defer ms.mux.Unlock()
@@ -113,6 +245,50 @@ func (ms *MessageStorage) Diff(sp *StatusPacket) (*RumorMessage, bool) {
 	return nil, otherHasThisDoesnt
 }
 
+// all the rumors this peer has and another peer doesn't, by origin, then by id. Rumors, which another peer has
+// buffered, are not missing
+func (ms *MessageStorage) GetMissing(sp *StatusPacket) []*RumorMessage {
+	ms.mux.Lock()
+	defer ms.mux.Unlock()
+
+	othersMap := make(map[string]uint32)
+	othersBuffered := make(map[string][]uint32)
+	for _, peerStatus := range sp.Want {
+		if peerStatus.NextID > 0 {
+			othersMap[peerStatus.Identifier] = peerStatus.NextID - 1 // numeration from 1
+			othersBuffered[peerStatus.Identifier] = peerStatus.Buffered
+		}
+	}
+
+	origins := make([]string, 0, len(ms.VectorClock))
+	for name := range ms.VectorClock {
+		origins = append(origins, name)
+	}
+	sort.Strings(origins)
+
+	missing := make([]*RumorMessage, 0)
+	for _, name := range origins {
+		if othersMap[name] < ms.VectorClock[name] {
+			missing = appendNotBuffered(missing, ms.RumorMessages[name][othersMap[name]:], othersBuffered[name])
+		}
+	}
+	return missing
+}
+
+// appends rumors, which ids are not in buffered
+func appendNotBuffered(missing []*RumorMessage, rumors []*RumorMessage, buffered []uint32) []*RumorMessage {
+	isBuffered := make(map[uint32]bool, len(buffered))
+	for _, id := range buffered {
+		isBuffered[id] = true
+	}
+	for _, rmsg := range rumors {
+		if !isBuffered[rmsg.ID] {
+			missing = append(missing, rmsg)
+		}
+	}
+	return missing
+}
+
 func (ms *MessageStorage) IsNewMessage(rmsg *RumorMessage) bool {
 	ms.mux.Lock()
 	defer ms.mux.Unlock()
@@ -130,7 +306,9 @@ func (ms *MessageStorage) IsNewMessage(rmsg *RumorMessage) bool {
 
 }
 
-func (ms *MessageStorage) AddRumorMessage(rmsg *RumorMessage) bool {
+// returns rumors, which are added, in order: the given one and the buffered ones, which followed it,
+// or nothing, if rumor is not new or is buffered
+func (ms *MessageStorage) AddRumorMessage(rmsg *RumorMessage) []*RumorMessage {
 	ms.mux.Lock()
 	defer ms.mux.Unlock()
 
@@ -143,40 +321,111 @@ func (ms *MessageStorage) AddRumorMessage(rmsg *RumorMessage) bool {
 		ms.RumorMessages[origin] = make([]*RumorMessage, 0)
 	}
 
+	added := make([]*RumorMessage, 0)
 	rmsgId := rmsg.ID - 1 // numeration from 1
-	if rmsgId < ms.VectorClock[origin] {
-		return false // not new
+	if rmsg.ID == 0 || rmsgId < ms.VectorClock[origin] {
+		return added // not new
 	} else if rmsgId == ms.VectorClock[origin] {
-		ms.RumorMessages[origin] = append(ms.RumorMessages[origin], rmsg)
-		if rmsg.Text != "" {
-			// if not rumor-routing
-			ms.NonEmptyMessagesChronOrder = append(ms.NonEmptyMessagesChronOrder, rmsg)
+		ms.appendRumorMessage(rmsg)
+		added = append(added, rmsg)
+
+		// gap is filled, buffered rumors, which follow it, are added too
+		buffered := ms.BufferedRumors[origin]
+		for next, ok := buffered[ms.VectorClock[origin]+1]; ok; next, ok = buffered[ms.VectorClock[origin]+1] {
+			delete(buffered, next.ID)
+			ms.bufferedCount--
+			ms.appendRumorMessage(next)
+			added = append(added, next)
+		}
+		if len(buffered) == 0 {
+			delete(ms.BufferedRumors, origin)
 		}
-		ms.VectorClock[origin]++
 	} else {
-		log.Warn("messages from " + origin + " arrive not in chronological order!")
-		log.Warn("got message with ID " + strconv.Itoa(int(rmsgId)) + " when value in vector clock is: " + strconv.Itoa(int(ms.VectorClock[origin])))
-
-		if false {
-			// fill missing messages with zero text
-			for i := ms.VectorClock[origin]; i < rmsgId; i++ {
-				brokenRmsg := &RumorMessage{ID: i, OriginalName: origin, Text: "error - chronological order broken - error"}
-				ms.RumorMessages[origin] = append(ms.RumorMessages[origin], brokenRmsg)
-				ms.NonEmptyMessagesChronOrder = append(ms.NonEmptyMessagesChronOrder, brokenRmsg)
-				ms.VectorClock[origin]++
-			}
+		log.Debug("got message with ID " + strconv.Itoa(int(rmsgId)) + " from " + origin + " when value in vector clock is: " + strconv.Itoa(int(ms.VectorClock[origin])))
 
-			ms.RumorMessages[origin] = append(ms.RumorMessages[origin], rmsg)
-			ms.VectorClock[origin]++
-		} else {
-			return false // messages arrived not in chronological order, so we will not store them, but will wait for chronological order
+		if rmsgId-ms.VectorClock[origin] > MaxBufferedRumorsPerOrigin {
+			log.Warn("message " + rmsg.String() + " is too far ahead of the vector clock, dropping it")
+			return added
 		}
+		ms.bufferRumorMessage(rmsg)
+		return added // is not spread until previous messages come
 	}
 
 	// should add personal info to logger
 	log.Debug("Vector clock (to get status snapshot make +1) is:", ms.VectorClock)
 
-	return true // was new
+	return added // was new
+}
+
+// called under lock, rumor should be ahead of the vector clock
+func (ms *MessageStorage) bufferRumorMessage(rmsg *RumorMessage) {
+	origin := rmsg.OriginalName
+	if ms.BufferedRumors[origin] == nil {
+		ms.BufferedRumors[origin] = make(map[uint32]*RumorMessage)
+	}
+	if _, ok := ms.BufferedRumors[origin][rmsg.ID]; ok {
+		return // the same rumor came again
+	}
+	ms.BufferedRumors[origin][rmsg.ID] = rmsg
+	ms.BufferedOrder = append(ms.BufferedOrder, rmsg)
+	ms.bufferedCount++
+
+	for ms.bufferedCount > MaxBufferedRumors {
+		oldest := ms.BufferedOrder[0]
+		ms.BufferedOrder = ms.BufferedOrder[1:]
+		if buffered := ms.BufferedRumors[oldest.OriginalName]; buffered[oldest.ID] == oldest {
+			log.Warn("too many rumors are buffered, evicting " + oldest.String())
+			delete(buffered, oldest.ID)
+			if len(buffered) == 0 {
+				delete(ms.BufferedRumors, oldest.OriginalName)
+			}
+			ms.bufferedCount--
+		}
+	}
+
+	// added rumors are left in the order, so it's cleaned up, when they are the most of it
+	if len(ms.BufferedOrder) > 2*ms.bufferedCount {
+		order := make([]*RumorMessage, 0, ms.bufferedCount)
+		for _, buffered := range ms.BufferedOrder {
+			if ms.BufferedRumors[buffered.OriginalName][buffered.ID] == buffered {
+				order = append(order, buffered)
+			}
+		}
+		ms.BufferedOrder = order
+	}
+}
+
+// called under lock, sorted, nil, if nothing of the origin is buffered
+func (ms *MessageStorage) getBufferedIds(origin string) []uint32 {
+	buffered := ms.BufferedRumors[origin]
+	if len(buffered) == 0 {
+		return nil
+	}
+	ids := make([]uint32, 0, len(buffered))
+	for id := range buffered {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// called under lock, rumor should be the next one of its origin
+func (ms *MessageStorage) appendRumorMessage(rmsg *RumorMessage) {
+	origin := rmsg.OriginalName
+	ms.RumorMessages[origin] = append(ms.RumorMessages[origin], rmsg)
+	if rmsg.Text != "" {
+		// if not rumor-routing
+		ms.NonEmptyMessagesChronOrder = append(ms.NonEmptyMessagesChronOrder, rmsg)
+	}
+	ms.VectorClock[origin]++
+}
+
+// number of rumors, which wait for previous ones of their origin
+func (ms *MessageStorage) GetBufferedCount() int {
+	ms.mux.Lock()
+	defer ms.mux.Unlock()
+
+	return ms.bufferedCount
 }
 
 func (ms *MessageStorage) AddPrivateMessage(pmsg *PrivateMessage, currentGossiperName string) bool {
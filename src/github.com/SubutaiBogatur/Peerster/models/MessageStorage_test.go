@@ -0,0 +1,188 @@
+package models
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+
+	. "github.com/SubutaiBogatur/Peerster/config"
+)
+
+func rumor(id uint32) *RumorMessage {
+	return &RumorMessage{OriginalName: "a", ID: id, Text: "text"}
+}
+
+func statusOfA(ms *MessageStorage) PeerStatus {
+	for _, peerStatus := range ms.GetCurrentStatusPacket().Want {
+		if peerStatus.Identifier == "a" {
+			return peerStatus
+		}
+	}
+	return PeerStatus{Identifier: "a", NextID: 1}
+}
+
+func nextID(ms *MessageStorage) uint32 {
+	return statusOfA(ms).NextID
+}
+
+func TestRumorsAfterGapWaitForIt(t *testing.T) {
+	ms := InitMessageStorage("b")
+	ms.AddRumorMessage(rumor(1))
+
+	if len(ms.AddRumorMessage(rumor(4))) > 0 || len(ms.AddRumorMessage(rumor(3))) > 0 || len(ms.AddRumorMessage(rumor(6))) > 0 {
+		t.Fatal("rumor after the gap is added")
+	}
+	if nextID(ms) != 2 || ms.GetBufferedCount() != 3 {
+		t.Fatal("buffered rumors are advertised as added")
+	}
+	if buffered := statusOfA(ms).Buffered; len(buffered) != 3 || buffered[0] != 3 || buffered[1] != 4 || buffered[2] != 6 {
+		t.Fatal("buffered ids are not advertised")
+	}
+
+	if added := ms.AddRumorMessage(rumor(2)); len(added) != 3 || added[0].ID != 2 || added[2].ID != 4 {
+		t.Fatal("rumor, which fills the gap, doesn't bring the buffered ones")
+	}
+	if nextID(ms) != 5 || ms.GetBufferedCount() != 1 {
+		t.Fatal("buffered rumors are not added, when the gap is filled")
+	}
+	for i, rmsg := range *ms.GetRumorMessagesCopy() {
+		if rmsg.ID != uint32(i+1) {
+			t.Fatal("rumors are not in chronological order")
+		}
+	}
+
+	if len(ms.AddRumorMessage(rumor(3))) > 0 {
+		t.Fatal("added rumor is new again")
+	}
+	ms.AddRumorMessage(rumor(5))
+	if nextID(ms) != 7 || ms.GetBufferedCount() != 0 {
+		t.Fatal("the last gap is not filled")
+	}
+}
+
+func TestRumorTooFarAheadIsDropped(t *testing.T) {
+	ms := InitMessageStorage("b")
+	ms.AddRumorMessage(rumor(MaxBufferedRumorsPerOrigin + 2))
+	if ms.GetBufferedCount() != 0 {
+		t.Fatal("rumor too far ahead is buffered")
+	}
+	ms.AddRumorMessage(rumor(MaxBufferedRumorsPerOrigin + 1))
+	if ms.GetBufferedCount() != 1 {
+		t.Fatal("rumor in the window is not buffered")
+	}
+	if status := statusOfA(ms); len(status.Buffered) != 1 || status.Buffered[0] != MaxBufferedRumorsPerOrigin+1 {
+		t.Fatal("buffered id of origin without added rumors is not advertised")
+	}
+}
+
+func TestOldestBufferedRumorsAreEvicted(t *testing.T) {
+	ms := InitMessageStorage("b")
+	for i := 0; i <= MaxBufferedRumors; i++ {
+		ms.AddRumorMessage(&RumorMessage{OriginalName: "origin" + strconv.Itoa(i), ID: 2})
+	}
+	if ms.GetBufferedCount() != MaxBufferedRumors {
+		t.Fatal("buffered rumors are not limited")
+	}
+	if len(ms.AddRumorMessage(&RumorMessage{OriginalName: "origin0", ID: 1})) != 1 {
+		t.Fatal("the oldest buffered rumor is not evicted")
+	}
+	if len(ms.AddRumorMessage(&RumorMessage{OriginalName: "origin1", ID: 1})) != 2 {
+		t.Fatal("buffered rumor, which is not the oldest, is evicted")
+	}
+}
+
+func TestBufferedRumorsAreNotMissing(t *testing.T) {
+	sender, receiver := InitMessageStorage("c"), InitMessageStorage("b")
+	for id := uint32(1); id <= 5; id++ {
+		sender.AddRumorMessage(rumor(id))
+	}
+	receiver.AddRumorMessage(rumor(1))
+	receiver.AddRumorMessage(rumor(3))
+	receiver.AddRumorMessage(rumor(5))
+
+	missing := sender.GetMissing(receiver.GetCurrentStatusPacket())
+	if len(missing) != 2 || missing[0].ID != 2 || missing[1].ID != 4 {
+		t.Fatal("rumors, which are buffered by the other peer, are sent again")
+	}
+}
+
+// storage, which has rumors 1..count of every origin
+func storageWith(counts map[string]uint32) *MessageStorage {
+	ms := InitMessageStorage("me")
+	for origin, count := range counts {
+		for id := uint32(1); id <= count; id++ {
+			ms.AddRumorMessage(&RumorMessage{OriginalName: origin, ID: id})
+		}
+	}
+	return ms
+}
+
+// from sends its digest to to, then they answer each other, until nobody has anything to answer, returns rounds
+func reconcile(t *testing.T, from *MessageStorage, to *MessageStorage) int {
+	sender, receiver := from, to
+	ranges := from.GetStatusDigest().Ranges
+	rounds := 0
+	for len(ranges) > 0 {
+		reply, missing := receiver.Reconcile(ranges)
+		if len(reply) > DigestMaxRanges {
+			t.Fatal("too many ranges in reply")
+		}
+		for _, rmsg := range missing {
+			sender.AddRumorMessage(rmsg)
+		}
+		ranges = reply
+		sender, receiver = receiver, sender
+		rounds++
+		if rounds > 50 {
+			t.Fatal("digests are exchanged forever")
+		}
+	}
+	return rounds
+}
+
+func TestEqualDigestsNeedNoAnswer(t *testing.T) {
+	a := storageWith(map[string]uint32{"x": 2, "y": 1})
+	b := storageWith(map[string]uint32{"y": 1, "x": 2})
+	if reply, missing := b.Reconcile(a.GetStatusDigest().Ranges); len(reply) != 0 || len(missing) != 0 {
+		t.Fatal("peers in sync answer each other")
+	}
+	if len(a.GetStatusDigest().Ranges[0].Want) != 0 {
+		t.Fatal("digest lists origins")
+	}
+}
+
+func TestListedRangeAdvertisesBufferedRumors(t *testing.T) {
+	sender := storageWith(map[string]uint32{"a": 3})
+	receiver := InitMessageStorage("b")
+	receiver.AddRumorMessage(rumor(1))
+	receiver.AddRumorMessage(rumor(3))
+
+	listed, _ := receiver.Reconcile(sender.GetStatusDigest().Ranges)
+	if _, missing := sender.Reconcile(listed); len(missing) != 1 || missing[0].ID != 2 {
+		t.Fatal("rumor, which the other peer has buffered, is sent again")
+	}
+}
+
+func TestDigestsOfManyOriginsAreReconciled(t *testing.T) {
+	common := make(map[string]uint32)
+	for i := 0; i < 1000; i++ {
+		common["origin"+strconv.Itoa(i)] = uint32(i%3 + 1)
+	}
+	a := storageWith(common)
+	b := storageWith(common)
+	a.AddRumorMessage(&RumorMessage{OriginalName: "origin500", ID: 500%3 + 2})
+	a.AddRumorMessage(&RumorMessage{OriginalName: "only-a", ID: 1})
+	b.AddRumorMessage(&RumorMessage{OriginalName: "origin7", ID: 7%3 + 2})
+	b.AddRumorMessage(&RumorMessage{OriginalName: "zz-only-b", ID: 1})
+
+	rounds := reconcile(t, a, b)
+	if !bytes.Equal(a.GetStatusDigest().Ranges[0].Hash, b.GetStatusDigest().Ranges[0].Hash) {
+		t.Fatal("peers are not in sync after reconciliation")
+	}
+	if rounds > 12 {
+		t.Fatal("reconciliation takes " + strconv.Itoa(rounds) + " rounds")
+	}
+	if reconcile(t, b, a) != 1 {
+		t.Fatal("peers in sync exchange more than one digest")
+	}
+}
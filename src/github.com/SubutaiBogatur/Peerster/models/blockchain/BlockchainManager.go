@@ -1,10 +1,13 @@
 package blockchain
 
 import (
+	"context"
 	"encoding/hex"
 	"fmt"
 	. "github.com/SubutaiBogatur/Peerster/config"
+	. "github.com/SubutaiBogatur/Peerster/events"
 	. "github.com/SubutaiBogatur/Peerster/models"
+	"github.com/SubutaiBogatur/Peerster/utils/clock"
 	log "github.com/sirupsen/logrus"
 	"math/rand"
 	"strconv"
@@ -18,12 +21,15 @@ type BlockchainManager struct {
 	pendingTx      *TransactionsSet
 	noParentBlocks map[[32]byte]*Block // blocks here are waiting for parent to arrive
 
+	clock  clock.Clock // measures mining time and waiting for transactions
+	events *Bus
+
 	m sync.Mutex
 	l *log.Entry // logger
 }
 
-func InitBlockchainManager(l *log.Entry) *BlockchainManager {
-	bm := &BlockchainManager{l: l, blocks: make(map[[32]byte]*blockNode), pendingTx: InitEmpty(), noParentBlocks: make(map[[32]byte]*Block)}
+func InitBlockchainManager(clock clock.Clock, events *Bus, l *log.Entry) *BlockchainManager {
+	bm := &BlockchainManager{clock: clock, events: events, l: l, blocks: make(map[[32]byte]*blockNode), pendingTx: InitEmpty(), noParentBlocks: make(map[[32]byte]*Block)}
 
 	bm.tail = InitFakeBlockNode()
 	bm.blocks[bm.tail.getBlockHash()] = bm.tail // a bit dangerous, because real hash is not zeroes
@@ -92,7 +98,7 @@ func (bm *BlockchainManager) appendToTheTree(block *Block) {
 		bm.tail = InitBlockNode(bm.tail, block)
 		bm.blocks[block.Hash()] = bm.tail
 		bm.pendingTx.subtract(bm.tail.txSet)
-		bm.printLongestChain()
+		bm.publishLongestChain()
 		return
 	}
 
@@ -101,7 +107,7 @@ func (bm *BlockchainManager) appendToTheTree(block *Block) {
 	if parentNode.depth < bm.tail.depth {
 		bm.l.Info("block prolongs short chain, block: " + block.String())
 		bm.blocks[block.Hash()] = InitBlockNode(parentNode, block)
-		fmt.Println("FORK-SHORTER " + block.String())
+		bm.events.Publish(ForkShorterEvent{Block: *block})
 		return
 	}
 
@@ -114,7 +120,7 @@ func (bm *BlockchainManager) appendToTheTree(block *Block) {
 		bm.pendingTx.union(curBlock.txSet)
 		blocksUndone++
 	}
-	fmt.Println("FORK-LONGER rewind " + strconv.Itoa(blocksUndone) + " blocks")
+	bm.events.Publish(ForkLongerEvent{Rewound: blocksUndone})
 
 	bm.tail = InitBlockNode(parentNode, block)
 	bm.blocks[block.Hash()] = bm.tail
@@ -122,7 +128,7 @@ func (bm *BlockchainManager) appendToTheTree(block *Block) {
 	for curBlock := bm.tail; curBlock != lsa; curBlock = curBlock.parent {
 		bm.pendingTx.subtract(curBlock.txSet)
 	}
-	bm.printLongestChain()
+	bm.publishLongestChain()
 }
 
 // returns true if new & correct transaction
@@ -169,17 +175,28 @@ func (bm *BlockchainManager) GetBlockNumber() int {
 
 // is called from distinct thread
 // tries to mine new block. When succeeds, adds it to the blockchain and returns (block, time cpu was actually mining)
-func (bm *BlockchainManager) DoMining() (*Block, Duration) {
+// if ctx is cancelled before block is mined, returns (nil, 0)
+func (bm *BlockchainManager) DoMining(ctx context.Context) (*Block, Duration) {
 	failedAttempts := 0
 	sleepTimes := 0
-	start := Now()
+	start := bm.clock.Now()
 
 	// mining is done very often, so not taking lock every time, but only when possibly good block found, when decide to finally check it
 	// there is a potential danger of simultaneous iteration & editing, which may cause fail, it can be avoided with taking lock every time
 	for {
+		select {
+		case <-ctx.Done():
+			return nil, 0
+		default:
+		}
+
 		if bm.pendingTx.isEmpty() {
 			sleepTimes++
-			Sleep(BlockchainNoTxTimeout)
+			select {
+			case <-ctx.Done():
+				return nil, 0
+			case <-bm.clock.After(BlockchainNoTxTimeout):
+			}
 			continue
 		}
 
@@ -209,42 +226,31 @@ func (bm *BlockchainManager) DoMining() (*Block, Duration) {
 		}
 
 		// block is truly good
-		timeUsed := Since(start) - Duration(sleepTimes)*BlockchainNoTxTimeout
+		timeUsed := bm.clock.Since(start) - Duration(sleepTimes)*BlockchainNoTxTimeout
 		bm.l.Info("new block mined, spent " + strconv.Itoa(failedAttempts) + " attempts and " + fmt.Sprint(timeUsed.Seconds()) + " seconds, hash is: " + newBlock.String())
-		fmt.Println("FOUND-BLOCK " + newBlock.String())
+		bm.events.Publish(BlockFoundEvent{Block: newBlock})
 
 		// adding new block to blockchain
 		bm.tail = InitBlockNode(bm.tail, &newBlock)
 		bm.blocks[newBlock.Hash()] = bm.tail
 		bm.pendingTx.clear()
 		bm.l.Info("added new block to blockhain, now biggest-chain-depth is: " + fmt.Sprint(bm.tail.depth))
-		bm.printLongestChain()
+		bm.publishLongestChain()
 		bm.printBlocksMap()
 		bm.m.Unlock()
 
 		return &newBlock, timeUsed
 	}
-
-	return nil, 0 // cannot happen
 }
 
 // call after tail is updated
-func (bm *BlockchainManager) printLongestChain() {
-	str := "CHAIN"
+func (bm *BlockchainManager) publishLongestChain() {
+	blocks := make([]Block, 0, bm.tail.depth)
 	for curBlock := bm.tail; !curBlock.isFake(); curBlock = curBlock.parent {
-		txs := ""
-		for i, tx := range curBlock.block.Transactions {
-			txs += tx.File.Name
-			if i != len(curBlock.block.Transactions)-1 {
-				txs += ","
-			}
-		}
-
-		str += " " + curBlock.String() + ":" + curBlock.parent.String() + ":" + txs
+		blocks = append(blocks, *curBlock.block)
 	}
 
-	log.Debug(str)
-	fmt.Println(str)
+	bm.events.Publish(ChainEvent{Blocks: blocks})
 }
 
 func (bm *BlockchainManager) printBlocksMap() {
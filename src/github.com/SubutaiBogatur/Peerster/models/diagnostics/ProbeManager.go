@@ -0,0 +1,121 @@
+package diagnostics
+
+import (
+	. "github.com/SubutaiBogatur/Peerster/config"
+	. "github.com/SubutaiBogatur/Peerster/events"
+	. "github.com/SubutaiBogatur/Peerster/models"
+	"github.com/SubutaiBogatur/Peerster/utils/clock"
+	log "github.com/sirupsen/logrus"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	PingProbe       = "ping"
+	TracerouteProbe = "traceroute"
+
+	ProbePending    = "pending"
+	ProbeAnswered   = "answered"
+	ProbeTimedOut   = "timeout"
+	ProbeUnroutable = "unroutable" // no alive route to destination, nothing was sent
+)
+
+// ping or traceroute, started by this gossiper
+type Probe struct {
+	ID          uint32
+	Kind        string // PingProbe or TracerouteProbe
+	Destination string
+	Status      string
+	SentAt      time.Time
+	RoundTrip   time.Duration // by the clock of this gossiper, 0 until answered
+	HopCount    uint32        // 0 until answered
+	Hops        []*TraceHop   // traceroute only, from this gossiper to destination
+}
+
+// Keeps pings and traceroutes of this gossiper: pending ones wait for answers, finished ones are shown to the user.
+// Only MaxKeptProbes latest probes are kept. Finished probe is published as ProbeFinishedEvent
+// accessed from message-processor, probe-timeout threads and from webserver, is hard-synchronized
+type ProbeManager struct {
+	probes map[uint32]*Probe
+	order  []uint32 // ids from the oldest probe
+	nextID uint32
+
+	mux    sync.Mutex
+	clock  clock.Clock // round trips are measured with it
+	events *Bus
+	l      *log.Entry // logger
+}
+
+func InitProbeManager(clock clock.Clock, events *Bus, l *log.Entry) *ProbeManager {
+	return &ProbeManager{probes: make(map[uint32]*Probe), nextID: 1, clock: clock, events: events, l: l}
+}
+
+// registers new pending probe, returns its id
+func (pm *ProbeManager) Start(kind string, destination string) uint32 {
+	pm.mux.Lock()
+	defer pm.mux.Unlock()
+
+	id := pm.nextID
+	pm.nextID++
+	pm.probes[id] = &Probe{ID: id, Kind: kind, Destination: destination, Status: ProbePending, SentAt: pm.clock.Now()}
+	pm.order = append(pm.order, id)
+	if len(pm.order) > MaxKeptProbes {
+		delete(pm.probes, pm.order[0])
+		pm.order = pm.order[1:]
+	}
+	return id
+}
+
+// answer to the probe came from its destination, answers to unknown or finished probes are ignored
+func (pm *ProbeManager) Answer(id uint32, kind string, destination string, hopCount uint32, hops []*TraceHop) {
+	pm.mux.Lock()
+	probe, ok := pm.probes[id]
+	if !ok || probe.Status != ProbePending || probe.Kind != kind || probe.Destination != destination {
+		pm.mux.Unlock()
+		pm.l.Debug("dropping unexpected answer to " + kind + " " + strconv.Itoa(int(id)) + " from " + destination)
+		return
+	}
+	probe.RoundTrip = pm.clock.Since(probe.SentAt)
+	probe.HopCount = hopCount
+	probe.Hops = hops
+	probe.Status = ProbeAnswered
+	finished := *probe
+	pm.mux.Unlock()
+
+	pm.publishFinished(&finished)
+}
+
+// probe is finished without answer, does nothing, if it's already finished
+func (pm *ProbeManager) Fail(id uint32, status string) {
+	pm.mux.Lock()
+	probe, ok := pm.probes[id]
+	if !ok || probe.Status != ProbePending {
+		pm.mux.Unlock()
+		return
+	}
+	probe.Status = status
+	finished := *probe
+	pm.mux.Unlock()
+
+	pm.publishFinished(&finished)
+}
+
+// event is published not under lock, so that subscribers can ask for probes
+func (pm *ProbeManager) publishFinished(probe *Probe) {
+	pm.l.Info(probe.Kind + " " + strconv.Itoa(int(probe.ID)) + " to " + probe.Destination + " finished: " + probe.Status)
+	pm.events.Publish(ProbeFinishedEvent{Kind: probe.Kind, Destination: probe.Destination, Status: probe.Status,
+		RoundTrip: probe.RoundTrip, HopCount: probe.HopCount, Hops: probe.Hops})
+}
+
+// from the oldest probe
+func (pm *ProbeManager) GetProbesCopy() []Probe {
+	pm.mux.Lock()
+	defer pm.mux.Unlock()
+
+	probes := make([]Probe, 0, len(pm.order))
+	for _, id := range pm.order {
+		probes = append(probes, *pm.probes[id])
+	}
+	return probes
+}
@@ -0,0 +1,59 @@
+package diagnostics
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/SubutaiBogatur/Peerster/config"
+	. "github.com/SubutaiBogatur/Peerster/events"
+	. "github.com/SubutaiBogatur/Peerster/models"
+	"github.com/SubutaiBogatur/Peerster/utils/clock"
+	log "github.com/sirupsen/logrus"
+)
+
+func newManager(t *testing.T) (*ProbeManager, *clock.FakeClock, *[]ProbeFinishedEvent) {
+	fc := clock.NewFakeClock(time.Unix(0, 0))
+	bus := NewBus()
+	finished := make([]ProbeFinishedEvent, 0)
+	bus.Subscribe(func(e Event) {
+		finished = append(finished, e.(ProbeFinishedEvent))
+	})
+	return InitProbeManager(fc, bus, log.WithField("test", t.Name())), fc, &finished
+}
+
+func TestProbeIsFinishedOnce(t *testing.T) {
+	pm, fc, finished := newManager(t)
+	ping := pm.Start(PingProbe, "d")
+	trace := pm.Start(TracerouteProbe, "d")
+
+	fc.Advance(time.Second)
+	pm.Answer(ping, TracerouteProbe, "d", 3, nil)
+	pm.Answer(ping, PingProbe, "e", 3, nil)
+	if len(*finished) != 0 {
+		t.Fatal("answer of another kind or from another origin finishes the probe")
+	}
+	pm.Answer(ping, PingProbe, "d", 3, nil)
+	pm.Fail(ping, ProbeTimedOut)
+	pm.Fail(trace, ProbeTimedOut)
+	pm.Answer(trace, TracerouteProbe, "d", 3, []*TraceHop{{Name: "a"}, {Name: "d"}})
+
+	if len(*finished) != 2 || (*finished)[0].Status != ProbeAnswered || (*finished)[0].RoundTrip != time.Second || (*finished)[1].Status != ProbeTimedOut {
+		t.Fatalf("unexpected finished probes: %+v", *finished)
+	}
+	probes := pm.GetProbesCopy()
+	if len(probes) != 2 || probes[0].HopCount != 3 || probes[1].Status != ProbeTimedOut || probes[1].Hops != nil {
+		t.Fatalf("unexpected probes: %+v", probes)
+	}
+}
+
+func TestOnlyLatestProbesAreKept(t *testing.T) {
+	pm, _, _ := newManager(t)
+	for i := 0; i < MaxKeptProbes+5; i++ {
+		pm.Start(PingProbe, "d")
+	}
+
+	probes := pm.GetProbesCopy()
+	if len(probes) != MaxKeptProbes || probes[0].ID != 6 || probes[len(probes)-1].ID != MaxKeptProbes+5 {
+		t.Fatal("old probes are not forgotten")
+	}
+}
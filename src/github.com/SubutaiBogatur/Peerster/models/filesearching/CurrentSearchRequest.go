@@ -11,6 +11,8 @@ import (
 // accessed from message-processor, search-request goroutine & from webserver, is hard-synchronized
 type CurrentSearchRequest struct {
 	ch          chan *SearchReply
+	done        <-chan struct{} // closed, when gossiper is stopped, then nobody reads from ch
+	finished    chan struct{}   // closed on shutdown, then search-request goroutine doesn't read from ch too
 	fullMatches []*FullSearchMatch
 	isAlive     bool
 
@@ -20,23 +22,30 @@ type CurrentSearchRequest struct {
 	mux sync.Mutex
 }
 
-func InitCurrentSearchRequest(ch chan *SearchReply, gossiperUIPort int, l *log.Entry) *CurrentSearchRequest {
-	return &CurrentSearchRequest{ch: ch, fullMatches: make([]*FullSearchMatch, 0), isAlive: true, gossiperUIPort: gossiperUIPort, l: l}
+func InitCurrentSearchRequest(ch chan *SearchReply, done <-chan struct{}, gossiperUIPort int, l *log.Entry) *CurrentSearchRequest {
+	return &CurrentSearchRequest{ch: ch, done: done, finished: make(chan struct{}), fullMatches: make([]*FullSearchMatch, 0), isAlive: true, gossiperUIPort: gossiperUIPort, l: l}
 }
 
+// lock is not held while sending, because search-request goroutine takes it to add the match
 func (csr *CurrentSearchRequest) ForwardSearchReply(srp *SearchReply) {
-	csr.mux.Lock()
-	defer csr.mux.Unlock()
-
-	if !csr.isAlive {
+	if !csr.IsAlive() {
 		csr.l.Info("search request is already not in progress, dropping reply")
 		return
 	}
 
-	csr.ch <- srp
+	select {
+	case csr.ch <- srp:
+	case <-csr.finished:
+		csr.l.Info("search request finished meanwhile, dropping reply")
+	case <-csr.done:
+		csr.l.Info("gossiper is stopped, dropping reply")
+	}
 }
 
 func (csr *CurrentSearchRequest) IsAlive() bool {
+	csr.mux.Lock()
+	defer csr.mux.Unlock()
+
 	return csr.isAlive
 }
 
@@ -98,7 +107,10 @@ func (csr *CurrentSearchRequest) Shutdown() {
 	csr.mux.Lock()
 	defer csr.mux.Unlock()
 
-	csr.isAlive = false
+	if csr.isAlive {
+		csr.isAlive = false
+		close(csr.finished)
+	}
 }
 
 func (csr *CurrentSearchRequest) GetFullMatches() []string {
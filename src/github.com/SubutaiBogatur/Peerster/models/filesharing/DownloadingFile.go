@@ -3,8 +3,8 @@ package filesharing
 import (
 	"crypto/sha256"
 	"encoding/hex"
-	"fmt"
 	. "github.com/SubutaiBogatur/Peerster/config"
+	. "github.com/SubutaiBogatur/Peerster/events"
 	. "github.com/SubutaiBogatur/Peerster/models"
 	. "github.com/SubutaiBogatur/Peerster/utils"
 	log "github.com/sirupsen/logrus"
@@ -12,7 +12,6 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
-	"strconv"
 	"strings"
 )
 
@@ -26,10 +25,15 @@ type downloadingFile struct {
 	ChunksHashesSet   map[[32]byte]bool // same as slice, but set. Slice stores order, set provides O(1) access
 
 	ChunksToDownload map[[32]byte]bool // chunk_hash -> bool, is like ChunkHashesSet, but is modified with every new downloaded chunk
+
+	downloadsPath       string // where file is saved, when downloading finishes
+	downloadsChunksPath string // where chunks are saved, while downloading
+
+	events *Bus
 }
 
-func initDownloadingFile(name string, metahash [32]byte) *downloadingFile {
-	return &downloadingFile{Name: name, MetaHash: metahash} // all others nil
+func initDownloadingFile(name string, metahash [32]byte, downloadsPath string, downloadsChunksPath string, events *Bus) *downloadingFile {
+	return &downloadingFile{Name: name, MetaHash: metahash, downloadsPath: downloadsPath, downloadsChunksPath: downloadsChunksPath, events: events} // all others nil
 }
 
 func (df *downloadingFile) fileHasDownloadedChunk(hashValue [32]byte) bool {
@@ -50,7 +54,7 @@ func (df *downloadingFile) getChunkOrMetafile(hashValue [32]byte) []byte {
 	}
 
 	if df.fileHasDownloadedChunk(hashValue) {
-		chunkPath := filepath.Join(DownloadsChunksPath, df.Name, GetChunkFileName(hashValue))
+		chunkPath := filepath.Join(df.downloadsChunksPath, df.Name, GetChunkFileName(hashValue))
 		if _, err := os.Stat(chunkPath); os.IsNotExist(err) {
 			log.Error("existing chunk cannot be found!!!")
 			return nil
@@ -87,7 +91,7 @@ func (df *downloadingFile) processDataReply(drpmsg *DataReply) *bool {
 	if df.Metafile == nil {
 		ok := df.gotMetafile(typedHashValue, data)
 		if ok {
-			fmt.Println("DOWNLOADING metafile of " + df.Name + " from " + drpmsg.Origin)
+			df.events.Publish(MetafileDownloadedEvent{Name: df.Name, From: drpmsg.Origin})
 			return new(bool) // ptr to false
 		} else {
 			return nil // request will be repeated
@@ -102,9 +106,9 @@ func (df *downloadingFile) processDataReply(drpmsg *DataReply) *bool {
 
 	// we got the chunk we were waiting for:
 	delete(df.ChunksToDownload, typedHashValue)
-	fmt.Println("DOWNLOADING " + df.Name + " chunk " + strconv.Itoa(len(df.ChunksHashesSlice)-len(df.ChunksToDownload)) + " from " + drpmsg.Origin)
+	df.events.Publish(ChunkDownloadedEvent{Name: df.Name, Index: len(df.ChunksHashesSlice) - len(df.ChunksToDownload), From: drpmsg.Origin})
 	chunkFileName := GetChunkFileName(typedHashValue)
-	ioutil.WriteFile(filepath.Join(DownloadsChunksPath, df.Name, chunkFileName), data, FileCommonMode)
+	ioutil.WriteFile(filepath.Join(df.downloadsChunksPath, df.Name, chunkFileName), data, FileCommonMode)
 
 	if len(df.ChunksToDownload) == 0 {
 		df.finishDownloading()
@@ -140,13 +144,13 @@ func (df *downloadingFile) gotMetafile(hashValue [32]byte, metafile []byte) bool
 	}
 
 	// clean tmp storage:
-	if _, err := os.Stat(DownloadsPath); os.IsNotExist(err) {
-		os.Mkdir(DownloadsPath, FileCommonMode)
+	if _, err := os.Stat(df.downloadsPath); os.IsNotExist(err) {
+		os.Mkdir(df.downloadsPath, FileCommonMode)
 	}
-	if _, err := os.Stat(DownloadsChunksPath); os.IsNotExist(err) {
-		os.Mkdir(DownloadsChunksPath, FileCommonMode)
+	if _, err := os.Stat(df.downloadsChunksPath); os.IsNotExist(err) {
+		os.Mkdir(df.downloadsChunksPath, FileCommonMode)
 	}
-	fileChunksPath := filepath.Join(DownloadsChunksPath, df.Name)
+	fileChunksPath := filepath.Join(df.downloadsChunksPath, df.Name)
 	if _, err := os.Stat(fileChunksPath); !os.IsNotExist(err) {
 		log.Warn("received metafile for file, which downloading is currently in progress..")
 		return false
@@ -165,7 +169,7 @@ func (df *downloadingFile) finishDownloading() {
 	fileBytes := make([]byte, 0, len(df.ChunksHashesSlice)*FileChunkSize)
 	for _, chunkHash := range df.ChunksHashesSlice {
 		chunkFileName := GetChunkFileName(chunkHash)
-		chunkPath := filepath.Join(DownloadsChunksPath, df.Name, chunkFileName)
+		chunkPath := filepath.Join(df.downloadsChunksPath, df.Name, chunkFileName)
 		if _, err := os.Stat(chunkPath); os.IsNotExist(err) {
 			log.Error("existing chunk cannot be found!!!")
 			return
@@ -181,14 +185,14 @@ func (df *downloadingFile) finishDownloading() {
 	}
 
 	log.Info("file composed and being written to persistent memory")
-	if _, err := os.Stat(filepath.Join(DownloadsPath, df.Name)); !os.IsNotExist(err) {
+	if _, err := os.Stat(filepath.Join(df.downloadsPath, df.Name)); !os.IsNotExist(err) {
 		log.Warn("such file alreaqy exists in downloads dir, deleting old file, sorry..")
-		os.Remove(filepath.Join(DownloadsPath, df.Name))
+		os.Remove(filepath.Join(df.downloadsPath, df.Name))
 	}
-	ioutil.WriteFile(filepath.Join(DownloadsPath, df.Name), fileBytes, FileCommonMode)
+	ioutil.WriteFile(filepath.Join(df.downloadsPath, df.Name), fileBytes, FileCommonMode)
 
 	log.Debug("file composed & everything is ok, now providing chunks only for sharing")
-	fmt.Println("RECONSTRUCTED file " + df.Name)
+	df.events.Publish(FileReconstructedEvent{Name: df.Name})
 }
 
 func (df *downloadingFile) getDataRequest() []byte {
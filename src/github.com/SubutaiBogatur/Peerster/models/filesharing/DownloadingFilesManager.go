@@ -2,10 +2,12 @@ package filesharing
 
 import (
 	. "github.com/SubutaiBogatur/Peerster/config"
+	. "github.com/SubutaiBogatur/Peerster/events"
 	. "github.com/SubutaiBogatur/Peerster/models"
 	. "github.com/SubutaiBogatur/Peerster/utils"
 	log "github.com/sirupsen/logrus"
 	"os"
+	"path/filepath"
 	"sync"
 )
 
@@ -18,21 +20,34 @@ type DownloadingFilesManager struct {
 	downloadedFiles  map[[32]byte]*downloadingFile // metahash -> df
 	m                sync.Mutex
 
-	l *log.Entry // logger
+	downloadsPath       string // every gossiper has its own downloads directory
+	downloadsChunksPath string
+
+	events *Bus
+	l      *log.Entry // logger
 }
 
-func InitDownloadingFilesManager(l *log.Entry) *DownloadingFilesManager {
+// dataDir is a directory, where downloads directory is situated, "" for current directory
+func InitDownloadingFilesManager(dataDir string, events *Bus, l *log.Entry) *DownloadingFilesManager {
+	downloadsPath := filepath.Join(dataDir, DownloadsPath)
+	downloadsChunksPath := filepath.Join(dataDir, DownloadsChunksPath)
+
 	// clear tmp territory
-	if _, err := os.Stat(DownloadsChunksPath); !os.IsNotExist(err) {
-		os.RemoveAll(DownloadsChunksPath)
+	if _, err := os.Stat(downloadsChunksPath); !os.IsNotExist(err) {
+		os.RemoveAll(downloadsChunksPath)
 	}
-	if _, err := os.Stat(DownloadsPath); os.IsNotExist(err) {
-		os.Mkdir(DownloadsPath, FileCommonMode)
+	if _, err := os.Stat(downloadsPath); os.IsNotExist(err) {
+		os.MkdirAll(downloadsPath, FileCommonMode)
 	}
 
-	os.Mkdir(DownloadsChunksPath, FileCommonMode)
+	os.Mkdir(downloadsChunksPath, FileCommonMode)
+
+	return &DownloadingFilesManager{downloadingFiles: make(map[string]*downloadingFile), downloadedFiles: make(map[[32]byte]*downloadingFile),
+		downloadsPath: downloadsPath, downloadsChunksPath: downloadsChunksPath, events: events, l: l}
+}
 
-	return &DownloadingFilesManager{downloadingFiles: make(map[string]*downloadingFile), downloadedFiles: make(map[[32]byte]*downloadingFile), l: l}
+func (dfm *DownloadingFilesManager) GetDownloadsPath() string {
+	return dfm.downloadsPath
 }
 
 // kind of cas, returns true if really started downloading
@@ -46,7 +61,7 @@ func (dfm *DownloadingFilesManager) StartDownloadingFromOrigin(origin string, fi
 		return false
 	}
 
-	df := initDownloadingFile(fileName, metahash)
+	df := initDownloadingFile(fileName, metahash, dfm.downloadsPath, dfm.downloadsChunksPath, dfm.events)
 	dfm.downloadingFiles[origin] = df
 
 	return true
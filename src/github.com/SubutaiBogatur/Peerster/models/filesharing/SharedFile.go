@@ -24,9 +24,12 @@ type sharedFile struct {
 	MetaHash  [32]byte
 	MetaSlice []byte            // stores merged hashes of chunks in right order
 	MetaSet   map[[32]byte]bool // stores hashes of chunks
+
+	chunksPath string // directory, where chunks of this file are stored
 }
 
-func shareFile(path string) *sharedFile {
+// sharedFilesChunksPath is a directory, where directory with chunks of the file will be created
+func shareFile(path string, sharedFilesChunksPath string) *sharedFile {
 	path, err := filepath.Abs(path)
 	if CheckErr(err) {
 		return nil
@@ -37,16 +40,14 @@ func shareFile(path string) *sharedFile {
 		return nil
 	}
 
-	if _, err := os.Stat(SharedFilesPath); os.IsNotExist(err) {
-		os.Mkdir(SharedFilesPath, FileCommonMode)
-	}
-	if _, err := os.Stat(SharedFilesChunksPath); os.IsNotExist(err) {
-		os.Mkdir(SharedFilesChunksPath, FileCommonMode)
+	if _, err := os.Stat(sharedFilesChunksPath); os.IsNotExist(err) {
+		os.MkdirAll(sharedFilesChunksPath, FileCommonMode)
 	}
 
 	sharedFile := sharedFile{Name: filepath.Base(path)}
 
-	chunksPath := filepath.Join(SharedFilesChunksPath, sharedFile.Name)
+	chunksPath := filepath.Join(sharedFilesChunksPath, sharedFile.Name)
+	sharedFile.chunksPath = chunksPath
 	if _, err := os.Stat(chunksPath); !os.IsNotExist(err) {
 		return nil // it seems like this sharedFile is already being shared
 	}
@@ -103,7 +104,7 @@ func (sf *sharedFile) getChunk(hashValue [32]byte) []byte {
 		return nil
 	}
 
-	chunkPath := filepath.Join(SharedFilesChunksPath, sf.Name, GetChunkFileName(hashValue))
+	chunkPath := filepath.Join(sf.chunksPath, GetChunkFileName(hashValue))
 	if _, err := os.Stat(chunkPath); os.IsNotExist(err) {
 		log.Error("existing chunk cannot be found!!!")
 		return nil
@@ -2,8 +2,8 @@ package filesharing
 
 import (
 	"encoding/hex"
-	"fmt"
 	. "github.com/SubutaiBogatur/Peerster/config"
+	. "github.com/SubutaiBogatur/Peerster/events"
 	. "github.com/SubutaiBogatur/Peerster/models"
 	. "github.com/SubutaiBogatur/Peerster/utils"
 	log "github.com/sirupsen/logrus"
@@ -17,40 +17,51 @@ import (
 type SharedFilesManager struct {
 	sharedFiles map[[32]byte]*sharedFile
 
+	sharedFilesPath       string // every gossiper has its own shared files directory
+	sharedFilesChunksPath string
+
 	mux sync.Mutex
 
-	l *log.Entry // logger
+	events *Bus
+	l      *log.Entry // logger
 }
 
-func InitSharedFilesManager(l *log.Entry) *SharedFilesManager {
-	sfm := &SharedFilesManager{sharedFiles: make(map[[32]byte]*sharedFile), l: l}
+// dataDir is a directory, where shared files directory is situated, "" for current directory
+func InitSharedFilesManager(dataDir string, events *Bus, l *log.Entry) *SharedFilesManager {
+	sfm := &SharedFilesManager{sharedFiles: make(map[[32]byte]*sharedFile), events: events, l: l}
+	sfm.sharedFilesPath = filepath.Join(dataDir, SharedFilesPath)
+	sfm.sharedFilesChunksPath = filepath.Join(dataDir, SharedFilesChunksPath)
 
 	// when initting let's clear state and tmp files:
-	if _, err := os.Stat(SharedFilesChunksPath); !os.IsNotExist(err) {
-		os.RemoveAll(SharedFilesChunksPath)
+	if _, err := os.Stat(sfm.sharedFilesChunksPath); !os.IsNotExist(err) {
+		os.RemoveAll(sfm.sharedFilesChunksPath)
 	}
-	if _, err := os.Stat(SharedFilesPath); os.IsNotExist(err) {
-		os.Mkdir(SharedFilesPath, FileCommonMode)
+	if _, err := os.Stat(sfm.sharedFilesPath); os.IsNotExist(err) {
+		os.MkdirAll(sfm.sharedFilesPath, FileCommonMode)
 	}
-	os.Mkdir(SharedFilesChunksPath, FileCommonMode)
+	os.Mkdir(sfm.sharedFilesChunksPath, FileCommonMode)
 
 	return sfm
 }
 
+func (sfm *SharedFilesManager) GetSharedFilesPath() string {
+	return sfm.sharedFilesPath
+}
+
 // accepts path relative to _SharedFiles directory
 // returns (Name, MetafileHash, Size)
 func (sfm *SharedFilesManager) ShareFile(path string) (*string, *[32]byte, *int) {
 	sfm.mux.Lock()
 	defer sfm.mux.Unlock()
 
-	path = filepath.Join(SharedFilesPath, path)
+	path = filepath.Join(sfm.sharedFilesPath, path)
 	for _, v := range sfm.sharedFiles {
 		if v.Name == filepath.Base(path) {
 			sfm.l.Error("such file was already shared")
 		}
 	}
 
-	sf := shareFile(path)
+	sf := shareFile(path, sfm.sharedFilesChunksPath)
 	if sf == nil {
 		sfm.l.Error("unable to share file")
 		return nil, nil, nil
@@ -62,7 +73,7 @@ func (sfm *SharedFilesManager) ShareFile(path string) (*string, *[32]byte, *int)
 	}
 
 	sfm.sharedFiles[sf.MetaHash] = sf
-	fmt.Println("SHARED FILE " + sf.Name + " GOT METAHASH " + hex.EncodeToString(sf.MetaHash[:]))
+	sfm.events.Publish(FileSharedEvent{Name: sf.Name, MetaHash: sf.MetaHash})
 	return &sf.Name, &sf.MetaHash, &sf.Size
 }
 
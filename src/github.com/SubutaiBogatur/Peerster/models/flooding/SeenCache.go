@@ -0,0 +1,48 @@
+package flooding
+
+import (
+	"sync"
+)
+
+// Set of recently seen flooded messages, so that every message is relayed only once, even if topology has cycles.
+// Only capacity latest keys are kept, the oldest is forgotten first, so message, which comes back after capacity
+// other messages, is relayed again, but TTL of the message stops it anyway
+// accessed from message-processor, is hard-synchronized
+type SeenCache struct {
+	seen  map[string]bool
+	order []string // keys from the oldest
+	next  int      // index in order, where next key is put, when cache is full
+
+	capacity int
+	mux      sync.Mutex
+}
+
+func InitSeenCache(capacity int) *SeenCache {
+	return &SeenCache{seen: make(map[string]bool), order: make([]string, 0, capacity), capacity: capacity}
+}
+
+// returns true, if key was not seen before, then it's remembered
+func (sc *SeenCache) MarkSeen(key string) bool {
+	sc.mux.Lock()
+	defer sc.mux.Unlock()
+
+	if sc.seen[key] {
+		return false
+	}
+	if len(sc.order) < sc.capacity {
+		sc.order = append(sc.order, key)
+	} else {
+		delete(sc.seen, sc.order[sc.next])
+		sc.order[sc.next] = key
+		sc.next = (sc.next + 1) % sc.capacity
+	}
+	sc.seen[key] = true
+	return true
+}
+
+func (sc *SeenCache) Len() int {
+	sc.mux.Lock()
+	defer sc.mux.Unlock()
+
+	return len(sc.seen)
+}
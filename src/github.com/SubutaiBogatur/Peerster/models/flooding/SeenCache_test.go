@@ -0,0 +1,35 @@
+package flooding
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestKeyIsSeenOnce(t *testing.T) {
+	sc := InitSeenCache(4)
+	if !sc.MarkSeen("a#1") || sc.MarkSeen("a#1") {
+		t.Fatal("key is new twice")
+	}
+	if !sc.MarkSeen("a#2") || !sc.MarkSeen("b#1") {
+		t.Fatal("other keys are taken for seen")
+	}
+}
+
+func TestOldestKeyIsForgotten(t *testing.T) {
+	sc := InitSeenCache(3)
+	for i := 0; i < 5; i++ {
+		sc.MarkSeen(strconv.Itoa(i))
+	}
+	if sc.Len() != 3 {
+		t.Fatal("cache is not bounded")
+	}
+	if sc.MarkSeen("4") || sc.MarkSeen("3") || sc.MarkSeen("2") {
+		t.Fatal("latest keys are forgotten")
+	}
+	if !sc.MarkSeen("0") {
+		t.Fatal("oldest key is not forgotten")
+	}
+	if sc.MarkSeen("3") || !sc.MarkSeen("2") {
+		t.Fatal("keys are forgotten not from the oldest")
+	}
+}
@@ -0,0 +1,125 @@
+package fragmentation
+
+import (
+	. "github.com/SubutaiBogatur/Peerster/config"
+	. "github.com/SubutaiBogatur/Peerster/models"
+	"github.com/SubutaiBogatur/Peerster/utils/clock"
+	log "github.com/sirupsen/logrus"
+	"strconv"
+	"time"
+)
+
+// splits encoded packet into fragments, each of them fits into one datagram after encoding
+// returns nil, if packet is too large even for fragmentation
+func SplitIntoFragments(packetBytes []byte, id uint64) []*Fragment {
+	count := (len(packetBytes) + FragmentDataSize - 1) / FragmentDataSize
+	if count > MaxFragmentsCount {
+		return nil
+	}
+
+	fragments := make([]*Fragment, 0, count)
+	for i := 0; i < count; i++ {
+		end := (i + 1) * FragmentDataSize
+		if end > len(packetBytes) {
+			end = len(packetBytes)
+		}
+		fragments = append(fragments, &Fragment{ID: id, Index: uint32(i), Count: uint32(count), Data: packetBytes[i*FragmentDataSize : end]})
+	}
+	return fragments
+}
+
+// fragments of one packet, received so far
+type partialPacket struct {
+	fragments [][]byte // index -> data, nil if not received yet
+	received  int
+	size      int
+	started   time.Time // when first fragment arrived
+}
+
+// accessed only from peer-reader thread, so is not synchronized
+type Reassembler struct {
+	packets map[string]*partialPacket // key = "{sender address}-{fragment id}"
+
+	clock clock.Clock // reassembly timeout is measured with it
+	l     *log.Entry  // logger
+}
+
+func InitReassembler(clock clock.Clock, l *log.Entry) *Reassembler {
+	return &Reassembler{packets: make(map[string]*partialPacket), clock: clock, l: l}
+}
+
+// returns encoded packet, if the fragment was the last missing one, else returns nil
+func (r *Reassembler) AddFragment(sender string, f *Fragment) []byte {
+	r.dropExpired()
+
+	if f.Count == 0 || f.Count > MaxFragmentsCount || f.Index >= f.Count || len(f.Data) > FragmentDataSize {
+		r.l.Warn("malformed fragment from " + sender + ", dropping it")
+		return nil
+	}
+
+	key := sender + "-" + strconv.FormatUint(f.ID, 10)
+	pp, ok := r.packets[key]
+	if !ok {
+		if len(r.packets) >= MaxPendingFragmentedPackets {
+			r.dropOldest()
+		}
+		pp = &partialPacket{fragments: make([][]byte, f.Count), started: r.clock.Now()}
+		r.packets[key] = pp
+	}
+
+	if int(f.Count) != len(pp.fragments) {
+		r.l.Warn("fragment from " + sender + " disagrees with previous ones on fragments count, dropping the whole packet")
+		delete(r.packets, key)
+		return nil
+	}
+
+	if pp.fragments[f.Index] != nil {
+		r.l.Debug("duplicate fragment from " + sender + ", skipping it")
+		return nil
+	}
+
+	pp.fragments[f.Index] = f.Data
+	pp.received++
+	pp.size += len(f.Data)
+	if pp.received < len(pp.fragments) {
+		return nil
+	}
+
+	delete(r.packets, key)
+	packetBytes := make([]byte, 0, pp.size)
+	for _, data := range pp.fragments {
+		packetBytes = append(packetBytes, data...)
+	}
+	r.l.Debug("packet from " + sender + " is reassembled from " + strconv.Itoa(len(pp.fragments)) + " fragments")
+	return packetBytes
+}
+
+// number of packets, which are not fully received yet
+func (r *Reassembler) GetPendingNumber() int {
+	return len(r.packets)
+}
+
+// expired packets are removed lazily, when new fragments arrive
+func (r *Reassembler) dropExpired() {
+	for key, pp := range r.packets {
+		if r.clock.Since(pp.started) >= FragmentReassemblyTimeout {
+			r.l.Warn("timeout when reassembling packet " + key + ", got " + strconv.Itoa(pp.received) + " of " + strconv.Itoa(len(pp.fragments)) + " fragments, dropping it")
+			delete(r.packets, key) // safe to iterate & delete
+		}
+	}
+}
+
+func (r *Reassembler) dropOldest() {
+	oldestKey := ""
+	var oldest *partialPacket
+	for key, pp := range r.packets {
+		if oldest == nil || pp.started.Before(oldest.started) {
+			oldestKey, oldest = key, pp
+		}
+	}
+
+	if oldest != nil {
+		r.l.Warn("too many packets are being reassembled, dropping the oldest one: " + oldestKey)
+		delete(r.packets, oldestKey)
+	}
+}
@@ -0,0 +1,73 @@
+package fragmentation
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+	"time"
+
+	. "github.com/SubutaiBogatur/Peerster/config"
+	"github.com/SubutaiBogatur/Peerster/utils/clock"
+	log "github.com/sirupsen/logrus"
+)
+
+func randomBytes(size int) []byte {
+	data := make([]byte, size)
+	rand.New(rand.NewSource(int64(size))).Read(data)
+	return data
+}
+
+func TestReassemblesShuffledAndDuplicatedFragments(t *testing.T) {
+	r := InitReassembler(clock.NewFakeClock(time.Unix(0, 0)), log.WithField("test", t.Name()))
+	packetBytes := randomBytes(3*FragmentDataSize + 17)
+
+	fragments := SplitIntoFragments(packetBytes, 42)
+	if len(fragments) != 4 {
+		t.Fatalf("expected 4 fragments, got %d", len(fragments))
+	}
+
+	// every fragment twice in reversed order, packet is ready exactly after the last missing one
+	order := []int{3, 3, 2, 1, 1, 2, 0}
+	for i, index := range order {
+		result := r.AddFragment("10.0.0.1:5000", fragments[index])
+		if i < len(order)-1 && result != nil {
+			t.Fatalf("packet is reassembled too early, after fragment %d", i)
+		}
+		if i == len(order)-1 && !bytes.Equal(result, packetBytes) {
+			t.Fatal("reassembled packet differs from the original one")
+		}
+	}
+
+	if r.GetPendingNumber() != 0 {
+		t.Fatal("reassembled packet is still pending")
+	}
+}
+
+func TestDropsIncompletePacketAfterTimeout(t *testing.T) {
+	fc := clock.NewFakeClock(time.Unix(0, 0))
+	r := InitReassembler(fc, log.WithField("test", t.Name()))
+
+	fragments := SplitIntoFragments(randomBytes(2*FragmentDataSize), 7)
+	r.AddFragment("10.0.0.1:5000", fragments[0])
+	fc.Advance(FragmentReassemblyTimeout)
+
+	// same id from another sender is another packet
+	if r.AddFragment("10.0.0.2:5000", fragments[1]) != nil {
+		t.Fatal("fragments of different senders are mixed")
+	}
+	if r.AddFragment("10.0.0.1:5000", fragments[1]) != nil {
+		t.Fatal("packet is reassembled from expired fragments")
+	}
+	if r.GetPendingNumber() != 2 {
+		t.Fatalf("expected 2 pending packets, got %d", r.GetPendingNumber())
+	}
+}
+
+func TestRejectsTooLargePacket(t *testing.T) {
+	if SplitIntoFragments(make([]byte, MaxFragmentsCount*FragmentDataSize+1), 1) != nil {
+		t.Fatal("packet larger than MaxFragmentsCount fragments is split")
+	}
+	if len(SplitIntoFragments(make([]byte, MaxFragmentsCount*FragmentDataSize), 1)) != MaxFragmentsCount {
+		t.Fatal("largest allowed packet is not split")
+	}
+}
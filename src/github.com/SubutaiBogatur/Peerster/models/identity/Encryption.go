@@ -0,0 +1,110 @@
+package identity
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	. "github.com/SubutaiBogatur/Peerster/utils"
+	"math/big"
+)
+
+// Text for the gossiper is encrypted with its identity key, converted to x25519 one. Sender generates new x25519 key
+// for every message, shared secret of it and of the recipient key is hashed into aes-gcm key. As the key is never
+// reused, nonce is always zero
+
+// prime of curve25519
+var curvePrime = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 255), big.NewInt(19))
+
+// returns ephemeral x25519 public key and ciphertext, which only owner of the ed25519 publicKey can decrypt
+// additionalData is not encrypted, but decryption fails, if it's not the same
+func EncryptFor(publicKey []byte, plaintext []byte, additionalData []byte) ([]byte, []byte, error) {
+	recipientKey, err := toX25519PublicKey(publicKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ephemeralKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	aead, err := initAead(ephemeralKey, recipientKey, ephemeralKey.PublicKey(), recipientKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	return ephemeralKey.PublicKey().Bytes(), aead.Seal(nil, nonce, plaintext, additionalData), nil
+}
+
+// decrypts ciphertext, encrypted with EncryptFor for the public key of the identity
+func (id *Identity) Decrypt(ephemeralKey []byte, ciphertext []byte, additionalData []byte) ([]byte, error) {
+	senderKey, err := ecdh.X25519().NewPublicKey(ephemeralKey)
+	if err != nil {
+		return nil, err
+	}
+	h := sha512.Sum512(id.privateKey.Seed()) // the same scalar as ed25519 uses, x25519 clamps it the same way
+	ownKey, err := ecdh.X25519().NewPrivateKey(h[:32])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := initAead(ownKey, senderKey, senderKey, ownKey.PublicKey())
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
+
+func initAead(own *ecdh.PrivateKey, other *ecdh.PublicKey, ephemeralKey *ecdh.PublicKey, recipientKey *ecdh.PublicKey) (cipher.AEAD, error) {
+	shared, err := own.ECDH(other)
+	if err != nil {
+		return nil, err // other key is of low order
+	}
+
+	h := sha256.New()
+	h.Write([]byte("peerster private"))
+	h.Write(shared)
+	h.Write(ephemeralKey.Bytes())
+	h.Write(recipientKey.Bytes())
+
+	block, err := aes.NewCipher(h.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// edwards point (x, y) is birationally equivalent to montgomery point with u = (1 + y) / (1 - y)
+func toX25519PublicKey(publicKey []byte) (*ecdh.PublicKey, error) {
+	if len(publicKey) != ed25519.PublicKeySize {
+		return nil, PeersterError{ErrorMsg: "public key is not ed25519 one"}
+	}
+
+	littleEndian := make([]byte, len(publicKey))
+	copy(littleEndian, publicKey)
+	littleEndian[len(littleEndian)-1] &= 0x7f // highest bit is sign of x
+	y := new(big.Int).SetBytes(reverse(littleEndian))
+
+	denominator := new(big.Int).Sub(big.NewInt(1), y)
+	denominator.Mod(denominator, curvePrime)
+	if denominator.ModInverse(denominator, curvePrime) == nil {
+		return nil, PeersterError{ErrorMsg: "public key is not a valid ed25519 point"}
+	}
+	u := new(big.Int).Add(big.NewInt(1), y)
+	u.Mul(u, denominator)
+	u.Mod(u, curvePrime)
+
+	return ecdh.X25519().NewPublicKey(reverse(u.FillBytes(make([]byte, 32))))
+}
+
+func reverse(b []byte) []byte {
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return b
+}
@@ -0,0 +1,47 @@
+package identity
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestOnlyRecipientDecrypts(t *testing.T) {
+	bob, _ := LoadOrCreateIdentity("")
+	mallory, _ := LoadOrCreateIdentity("")
+	text := []byte("secret")
+
+	ephemeralKey, ciphertext, err := EncryptFor(bob.PublicKey(), text, []byte("alice->bob"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(ciphertext, text) {
+		t.Fatal("ciphertext contains the text")
+	}
+
+	decrypted, err := bob.Decrypt(ephemeralKey, ciphertext, []byte("alice->bob"))
+	if err != nil || !bytes.Equal(decrypted, text) {
+		t.Fatal("recipient cannot decrypt the text")
+	}
+	if _, err := mallory.Decrypt(ephemeralKey, ciphertext, []byte("alice->bob")); err == nil {
+		t.Fatal("not recipient decrypts the text")
+	}
+}
+
+func TestTamperedMessageIsNotDecrypted(t *testing.T) {
+	bob, _ := LoadOrCreateIdentity("")
+	ephemeralKey, ciphertext, err := EncryptFor(bob.PublicKey(), []byte("secret"), []byte("alice->bob"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := bob.Decrypt(ephemeralKey, ciphertext, []byte("mallory->bob")); err == nil {
+		t.Fatal("ciphertext is decrypted with other additional data")
+	}
+	ciphertext[0] ^= 1
+	if _, err := bob.Decrypt(ephemeralKey, ciphertext, []byte("alice->bob")); err == nil {
+		t.Fatal("changed ciphertext is decrypted")
+	}
+	if _, _, err := EncryptFor([]byte("short"), []byte("secret"), nil); err == nil {
+		t.Fatal("text is encrypted for malformed key")
+	}
+}
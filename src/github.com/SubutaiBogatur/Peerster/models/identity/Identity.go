@@ -0,0 +1,61 @@
+package identity
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	. "github.com/SubutaiBogatur/Peerster/utils"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// ed25519 keypair of the gossiper. Other gossipers bind its origin name to the public key and check signatures of its messages
+type Identity struct {
+	privateKey ed25519.PrivateKey
+}
+
+// reads private key seed (hex) from the file, if file doesn't exist, new key is generated and saved there
+// if keyFile is "", new key is generated and not saved anywhere
+func LoadOrCreateIdentity(keyFile string) (*Identity, error) {
+	if keyFile == "" {
+		return generateIdentity()
+	}
+
+	data, err := ioutil.ReadFile(keyFile)
+	if os.IsNotExist(err) {
+		id, err := generateIdentity()
+		if err != nil {
+			return nil, err
+		}
+		if err := ioutil.WriteFile(keyFile, []byte(hex.EncodeToString(id.privateKey.Seed())+"\n"), 0600); err != nil {
+			return nil, err
+		}
+		return id, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	seed, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, PeersterError{ErrorMsg: "key file " + keyFile + " doesn't contain hex ed25519 seed"}
+	}
+	return &Identity{privateKey: ed25519.NewKeyFromSeed(seed)}, nil
+}
+
+func generateIdentity() (*Identity, error) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &Identity{privateKey: privateKey}, nil
+}
+
+func (id *Identity) PublicKey() []byte {
+	return id.privateKey.Public().(ed25519.PublicKey)
+}
+
+func (id *Identity) Sign(data []byte) []byte {
+	return ed25519.Sign(id.privateKey, data)
+}
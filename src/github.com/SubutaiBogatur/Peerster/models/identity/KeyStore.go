@@ -0,0 +1,121 @@
+package identity
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	. "github.com/SubutaiBogatur/Peerster/utils"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type knownKey struct {
+	key    ed25519.PublicKey
+	pinned bool // from trust file, else learned, when origin was seen first time
+}
+
+// Binds origin names to public keys. Key of the origin is either pinned in trust file or is learned from the first
+// correctly signed message of the origin (trust on first use). After that, messages of the origin signed with other
+// keys are rejected
+// accessed from message-processor and from webserver, is hard-synchronized
+type KeyStore struct {
+	keys map[string]*knownKey // origin -> its key
+
+	mux sync.Mutex
+	l   *log.Entry // logger
+}
+
+// trust file consists of lines "{origin} {hex public key}", empty lines and lines starting with # are skipped
+// if trustFile is "", no keys are pinned
+func InitKeyStore(trustFile string, l *log.Entry) (*KeyStore, error) {
+	ks := &KeyStore{keys: make(map[string]*knownKey), l: l}
+	if trustFile == "" {
+		return ks, nil
+	}
+
+	file, err := os.Open(trustFile)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		key, err := hex.DecodeString(fields[len(fields)-1])
+		if len(fields) != 2 || err != nil || len(key) != ed25519.PublicKeySize {
+			return nil, PeersterError{ErrorMsg: "trust file " + trustFile + " has malformed line " + strconv.Itoa(lineNumber)}
+		}
+		ks.keys[fields[0]] = &knownKey{key: key, pinned: true}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	l.Info("pinned " + strconv.Itoa(len(ks.keys)) + " keys from trust file")
+	return ks, nil
+}
+
+// binds origin to the key unconditionally, used for own name of the gossiper
+func (ks *KeyStore) Bind(origin string, publicKey []byte) {
+	ks.mux.Lock()
+	defer ks.mux.Unlock()
+
+	ks.keys[origin] = &knownKey{key: publicKey, pinned: true}
+}
+
+// returns true, if data is signed by the key and the key belongs to the origin. Unknown origin gets the key
+func (ks *KeyStore) Verify(origin string, publicKey []byte, data []byte, signature []byte) bool {
+	if len(publicKey) != ed25519.PublicKeySize || !ed25519.Verify(publicKey, data, signature) {
+		ks.l.Warn("message of " + origin + " has no valid signature")
+		return false
+	}
+
+	ks.mux.Lock()
+	defer ks.mux.Unlock()
+
+	known, ok := ks.keys[origin]
+	if !ok {
+		ks.l.Info("first message of " + origin + ", trusting its key " + hex.EncodeToString(publicKey))
+		ks.keys[origin] = &knownKey{key: publicKey}
+		return true
+	}
+
+	if !bytes.Equal(known.key, publicKey) {
+		ks.l.Warn("message of " + origin + " is signed with unknown key " + hex.EncodeToString(publicKey) + ", pinned is " + strconv.FormatBool(known.pinned))
+		return false
+	}
+	return true
+}
+
+// returns key of the origin or nil, if the origin is unknown
+func (ks *KeyStore) GetKey(origin string) []byte {
+	ks.mux.Lock()
+	defer ks.mux.Unlock()
+
+	if known, ok := ks.keys[origin]; ok {
+		return known.key
+	}
+	return nil
+}
+
+// origin -> hex of its public key
+func (ks *KeyStore) GetKeysCopy() map[string]string {
+	ks.mux.Lock()
+	defer ks.mux.Unlock()
+
+	keys := make(map[string]string)
+	for origin, known := range ks.keys {
+		keys[origin] = hex.EncodeToString(known.key)
+	}
+	return keys
+}
@@ -0,0 +1,78 @@
+package identity
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func TestKeyIsTrustedOnFirstUse(t *testing.T) {
+	ks, err := InitKeyStore("", log.WithField("test", t.Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice, _ := LoadOrCreateIdentity("")
+	mallory, _ := LoadOrCreateIdentity("")
+	data := []byte("hello")
+
+	if ks.Verify("alice", alice.PublicKey(), data, mallory.Sign(data)) {
+		t.Fatal("signature of another key is accepted")
+	}
+	if !ks.Verify("alice", alice.PublicKey(), data, alice.Sign(data)) {
+		t.Fatal("first message of the origin is rejected")
+	}
+	if ks.Verify("alice", mallory.PublicKey(), data, mallory.Sign(data)) {
+		t.Fatal("message of the origin signed with other key is accepted")
+	}
+	if !ks.Verify("alice", alice.PublicKey(), []byte("again"), alice.Sign([]byte("again"))) {
+		t.Fatal("second message of the origin is rejected")
+	}
+}
+
+func TestPinnedKeysAreNotReplaced(t *testing.T) {
+	alice, _ := LoadOrCreateIdentity("")
+	mallory, _ := LoadOrCreateIdentity("")
+
+	trustFile := filepath.Join(t.TempDir(), "trust")
+	content := "# pinned keys\n\nalice " + hex.EncodeToString(alice.PublicKey()) + "\n"
+	if err := ioutil.WriteFile(trustFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ks, err := InitKeyStore(trustFile, log.WithField("test", t.Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("hello")
+	if ks.Verify("alice", mallory.PublicKey(), data, mallory.Sign(data)) {
+		t.Fatal("pinned key is replaced by the first seen one")
+	}
+	if !ks.Verify("alice", alice.PublicKey(), data, alice.Sign(data)) {
+		t.Fatal("message signed with pinned key is rejected")
+	}
+
+	if err := ioutil.WriteFile(trustFile, []byte("alice not-a-key\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := InitKeyStore(trustFile, log.WithField("test", t.Name())); err == nil {
+		t.Fatal("malformed trust file is accepted")
+	}
+}
+
+func TestIdentityIsSavedToKeyFile(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "key")
+	created, err := LoadOrCreateIdentity(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadOrCreateIdentity(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(created.PublicKey()) != hex.EncodeToString(loaded.PublicKey()) {
+		t.Fatal("identity is not the same after reloading")
+	}
+}
@@ -0,0 +1,376 @@
+package links
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	. "github.com/SubutaiBogatur/Peerster/config"
+	. "github.com/SubutaiBogatur/Peerster/models"
+	. "github.com/SubutaiBogatur/Peerster/models/identity"
+	. "github.com/SubutaiBogatur/Peerster/utils"
+	"github.com/SubutaiBogatur/Peerster/utils/clock"
+	log "github.com/sirupsen/logrus"
+	mrand "math/rand"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// session of the link, both sides have the same keys, but for sending one uses the key, which other uses for receiving
+type session struct {
+	id      uint64
+	send    cipher.AEAD
+	receive cipher.AEAD
+
+	sentCounter uint64       // counter of the last sealed datagram
+	window      replayWindow // counters of opened datagrams
+
+	initiatorKey []byte // ephemeral keys, both sides sign them
+	responderKey []byte
+
+	initiatorName string // claimed names, both sides sign them, so neither is taken for a link with someone else
+	responderName string
+}
+
+// handshake sent by this side, waiting for stage 2
+type initiatedHandshake struct {
+	id           uint64
+	ephemeralKey *ecdh.PrivateKey
+	name         string // of this side, when stage 1 was sent
+	started      time.Time
+}
+
+// everything about one neighbour address
+type link struct {
+	name      string              // authenticated name of the neighbour, "" until first session is established
+	sessions  []*session          // established ones, newest is the last
+	initiated *initiatedHandshake // nil, if this side doesn't wait for stage 2
+	responded *session            // stage 2 is sent for it, waiting for stage 3, not yet in sessions
+	pending   [][]byte            // datagrams to the neighbour, which wait for the first session
+}
+
+// what should be done after the handshake message is handled
+type HandshakeResult struct {
+	Reply   *Handshake // next stage to send back, nil if nothing
+	Flushed []*Sealed  // datagrams, which waited for the session, should be sent after the reply
+	Name    string     // of the neighbour, if session is established with this message, else ""
+}
+
+// Keeps secure links with neighbours (see Handshake). Datagrams to the neighbour are sealed with the newest session of
+// the link, datagrams of MaxLinkSessions newest sessions are accepted, so both sides can start handshake at once
+// accessed from peer-reader and from peer-writer, is hard-synchronized
+type LinkManager struct {
+	links map[string]*link // neighbour address -> link
+
+	identity *Identity // signs handshakes of this side
+	keyStore *KeyStore // neighbour should sign handshake with the key of the name it claims
+
+	mux   sync.Mutex
+	clock clock.Clock // handshake timeout is measured with it
+	l     *log.Entry  // logger
+}
+
+func InitLinkManager(identity *Identity, keyStore *KeyStore, clock clock.Clock, l *log.Entry) *LinkManager {
+	return &LinkManager{links: make(map[string]*link), identity: identity, keyStore: keyStore, clock: clock, l: l}
+}
+
+// name is the current name of this gossiper. Returns datagram sealed for the neighbour. If link has no session yet,
+// datagram waits for it and nil is returned, also first stage of handshake is returned, if it should be sent
+func (lm *LinkManager) Seal(address string, name string, datagram []byte) (*Sealed, *Handshake) {
+	lm.mux.Lock()
+	defer lm.mux.Unlock()
+
+	lk := lm.getOrCreateLink(address)
+	if lk == nil {
+		return nil, nil
+	}
+	if len(lk.sessions) > 0 {
+		return lk.sessions[len(lk.sessions)-1].seal(datagram), nil
+	}
+
+	if len(lk.pending) == MaxPendingLinkDatagrams {
+		lm.l.Warn("too many datagrams wait for handshake with " + address + ", dropping the oldest")
+		lk.pending = lk.pending[1:]
+	}
+	lk.pending = append(lk.pending, datagram)
+	return nil, lm.startHandshakeIfNeeded(lk, name)
+}
+
+// name is the current name of this gossiper. Returns opened datagram. If session is unknown, eg neighbour was
+// restarted, first stage of handshake can be returned
+func (lm *LinkManager) Open(address string, name string, sealed *Sealed) ([]byte, *Handshake, error) {
+	lm.mux.Lock()
+	defer lm.mux.Unlock()
+
+	lk := lm.getOrCreateLink(address)
+	if lk == nil {
+		return nil, nil, PeersterError{ErrorMsg: "too many links"}
+	}
+
+	var s *session
+	for _, established := range lk.sessions {
+		if established.id == sealed.SessionID {
+			s = established
+		}
+	}
+	if s == nil {
+		return nil, lm.startHandshakeIfNeeded(lk, name), PeersterError{ErrorMsg: "datagram of unknown session " + strconv.FormatUint(sealed.SessionID, 10)}
+	}
+
+	if !s.window.isFresh(sealed.Counter) {
+		return nil, nil, PeersterError{ErrorMsg: "datagram " + strconv.FormatUint(sealed.Counter, 10) + " is replayed"}
+	}
+	datagram, err := s.receive.Open(nil, nonceOf(s.receive, sealed.Counter), sealed.Ciphertext, nil)
+	if err != nil {
+		return nil, nil, PeersterError{ErrorMsg: "datagram " + strconv.FormatUint(sealed.Counter, 10) + " is not authentic"}
+	}
+	s.window.accept(sealed.Counter)
+	return datagram, nil, nil
+}
+
+// name is the current name of this gossiper. Error means, that neighbour failed to authenticate
+func (lm *LinkManager) HandleHandshake(address string, name string, hs *Handshake) (*HandshakeResult, error) {
+	lm.mux.Lock()
+	defer lm.mux.Unlock()
+
+	lk := lm.getOrCreateLink(address)
+	if lk == nil {
+		return nil, PeersterError{ErrorMsg: "too many links"}
+	}
+
+	switch hs.Stage {
+	case 1:
+		return lm.respond(lk, name, hs)
+	case 2:
+		return lm.finish(lk, name, hs)
+	case 3:
+		return lm.confirm(lk, hs)
+	}
+	return nil, PeersterError{ErrorMsg: "unknown handshake stage " + strconv.Itoa(int(hs.Stage))}
+}
+
+// address -> authenticated name of the neighbour, for links with sessions
+func (lm *LinkManager) GetLinksCopy() map[string]string {
+	lm.mux.Lock()
+	defer lm.mux.Unlock()
+
+	links := make(map[string]string)
+	for address, lk := range lm.links {
+		if len(lk.sessions) > 0 {
+			links[address] = lk.name
+		}
+	}
+	return links
+}
+
+// responder gets stage 1
+func (lm *LinkManager) respond(lk *link, name string, hs *Handshake) (*HandshakeResult, error) {
+	if lk.responded != nil && lk.responded.id == hs.SessionID {
+		lm.l.Debug("stage 1 is duplicated, ignoring it")
+		return &HandshakeResult{}, nil // otherwise stage 2 with other ephemeral key is sent
+	}
+	if hs.Name == "" {
+		return nil, PeersterError{ErrorMsg: "initiator doesn't claim its name"}
+	}
+	initiatorKey, err := ecdh.X25519().NewPublicKey(hs.EphemeralKey)
+	if err != nil {
+		return nil, PeersterError{ErrorMsg: "malformed ephemeral key"}
+	}
+	ephemeralKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := initSession(hs.SessionID, ephemeralKey, initiatorKey, false, hs.Name, name)
+	if err != nil {
+		return nil, err
+	}
+	lk.responded = s // previous not confirmed one is forgotten
+
+	reply := &Handshake{Stage: 2, SessionID: hs.SessionID, EphemeralKey: s.responderKey}
+	lm.signHandshake(reply, name, s)
+	return &HandshakeResult{Reply: reply}, nil
+}
+
+// initiator gets stage 2
+func (lm *LinkManager) finish(lk *link, name string, hs *Handshake) (*HandshakeResult, error) {
+	initiated := lk.initiated
+	if initiated == nil || initiated.id != hs.SessionID {
+		lm.l.Debug("stage 2 of not initiated handshake, ignoring it")
+		return &HandshakeResult{}, nil // eg duplicated datagram
+	}
+	responderKey, err := ecdh.X25519().NewPublicKey(hs.EphemeralKey)
+	if err != nil {
+		return nil, PeersterError{ErrorMsg: "malformed ephemeral key"}
+	}
+
+	s, err := initSession(hs.SessionID, initiated.ephemeralKey, responderKey, true, initiated.name, hs.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !lm.isHandshakeAuthentic(hs, s, "responder") {
+		return nil, PeersterError{ErrorMsg: "responder failed to prove, that it's " + hs.Name}
+	}
+	lk.initiated = nil
+
+	reply := &Handshake{Stage: 3, SessionID: hs.SessionID}
+	lm.signHandshake(reply, name, s)
+	return &HandshakeResult{Reply: reply, Flushed: lm.establish(lk, s, hs.Name), Name: hs.Name}, nil
+}
+
+// responder gets stage 3
+func (lm *LinkManager) confirm(lk *link, hs *Handshake) (*HandshakeResult, error) {
+	s := lk.responded
+	if s == nil || s.id != hs.SessionID {
+		lm.l.Debug("stage 3 of not responded handshake, ignoring it")
+		return &HandshakeResult{}, nil
+	}
+	if hs.Name != s.initiatorName {
+		return nil, PeersterError{ErrorMsg: "initiator claimed to be " + s.initiatorName + ", but signs as " + hs.Name}
+	}
+	if !lm.isHandshakeAuthentic(hs, s, "initiator") {
+		return nil, PeersterError{ErrorMsg: "initiator failed to prove, that it's " + hs.Name}
+	}
+	lk.responded = nil
+
+	return &HandshakeResult{Flushed: lm.establish(lk, s, hs.Name), Name: hs.Name}, nil
+}
+
+// returns pending datagrams sealed with the new session
+func (lm *LinkManager) establish(lk *link, s *session, name string) []*Sealed {
+	lk.name = name
+	lk.sessions = append(lk.sessions, s)
+	if len(lk.sessions) > MaxLinkSessions {
+		lk.sessions = lk.sessions[len(lk.sessions)-MaxLinkSessions:]
+	}
+
+	flushed := make([]*Sealed, 0, len(lk.pending))
+	for _, datagram := range lk.pending {
+		flushed = append(flushed, s.seal(datagram))
+	}
+	lk.pending = nil
+	return flushed
+}
+
+func (lm *LinkManager) signHandshake(hs *Handshake, name string, s *session) {
+	role := "initiator"
+	if hs.Stage == 2 {
+		role = "responder"
+	}
+	hs.Name = name
+	hs.PublicKey = lm.identity.PublicKey()
+	hs.Signature = lm.identity.Sign(s.transcript(role))
+}
+
+func (lm *LinkManager) isHandshakeAuthentic(hs *Handshake, s *session, role string) bool {
+	return hs.Name != "" && lm.keyStore.Verify(hs.Name, hs.PublicKey, s.transcript(role), hs.Signature)
+}
+
+func (lm *LinkManager) startHandshakeIfNeeded(lk *link, name string) *Handshake {
+	if lk.initiated != nil && lm.clock.Since(lk.initiated.started) < LinkHandshakeTimeout {
+		return nil // still waiting for the answer
+	}
+
+	ephemeralKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		lm.l.Error("unable to generate ephemeral key: " + err.Error())
+		return nil
+	}
+	lk.initiated = &initiatedHandshake{id: mrand.Uint64(), ephemeralKey: ephemeralKey, name: name, started: lm.clock.Now()}
+	return &Handshake{Stage: 1, SessionID: lk.initiated.id, EphemeralKey: ephemeralKey.PublicKey().Bytes(), Name: name}
+}
+
+// returns nil, if there are too many links already
+func (lm *LinkManager) getOrCreateLink(address string) *link {
+	lk, ok := lm.links[address]
+	if ok {
+		return lk
+	}
+	if len(lm.links) >= MaxSecureLinks {
+		lm.l.Warn("too many links, ignoring " + address)
+		return nil
+	}
+
+	lk = &link{}
+	lm.links[address] = lk
+	return lk
+}
+
+// ephemeralKey is own one, otherKey is of the other side, names are claimed by the sides
+func initSession(id uint64, ephemeralKey *ecdh.PrivateKey, otherKey *ecdh.PublicKey, isInitiator bool, initiatorName string, responderName string) (*session, error) {
+	shared, err := ephemeralKey.ECDH(otherKey)
+	if err != nil {
+		return nil, err // other key is of low order
+	}
+
+	s := &session{id: id, initiatorKey: ephemeralKey.PublicKey().Bytes(), responderKey: otherKey.Bytes(), initiatorName: initiatorName, responderName: responderName}
+	if !isInitiator {
+		s.initiatorKey, s.responderKey = s.responderKey, s.initiatorKey
+	}
+
+	master := sha256.New()
+	master.Write([]byte("peerster link"))
+	binary.Write(master, binary.LittleEndian, id)
+	master.Write(s.initiatorKey)
+	master.Write(s.responderKey)
+	master.Write(s.names())
+	master.Write(shared)
+	masterKey := master.Sum(nil)
+
+	initiatorToResponder, err := initAead(masterKey, "initiator")
+	if err != nil {
+		return nil, err
+	}
+	responderToInitiator, err := initAead(masterKey, "responder")
+	if err != nil {
+		return nil, err
+	}
+
+	s.send, s.receive = initiatorToResponder, responderToInitiator
+	if !isInitiator {
+		s.send, s.receive = responderToInitiator, initiatorToResponder
+	}
+	return s, nil
+}
+
+func initAead(masterKey []byte, direction string) (cipher.AEAD, error) {
+	key := sha256.Sum256(append(append([]byte{}, masterKey...), direction...))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// what is signed by the side with the role
+func (s *session) transcript(role string) []byte {
+	transcript := []byte("peerster link " + role)
+	transcript = binary.LittleEndian.AppendUint64(transcript, s.id)
+	transcript = append(transcript, s.initiatorKey...)
+	transcript = append(transcript, s.responderKey...)
+	return append(transcript, s.names()...)
+}
+
+// both names with their lengths, so that the border between them cannot be moved
+func (s *session) names() []byte {
+	names := binary.LittleEndian.AppendUint32(nil, uint32(len(s.initiatorName)))
+	names = append(names, s.initiatorName...)
+	names = binary.LittleEndian.AppendUint32(names, uint32(len(s.responderName)))
+	return append(names, s.responderName...)
+}
+
+func (s *session) seal(datagram []byte) *Sealed {
+	s.sentCounter++
+	ciphertext := s.send.Seal(nil, nonceOf(s.send, s.sentCounter), datagram, nil)
+	return &Sealed{SessionID: s.id, Counter: s.sentCounter, Ciphertext: ciphertext}
+}
+
+// counter never repeats in the session, so it's used as nonce
+func nonceOf(aead cipher.AEAD, counter uint64) []byte {
+	nonce := make([]byte, aead.NonceSize())
+	binary.LittleEndian.PutUint64(nonce, counter)
+	return nonce
+}
@@ -0,0 +1,190 @@
+package links
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	. "github.com/SubutaiBogatur/Peerster/config"
+	. "github.com/SubutaiBogatur/Peerster/models"
+	. "github.com/SubutaiBogatur/Peerster/models/identity"
+	"github.com/SubutaiBogatur/Peerster/utils/clock"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	addressA = "10.0.0.1:5000"
+	addressB = "10.0.0.1:5001"
+)
+
+type side struct {
+	name     string
+	lm       *LinkManager
+	keyStore *KeyStore
+	clock    *clock.FakeClock
+}
+
+func newSide(t *testing.T, name string) *side {
+	id, _ := LoadOrCreateIdentity("")
+	ks, _ := InitKeyStore("", log.WithField("test", t.Name()))
+	ks.Bind(name, id.PublicKey())
+	fc := clock.NewFakeClock(time.Unix(0, 0))
+	return &side{name: name, lm: InitLinkManager(id, ks, fc, log.WithField("test", t.Name()).WithField("side", name)), keyStore: ks, clock: fc}
+}
+
+// a sends the first datagram to b, returns it sealed, as it's flushed after the handshake
+func establish(t *testing.T, a *side, b *side, datagram []byte) *Sealed {
+	sealed, stage1 := a.lm.Seal(addressB, a.name, datagram)
+	if sealed != nil || stage1 == nil || stage1.Stage != 1 {
+		t.Fatal("datagram is sealed before handshake")
+	}
+	if _, again := a.lm.Seal(addressB, a.name, datagram); again != nil {
+		t.Fatal("handshake is started twice")
+	}
+
+	stage2 := handle(t, b, addressA, "b", stage1)
+	stage3 := handle(t, a, addressB, "a", stage2.Reply)
+	if stage3.Name != "b" || len(stage3.Flushed) != 2 {
+		t.Fatal("initiator doesn't establish the link after stage 2")
+	}
+	confirmed := handle(t, b, addressA, "b", stage3.Reply)
+	if confirmed.Name != "a" || confirmed.Reply != nil {
+		t.Fatal("responder doesn't establish the link after stage 3")
+	}
+	return stage3.Flushed[0]
+}
+
+func handle(t *testing.T, s *side, from string, name string, hs *Handshake) *HandshakeResult {
+	t.Helper()
+	result, err := s.lm.HandleHandshake(from, name, hs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestHandshakeEstablishesLink(t *testing.T) {
+	a, b := newSide(t, "a"), newSide(t, "b")
+	first := establish(t, a, b, []byte("first"))
+
+	opened, _, err := b.lm.Open(addressA, b.name, first)
+	if err != nil || !bytes.Equal(opened, []byte("first")) {
+		t.Fatal("responder cannot open datagram, which waited for handshake")
+	}
+
+	reply, handshake := b.lm.Seal(addressA, b.name, []byte("reply"))
+	if reply == nil || handshake != nil {
+		t.Fatal("responder doesn't use established link")
+	}
+	opened, _, err = a.lm.Open(addressB, a.name, reply)
+	if err != nil || !bytes.Equal(opened, []byte("reply")) {
+		t.Fatal("initiator cannot open reply")
+	}
+	if b.lm.GetLinksCopy()[addressA] != "a" || a.lm.GetLinksCopy()[addressB] != "b" {
+		t.Fatal("links don't know authenticated names")
+	}
+}
+
+func TestReplayedAndChangedDatagramsAreRejected(t *testing.T) {
+	a, b := newSide(t, "a"), newSide(t, "b")
+	establish(t, a, b, []byte("first"))
+
+	sealed := make([]*Sealed, 0)
+	for i := 0; i < 3; i++ {
+		s, _ := a.lm.Seal(addressB, a.name, []byte{byte(i)})
+		sealed = append(sealed, s)
+	}
+
+	// out of order is fine, the same counter twice is not
+	for _, index := range []int{2, 0} {
+		if _, _, err := b.lm.Open(addressA, b.name, sealed[index]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, _, err := b.lm.Open(addressA, b.name, sealed[2]); err == nil {
+		t.Fatal("replayed datagram is accepted")
+	}
+
+	changed := *sealed[1]
+	changed.Ciphertext = append([]byte{}, changed.Ciphertext...)
+	changed.Ciphertext[0] ^= 1
+	if _, _, err := b.lm.Open(addressA, b.name, &changed); err == nil {
+		t.Fatal("changed datagram is accepted")
+	}
+	if _, _, err := b.lm.Open(addressA, b.name, sealed[1]); err != nil {
+		t.Fatal("changed copy spoiled the original datagram")
+	}
+	if _, _, err := b.lm.Open(addressB, b.name, sealed[1]); err == nil {
+		t.Fatal("datagram is accepted from another address")
+	}
+}
+
+func TestImpostorIsRejected(t *testing.T) {
+	a, b, mallory := newSide(t, "a"), newSide(t, "b"), newSide(t, "mallory")
+	b.keyStore.Bind("mallory", []byte("0123456789abcdef0123456789abcdef")) // b knows another key of mallory
+
+	// mallory initiates and claims its name, its key is not the pinned one
+	_, stage1 := mallory.lm.Seal(addressB, mallory.name, []byte("hi"))
+	stage2 := handle(t, b, addressA, "b", stage1)
+	stage3 := handle(t, mallory, addressB, "mallory", stage2.Reply)
+	if _, err := b.lm.HandleHandshake(addressA, "b", stage3.Reply); err == nil {
+		t.Fatal("initiator with wrong key is accepted")
+	}
+
+	// mallory responds to a and claims to be b, whose key a already knows
+	a.keyStore.Bind("b", []byte("0123456789abcdef0123456789abcdef"))
+	_, stage1 = a.lm.Seal(addressB, a.name, []byte("hi"))
+	stage2 = handle(t, mallory, addressA, "b", stage1)
+	if _, err := a.lm.HandleHandshake(addressB, "a", stage2.Reply); err == nil {
+		t.Fatal("responder with wrong key is accepted")
+	}
+	if len(a.lm.GetLinksCopy()) != 0 || len(b.lm.GetLinksCopy()) != 0 {
+		t.Fatal("link with impostor is established")
+	}
+}
+
+// mallory relays handshake of a to b, but claims its own name, so that b takes datagrams of a for ones of mallory
+func TestClaimedNamesAreBoundToSession(t *testing.T) {
+	a, b := newSide(t, "a"), newSide(t, "b")
+
+	_, stage1 := a.lm.Seal(addressB, a.name, []byte("hi"))
+	stage1.Name = "mallory"
+	stage2 := handle(t, b, addressA, "b", stage1)
+	if _, err := a.lm.HandleHandshake(addressB, "a", stage2.Reply); err == nil {
+		t.Fatal("responder, which was told another name of initiator, is accepted")
+	}
+
+	if len(b.lm.GetLinksCopy()) != 0 || len(a.lm.GetLinksCopy()) != 0 {
+		t.Fatal("link is established with claimed name")
+	}
+}
+
+func TestHandshakeIsRestartedAfterTimeout(t *testing.T) {
+	a := newSide(t, "a")
+	_, first := a.lm.Seal(addressB, a.name, []byte("hi"))
+
+	a.clock.Advance(LinkHandshakeTimeout)
+	_, second := a.lm.Seal(addressB, a.name, []byte("hi"))
+	if second == nil || second.SessionID == first.SessionID {
+		t.Fatal("handshake is not restarted")
+	}
+}
+
+func TestReplayWindow(t *testing.T) {
+	w := &replayWindow{}
+	for _, counter := range []uint64{1, 5, 3, 70, 10} {
+		if !w.isFresh(counter) {
+			t.Fatalf("fresh counter %d is rejected", counter)
+		}
+		w.accept(counter)
+	}
+	// 6 is already out of the window, as 70 - 6 = 64
+	for _, counter := range []uint64{0, 1, 5, 70, 10, 6} {
+		if w.isFresh(counter) {
+			t.Fatalf("counter %d is accepted twice", counter)
+		}
+	}
+	if !w.isFresh(7) || !w.isFresh(71) {
+		t.Fatal("not seen counter in the window is rejected")
+	}
+}
@@ -0,0 +1,36 @@
+package links
+
+// remembers counters of datagrams received in the session. Counters can come out of order, but only 64 latest
+// counters are remembered, older datagrams are dropped as replayed
+type replayWindow struct {
+	highest uint64 // highest accepted counter, counters start from 1
+	seen    uint64 // bit i is set, if counter highest-i is accepted
+}
+
+const replayWindowSize = 64
+
+// returns true, if datagram with the counter wasn't accepted yet
+func (w *replayWindow) isFresh(counter uint64) bool {
+	if counter == 0 {
+		return false
+	}
+	if counter > w.highest {
+		return true
+	}
+	age := w.highest - counter
+	return age < replayWindowSize && w.seen&(1<<age) == 0
+}
+
+// called only for fresh counters of authentic datagrams
+func (w *replayWindow) accept(counter uint64) {
+	if counter > w.highest {
+		shift := counter - w.highest
+		if shift >= replayWindowSize {
+			w.seen = 0
+		} else {
+			w.seen <<= shift
+		}
+		w.highest = counter
+	}
+	w.seen |= 1 << (w.highest - counter)
+}
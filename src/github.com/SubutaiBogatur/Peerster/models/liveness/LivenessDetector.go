@@ -0,0 +1,148 @@
+package liveness
+
+import (
+	. "github.com/SubutaiBogatur/Peerster/config"
+	"github.com/SubutaiBogatur/Peerster/utils/clock"
+	log "github.com/sirupsen/logrus"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	AlivePeer     = "alive"
+	SuspectedPeer = "suspected"
+	DeadPeer      = "dead"
+)
+
+// what this gossiper thinks about the neighbour
+type PeerLiveness struct {
+	Status    string    // AlivePeer, SuspectedPeer or DeadPeer
+	LastHeard time.Time // zero, if nothing was ever received from the neighbour
+}
+
+type peerState struct {
+	PeerLiveness
+	suspectedAt time.Time // when became suspected or dead
+	retriedAt   time.Time // when dead peer was probed last time, or when it died
+}
+
+// Failure detector in SWIM manner for neighbours. Every LivenessProbePeriod one neighbour is probed, if neither it nor
+// helpers (indirect probes) answer till the end of the period, the neighbour is suspected. Any packet from suspected
+// neighbour refutes suspicion, if nothing comes for LivenessSuspicionTimeout, neighbour is dead and is removed from
+// peers. Dead neighbours are probed every DeadPeerRetryPeriod, so that they are re-admitted, when they come back,
+// and are forgotten after DeadPeerForgetTimeout. Membership is not gossiped, every gossiper judges only its neighbours
+// accessed from message-processor, liveness-probing thread and from webserver, is hard-synchronized
+type LivenessDetector struct {
+	peers map[string]*peerState // address -> state
+
+	mux   sync.Mutex
+	clock clock.Clock
+	l     *log.Entry // logger
+}
+
+func InitLivenessDetector(clock clock.Clock, l *log.Entry) *LivenessDetector {
+	return &LivenessDetector{peers: make(map[string]*peerState), clock: clock, l: l}
+}
+
+// any packet from the neighbour shows, that it's alive. Returns previous status, "" for unknown neighbour
+func (ld *LivenessDetector) MarkHeardFrom(address string) string {
+	ld.mux.Lock()
+	defer ld.mux.Unlock()
+
+	state, ok := ld.peers[address]
+	if !ok {
+		ld.peers[address] = &peerState{PeerLiveness: PeerLiveness{Status: AlivePeer, LastHeard: ld.clock.Now()}}
+		return ""
+	}
+	previous := state.Status
+	if previous != AlivePeer {
+		ld.l.Info("peer " + address + " is alive again, was " + previous)
+	}
+	state.Status = AlivePeer
+	state.LastHeard = ld.clock.Now()
+	return previous
+}
+
+// returns true, if something came from the neighbour after the moment
+func (ld *LivenessDetector) HeardSince(address string, moment time.Time) bool {
+	ld.mux.Lock()
+	defer ld.mux.Unlock()
+
+	state, ok := ld.peers[address]
+	return ok && !state.LastHeard.Before(moment)
+}
+
+// neighbour didn't answer the probe. Returns true, if alive neighbour became suspected
+func (ld *LivenessDetector) Suspect(address string) bool {
+	ld.mux.Lock()
+	defer ld.mux.Unlock()
+
+	state, ok := ld.peers[address]
+	if !ok {
+		state = &peerState{PeerLiveness: PeerLiveness{Status: AlivePeer}}
+		ld.peers[address] = state
+	}
+	if state.Status != AlivePeer {
+		return false
+	}
+	ld.l.Info("peer " + address + " is suspected")
+	state.Status = SuspectedPeer
+	state.suspectedAt = ld.clock.Now()
+	return true
+}
+
+// suspected neighbours, which didn't refute suspicion for LivenessSuspicionTimeout, become dead, they are returned
+func (ld *LivenessDetector) ExpireSuspicions() []string {
+	ld.mux.Lock()
+	defer ld.mux.Unlock()
+
+	dead := make([]string, 0)
+	for address, state := range ld.peers {
+		if state.Status == SuspectedPeer && ld.clock.Since(state.suspectedAt) >= LivenessSuspicionTimeout {
+			ld.l.Info("peer " + address + " is dead")
+			state.Status = DeadPeer
+			state.suspectedAt = ld.clock.Now()
+			state.retriedAt = ld.clock.Now()
+			dead = append(dead, address)
+		}
+	}
+	sort.Strings(dead)
+	return dead
+}
+
+// dead neighbours, which should be probed now, to see if they came back. Too long dead ones are forgotten
+func (ld *LivenessDetector) GetDeadToRetry() []string {
+	ld.mux.Lock()
+	defer ld.mux.Unlock()
+
+	retry := make([]string, 0)
+	for address, state := range ld.peers {
+		if state.Status != DeadPeer {
+			continue
+		}
+		if ld.clock.Since(state.suspectedAt) >= DeadPeerForgetTimeout {
+			ld.l.Info("forgetting dead peer " + address)
+			delete(ld.peers, address)
+			continue
+		}
+		if ld.clock.Since(state.retriedAt) >= DeadPeerRetryPeriod {
+			state.retriedAt = ld.clock.Now()
+			retry = append(retry, address)
+		}
+	}
+	sort.Strings(retry)
+	return retry
+}
+
+// address -> liveness, for all the neighbours, which are not forgotten
+func (ld *LivenessDetector) GetPeersCopy() map[string]PeerLiveness {
+	ld.mux.Lock()
+	defer ld.mux.Unlock()
+
+	peers := make(map[string]PeerLiveness)
+	for address, state := range ld.peers {
+		peers[address] = state.PeerLiveness
+	}
+	return peers
+}
@@ -0,0 +1,71 @@
+package liveness
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/SubutaiBogatur/Peerster/config"
+	"github.com/SubutaiBogatur/Peerster/utils/clock"
+	log "github.com/sirupsen/logrus"
+)
+
+const peer = "10.0.0.1:5000"
+
+func newDetector(t *testing.T) (*LivenessDetector, *clock.FakeClock) {
+	fc := clock.NewFakeClock(time.Unix(0, 0))
+	return InitLivenessDetector(fc, log.WithField("test", t.Name())), fc
+}
+
+func TestSuspectedPeerDiesUnlessHeard(t *testing.T) {
+	ld, fc := newDetector(t)
+	ld.MarkHeardFrom(peer)
+	start := fc.Now()
+
+	fc.Advance(time.Second)
+	if ld.HeardSince(peer, fc.Now()) || !ld.HeardSince(peer, start) {
+		t.Fatal("wrong time of the last packet")
+	}
+	if !ld.Suspect(peer) || ld.Suspect(peer) {
+		t.Fatal("peer is suspected not once")
+	}
+	if ld.MarkHeardFrom(peer) != SuspectedPeer || ld.GetPeersCopy()[peer].Status != AlivePeer {
+		t.Fatal("packet doesn't refute suspicion")
+	}
+
+	ld.Suspect(peer)
+	fc.Advance(LivenessSuspicionTimeout - time.Millisecond)
+	if len(ld.ExpireSuspicions()) != 0 {
+		t.Fatal("peer dies before suspicion timeout")
+	}
+	fc.Advance(time.Millisecond)
+	if dead := ld.ExpireSuspicions(); len(dead) != 1 || dead[0] != peer || ld.GetPeersCopy()[peer].Status != DeadPeer {
+		t.Fatal("peer doesn't die after suspicion timeout")
+	}
+	if ld.Suspect(peer) || len(ld.ExpireSuspicions()) != 0 {
+		t.Fatal("dead peer dies again")
+	}
+}
+
+func TestDeadPeerIsRetriedThenForgotten(t *testing.T) {
+	ld, fc := newDetector(t)
+	ld.Suspect(peer)
+	fc.Advance(LivenessSuspicionTimeout)
+	ld.ExpireSuspicions()
+
+	if len(ld.GetDeadToRetry()) != 0 {
+		t.Fatal("dead peer is retried at once")
+	}
+	fc.Advance(DeadPeerRetryPeriod)
+	if retry := ld.GetDeadToRetry(); len(retry) != 1 || retry[0] != peer || len(ld.GetDeadToRetry()) != 0 {
+		t.Fatal("dead peer is not retried once in a period")
+	}
+
+	fc.Advance(DeadPeerForgetTimeout)
+	ld.GetDeadToRetry()
+	if _, ok := ld.GetPeersCopy()[peer]; ok {
+		t.Fatal("long dead peer is not forgotten")
+	}
+	if ld.MarkHeardFrom(peer) != "" {
+		t.Fatal("forgotten peer is still known")
+	}
+}
@@ -0,0 +1,103 @@
+package mongering
+
+import (
+	. "github.com/SubutaiBogatur/Peerster/config"
+	. "github.com/SubutaiBogatur/Peerster/models"
+	"sync"
+)
+
+type session struct {
+	rumor *RumorMessage
+	ch    chan *StatusPacket // buffered, only one status is ever put into it, so sender never blocks
+}
+
+// Rumor-mongering sessions, which wait for status feedback, up to MaxMongeringSessionsPerPeer per peer, so that
+// burst of rumors is mongered to the same peer at once. Status goes to the oldest session, whose rumor it
+// acknowledges, peer answers rumors in order, so it's usually the oldest one. Status, which acknowledges nothing
+// (or digest, which doesn't tell), goes to the oldest session, as it was, when there was only one session per peer
+// accessed from message-processor and from rumor-mongering threads, is hard-synchronized
+type MongeringSessions struct {
+	sessions map[string][]*session // peerIp -> sessions from the oldest
+
+	mux sync.Mutex
+}
+
+func InitMongeringSessions() *MongeringSessions {
+	return &MongeringSessions{sessions: make(map[string][]*session)}
+}
+
+// returns channel, where status feedback for the rumor comes, nil if there are too many sessions with the peer
+func (ms *MongeringSessions) Open(peer string, rumor *RumorMessage) chan *StatusPacket {
+	ms.mux.Lock()
+	defer ms.mux.Unlock()
+
+	if len(ms.sessions[peer]) >= MaxMongeringSessionsPerPeer {
+		return nil
+	}
+	ch := make(chan *StatusPacket, 1)
+	ms.sessions[peer] = append(ms.sessions[peer], &session{rumor: rumor, ch: ch})
+	return ch
+}
+
+// forwards status to the session, which waits for it. Returns false, if no session waits for status from the peer
+func (ms *MongeringSessions) Deliver(peer string, sp *StatusPacket) bool {
+	ms.mux.Lock()
+	defer ms.mux.Unlock()
+
+	sessions := ms.sessions[peer]
+	if len(sessions) == 0 {
+		return false
+	}
+	chosen := 0
+	for i, s := range sessions {
+		if isAcknowledged(s.rumor, sp) {
+			chosen = i
+			break
+		}
+	}
+
+	sessions[chosen].ch <- sp
+	ms.remove(peer, chosen) // session cannot eat more, than one status packet
+	return true
+}
+
+// session stops waiting, e.g. because of timeout. Does nothing, if status was already delivered to it
+func (ms *MongeringSessions) Close(peer string, ch chan *StatusPacket) {
+	ms.mux.Lock()
+	defer ms.mux.Unlock()
+
+	for i, s := range ms.sessions[peer] {
+		if s.ch == ch {
+			ms.remove(peer, i)
+			return
+		}
+	}
+}
+
+// number of sessions, which wait for status from the peer
+func (ms *MongeringSessions) Count(peer string) int {
+	ms.mux.Lock()
+	defer ms.mux.Unlock()
+
+	return len(ms.sessions[peer])
+}
+
+// is called under lock
+func (ms *MongeringSessions) remove(peer string, index int) {
+	sessions := ms.sessions[peer]
+	if len(sessions) == 1 {
+		delete(ms.sessions, peer)
+		return
+	}
+	ms.sessions[peer] = append(sessions[:index:index], sessions[index+1:]...)
+}
+
+// true, if full status shows, that the rumor is already received
+func isAcknowledged(rumor *RumorMessage, sp *StatusPacket) bool {
+	for _, ps := range sp.Want {
+		if ps.Identifier == rumor.OriginalName {
+			return ps.NextID > rumor.ID
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package mongering
+
+import (
+	"testing"
+
+	. "github.com/SubutaiBogatur/Peerster/config"
+	. "github.com/SubutaiBogatur/Peerster/models"
+)
+
+const peer = "10.0.0.1:5000"
+
+func statusOf(origin string, nextID uint32) *StatusPacket {
+	return &StatusPacket{Want: []PeerStatus{{Identifier: origin, NextID: nextID}}}
+}
+
+func TestStatusGoesToAcknowledgedSession(t *testing.T) {
+	ms := InitMongeringSessions()
+	first := ms.Open(peer, &RumorMessage{OriginalName: "a", ID: 1})
+	second := ms.Open(peer, &RumorMessage{OriginalName: "b", ID: 1})
+	third := ms.Open(peer, &RumorMessage{OriginalName: "a", ID: 2})
+
+	if !ms.Deliver(peer, statusOf("b", 2)) {
+		t.Fatal("status is not delivered")
+	}
+	if len(first) != 0 || len(second) != 1 || len(third) != 0 {
+		t.Fatal("status goes not to the session of acknowledged rumor")
+	}
+
+	ms.Deliver(peer, statusOf("c", 1)) // acknowledges nothing
+	if len(first) != 1 || len(third) != 0 {
+		t.Fatal("status, which acknowledges nothing, goes not to the oldest session")
+	}
+	if ms.Count(peer) != 1 {
+		t.Fatal("sessions are not removed after delivery")
+	}
+}
+
+func TestSessionsAreBoundedAndClosed(t *testing.T) {
+	ms := InitMongeringSessions()
+	channels := make([]chan *StatusPacket, 0)
+	for i := 0; i < MaxMongeringSessionsPerPeer; i++ {
+		ch := ms.Open(peer, &RumorMessage{OriginalName: "a", ID: uint32(i + 1)})
+		if ch == nil {
+			t.Fatal("session is not opened")
+		}
+		channels = append(channels, ch)
+	}
+	if ms.Open(peer, &RumorMessage{OriginalName: "a", ID: 100}) != nil {
+		t.Fatal("sessions with the peer are not bounded")
+	}
+	if ms.Open("10.0.0.2:5000", &RumorMessage{OriginalName: "a", ID: 100}) == nil {
+		t.Fatal("sessions with another peer are bounded")
+	}
+
+	for _, ch := range channels {
+		ms.Close(peer, ch)
+	}
+	ms.Close(peer, channels[0]) // already closed
+	if ms.Count(peer) != 0 || ms.Deliver(peer, statusOf("a", 1)) {
+		t.Fatal("closed sessions get statuses")
+	}
+}
@@ -0,0 +1,143 @@
+package negotiation
+
+import (
+	. "github.com/SubutaiBogatur/Peerster/config"
+	. "github.com/SubutaiBogatur/Peerster/models"
+	"github.com/SubutaiBogatur/Peerster/utils/clock"
+	log "github.com/sirupsen/logrus"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// optional features, which node understands, when receiving. Feature is used with the peer only if peer has it
+const (
+	BatchingCapability         = "batch"             // Batch packets
+	FragmentationCapability    = "fragment"          // Fragment packets
+	SecureLinksCapability      = "secure-links"      // Handshake and Sealed packets
+	EncryptedPrivateCapability = "encrypted-private" // private messages with Ciphertext
+	LivenessCapability         = "liveness"          // Liveness packets
+	PeerExchangeCapability     = "peer-exchange"     // PeerExchange packets
+	RumorBundleCapability      = "rumor-bundle"      // RumorBundle packets
+	StatusDigestCapability     = "status-digest"     // StatusPacket with Ranges
+)
+
+// what the peer said about itself in hello
+type PeerCapabilities struct {
+	Version      uint32
+	Capabilities []string
+}
+
+type peerState struct {
+	capabilities *PeerCapabilities // nil, until hello from the peer is received
+	greeted      time.Time         // when hello was sent to the peer last time, zero if never
+}
+
+// Nodes greet each other with Hello on first contact. Until hello of the peer is received, peer is considered to be
+// of the first protocol version, which has no optional features, so such peers still get packets, which they decode
+// accessed from message-processor, peer-writer and from webserver, is hard-synchronized
+type CapabilityTable struct {
+	own   []string              // capabilities of this node
+	peers map[string]*peerState // address -> state
+
+	mux   sync.Mutex
+	clock clock.Clock // hello retries are timed with it
+	l     *log.Entry  // logger
+}
+
+func InitCapabilityTable(own []string, clock clock.Clock, l *log.Entry) *CapabilityTable {
+	sorted := append([]string{}, own...)
+	sort.Strings(sorted)
+	return &CapabilityTable{own: sorted, peers: make(map[string]*peerState), clock: clock, l: l}
+}
+
+// hello of this node
+func (ct *CapabilityTable) GetOwnHello(isReply bool) *Hello {
+	return &Hello{Version: ProtocolVersion, Capabilities: append([]string{}, ct.own...), IsReply: isReply}
+}
+
+// returns true, if hello should be sent to the peer, ie peer was never greeted or didn't answer for HelloRetryTimeout
+// when returns true, peer is considered greeted
+func (ct *CapabilityTable) ShouldGreet(address string) bool {
+	ct.mux.Lock()
+	defer ct.mux.Unlock()
+
+	state := ct.getOrCreateState(address)
+	if state.capabilities != nil || !state.greeted.IsZero() && ct.clock.Since(state.greeted) < HelloRetryTimeout {
+		return false
+	}
+	state.greeted = ct.clock.Now()
+	return true
+}
+
+// called, when hello is sent to the peer as an answer
+func (ct *CapabilityTable) MarkGreeted(address string) {
+	ct.mux.Lock()
+	defer ct.mux.Unlock()
+
+	ct.getOrCreateState(address).greeted = ct.clock.Now()
+}
+
+func (ct *CapabilityTable) Record(address string, hello *Hello) {
+	ct.mux.Lock()
+	defer ct.mux.Unlock()
+
+	if hello.Version != ProtocolVersion {
+		ct.l.Info("peer " + address + " has protocol version " + strconv.Itoa(int(hello.Version)) + ", own is " + strconv.Itoa(ProtocolVersion))
+	}
+	capabilities := append([]string{}, hello.Capabilities...)
+	sort.Strings(capabilities)
+	ct.getOrCreateState(address).capabilities = &PeerCapabilities{Version: hello.Version, Capabilities: capabilities}
+	ct.l.Debug("peer " + address + " supports " + strings.Join(capabilities, ","))
+}
+
+// returns true, if hello of the peer is received, so its capabilities are known
+func (ct *CapabilityTable) HasHello(address string) bool {
+	ct.mux.Lock()
+	defer ct.mux.Unlock()
+
+	state, ok := ct.peers[address]
+	return ok && state.capabilities != nil
+}
+
+// returns true, if both this node and the peer have the capability
+func (ct *CapabilityTable) Supports(address string, capability string) bool {
+	ct.mux.Lock()
+	defer ct.mux.Unlock()
+
+	state, ok := ct.peers[address]
+	if !ok || state.capabilities == nil {
+		return false
+	}
+	return contains(ct.own, capability) && contains(state.capabilities.Capabilities, capability)
+}
+
+// address -> capabilities, for peers, whose hello is received
+func (ct *CapabilityTable) GetPeersCopy() map[string]PeerCapabilities {
+	ct.mux.Lock()
+	defer ct.mux.Unlock()
+
+	peers := make(map[string]PeerCapabilities)
+	for address, state := range ct.peers {
+		if state.capabilities != nil {
+			peers[address] = *state.capabilities
+		}
+	}
+	return peers
+}
+
+func (ct *CapabilityTable) getOrCreateState(address string) *peerState {
+	state, ok := ct.peers[address]
+	if !ok {
+		state = &peerState{}
+		ct.peers[address] = state
+	}
+	return state
+}
+
+func contains(sorted []string, s string) bool {
+	i := sort.SearchStrings(sorted, s)
+	return i < len(sorted) && sorted[i] == s
+}
@@ -0,0 +1,50 @@
+package negotiation
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/SubutaiBogatur/Peerster/config"
+	. "github.com/SubutaiBogatur/Peerster/models"
+	"github.com/SubutaiBogatur/Peerster/utils/clock"
+	log "github.com/sirupsen/logrus"
+)
+
+const peer = "10.0.0.1:5000"
+
+func TestFeatureIsUsedOnlyIfBothHaveIt(t *testing.T) {
+	ct := InitCapabilityTable([]string{FragmentationCapability, EncryptedPrivateCapability}, clock.NewFakeClock(time.Unix(0, 0)), log.WithField("test", t.Name()))
+	if ct.Supports(peer, FragmentationCapability) || ct.HasHello(peer) {
+		t.Fatal("peer without hello is considered to support fragments")
+	}
+
+	ct.Record(peer, &Hello{Version: ProtocolVersion, Capabilities: []string{SecureLinksCapability, FragmentationCapability}})
+	if !ct.Supports(peer, FragmentationCapability) || !ct.HasHello(peer) {
+		t.Fatal("common capability is not supported")
+	}
+	if ct.Supports(peer, EncryptedPrivateCapability) || ct.Supports(peer, SecureLinksCapability) {
+		t.Fatal("capability of only one side is supported")
+	}
+	if ct.GetPeersCopy()[peer].Version != ProtocolVersion {
+		t.Fatal("version of the peer is not recorded")
+	}
+}
+
+func TestPeerIsGreetedAgainUntilAnswers(t *testing.T) {
+	fc := clock.NewFakeClock(time.Unix(0, 0))
+	ct := InitCapabilityTable(nil, fc, log.WithField("test", t.Name()))
+
+	if !ct.ShouldGreet(peer) || ct.ShouldGreet(peer) {
+		t.Fatal("new peer should be greeted exactly once")
+	}
+	fc.Advance(HelloRetryTimeout)
+	if !ct.ShouldGreet(peer) {
+		t.Fatal("silent peer is not greeted again")
+	}
+
+	fc.Advance(HelloRetryTimeout)
+	ct.Record(peer, &Hello{Version: ProtocolVersion, IsReply: true})
+	if ct.ShouldGreet(peer) {
+		t.Fatal("peer, which answered, is greeted again")
+	}
+}
@@ -0,0 +1,192 @@
+package peerstore
+
+import (
+	"encoding/json"
+	. "github.com/SubutaiBogatur/Peerster/config"
+	. "github.com/SubutaiBogatur/Peerster/utils"
+	"github.com/SubutaiBogatur/Peerster/utils/clock"
+	log "github.com/sirupsen/logrus"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// what is remembered about the neighbour between restarts
+type PeerRecord struct {
+	Address  string
+	LastSeen time.Time // when something was received from the neighbour last time
+	Failures int       // times the neighbour was found dead since it was seen last time
+	Origins  []string  // origins, best routes to which went through the neighbour
+}
+
+// Address book of neighbours, which is kept in a file in data directory, so that restarted gossiper rejoins the mesh
+// through the neighbours it had and knows, which origins are behind them. Only neighbours, from which something was
+// received, get there. Neighbours, which failed PeerStoreMaxFailures times in a row or were not seen for
+// PeerStoreForgetTimeout, are not restored. File is written only by Save, in-memory state is the source of truth
+// accessed from message-processor, liveness-probing and peer-store-saving threads, is hard-synchronized
+type PeerStore struct {
+	path    string                 // file, "" if address book is not kept on disk
+	records map[string]*PeerRecord // address -> record
+	dirty   bool                   // something changed since the last Save
+
+	mux   sync.Mutex
+	clock clock.Clock
+	l     *log.Entry // logger
+}
+
+// reads address book from the file, if it exists. Path can be "", then nothing is read or written
+func InitPeerStore(path string, clock clock.Clock, l *log.Entry) (*PeerStore, error) {
+	ps := &PeerStore{path: path, records: make(map[string]*PeerRecord), clock: clock, l: l}
+	if path == "" {
+		return ps, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		l.Info("no address book at " + path + ", starting with empty one")
+		return ps, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	records := make([]*PeerRecord, 0)
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, PeersterError{ErrorMsg: "malformed address book " + path + ": " + err.Error()}
+	}
+	for _, record := range records {
+		if record.Address != "" {
+			ps.records[record.Address] = record
+		}
+	}
+	return ps, nil
+}
+
+// something came from the neighbour
+func (ps *PeerStore) MarkSeen(address string) {
+	ps.mux.Lock()
+	defer ps.mux.Unlock()
+
+	record, ok := ps.records[address]
+	if !ok {
+		record = &PeerRecord{Address: address}
+		ps.records[address] = record
+	}
+	record.LastSeen = ps.clock.Now()
+	record.Failures = 0
+	ps.dirty = true
+}
+
+// neighbour was found dead, does nothing for unknown neighbour
+func (ps *PeerStore) MarkFailed(address string) {
+	ps.mux.Lock()
+	defer ps.mux.Unlock()
+
+	if record, ok := ps.records[address]; ok {
+		record.Failures++
+		ps.dirty = true
+	}
+}
+
+// neighbour address -> origins behind it, replaces origins of all the known neighbours
+func (ps *PeerStore) SetOrigins(origins map[string][]string) {
+	ps.mux.Lock()
+	defer ps.mux.Unlock()
+
+	for address, record := range ps.records {
+		behind := append([]string{}, origins[address]...)
+		sort.Strings(behind)
+		if !equalStrings(record.Origins, behind) {
+			record.Origins = behind
+			ps.dirty = true
+		}
+	}
+}
+
+// neighbours worth contacting after restart, from the latest seen
+func (ps *PeerStore) GetUsable() []PeerRecord {
+	ps.mux.Lock()
+	defer ps.mux.Unlock()
+
+	usable := make([]PeerRecord, 0)
+	for _, record := range ps.records {
+		if record.Failures < PeerStoreMaxFailures && ps.clock.Since(record.LastSeen) < PeerStoreForgetTimeout {
+			usable = append(usable, *record)
+		}
+	}
+	sort.Slice(usable, func(i, j int) bool {
+		if !usable[i].LastSeen.Equal(usable[j].LastSeen) {
+			return usable[i].LastSeen.After(usable[j].LastSeen)
+		}
+		return usable[i].Address < usable[j].Address
+	})
+	return usable
+}
+
+// sorted by address
+func (ps *PeerStore) GetRecordsCopy() []PeerRecord {
+	ps.mux.Lock()
+	defer ps.mux.Unlock()
+
+	return ps.getSortedRecords()
+}
+
+// writes address book to the file, if it changed. Neighbours not seen for PeerStoreForgetTimeout are dropped
+func (ps *PeerStore) Save() error {
+	ps.mux.Lock()
+	defer ps.mux.Unlock()
+
+	for address, record := range ps.records {
+		if ps.clock.Since(record.LastSeen) >= PeerStoreForgetTimeout {
+			ps.l.Info("forgetting peer " + address + ", it was not seen for too long")
+			delete(ps.records, address)
+			ps.dirty = true
+		}
+	}
+	if ps.path == "" || !ps.dirty {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(ps.getSortedRecords(), "", "  ")
+	if err != nil {
+		return err
+	}
+	// file is replaced at once, so that crash in the middle doesn't leave half of it
+	tmpPath := ps.path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, ps.path); err != nil {
+		return err
+	}
+	ps.dirty = false
+	ps.l.Debug("address book is saved to " + ps.path)
+	return nil
+}
+
+// called under lock
+func (ps *PeerStore) getSortedRecords() []PeerRecord {
+	records := make([]PeerRecord, 0, len(ps.records))
+	for _, record := range ps.records {
+		copied := *record
+		copied.Origins = append([]string{}, record.Origins...)
+		records = append(records, copied)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Address < records[j].Address
+	})
+	return records
+}
+
+func equalStrings(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,87 @@
+package peerstore
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/SubutaiBogatur/Peerster/config"
+	"github.com/SubutaiBogatur/Peerster/utils/clock"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	peerA = "10.0.0.1:5000"
+	peerB = "10.0.0.1:5001"
+)
+
+func newStore(t *testing.T, path string, fc *clock.FakeClock) *PeerStore {
+	ps, err := InitPeerStore(path, fc, log.WithField("test", t.Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ps
+}
+
+func TestAddressBookSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), PeerStoreFile)
+	fc := clock.NewFakeClock(time.Unix(1000, 0))
+	ps := newStore(t, path, fc)
+
+	ps.MarkSeen(peerA)
+	fc.Advance(time.Second)
+	ps.MarkSeen(peerB)
+	ps.MarkFailed(peerB)
+	ps.MarkFailed("10.0.0.1:6000") // never seen, is not remembered
+	ps.SetOrigins(map[string][]string{peerA: {"c", "b"}})
+	if err := ps.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := newStore(t, path, fc).GetUsable()
+	if len(restored) != 2 || restored[0].Address != peerB || restored[1].Address != peerA {
+		t.Fatal("restored peers are not sorted from the latest seen")
+	}
+	if restored[0].Failures != 1 || len(restored[0].Origins) != 0 {
+		t.Fatal("wrong record of b")
+	}
+	if len(restored[1].Origins) != 2 || restored[1].Origins[0] != "b" || restored[1].Origins[1] != "c" {
+		t.Fatal("origins behind a are not restored")
+	}
+}
+
+func TestFailingAndForgottenPeersAreNotUsable(t *testing.T) {
+	fc := clock.NewFakeClock(time.Unix(1000, 0))
+	ps := newStore(t, "", fc)
+
+	ps.MarkSeen(peerA)
+	for i := 0; i < PeerStoreMaxFailures; i++ {
+		ps.MarkFailed(peerA)
+	}
+	if len(ps.GetUsable()) != 0 {
+		t.Fatal("failing peer is usable")
+	}
+	ps.MarkSeen(peerA)
+	if len(ps.GetUsable()) != 1 {
+		t.Fatal("seen peer is not usable")
+	}
+
+	fc.Advance(PeerStoreForgetTimeout)
+	if len(ps.GetUsable()) != 0 {
+		t.Fatal("long unseen peer is usable")
+	}
+	if err := ps.Save(); err != nil || len(ps.GetRecordsCopy()) != 0 {
+		t.Fatal("long unseen peer is not forgotten")
+	}
+}
+
+func TestMalformedAddressBookIsError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), PeerStoreFile)
+	if err := ioutil.WriteFile(path, []byte("not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := InitPeerStore(path, clock.NewFakeClock(time.Unix(0, 0)), log.WithField("test", t.Name())); err == nil {
+		t.Fatal("malformed address book is accepted")
+	}
+}
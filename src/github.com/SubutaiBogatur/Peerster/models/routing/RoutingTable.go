@@ -0,0 +1,206 @@
+package routing
+
+import (
+	. "github.com/SubutaiBogatur/Peerster/config"
+	. "github.com/SubutaiBogatur/Peerster/utils"
+	"github.com/SubutaiBogatur/Peerster/utils/clock"
+	log "github.com/sirupsen/logrus"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// route to the origin through the neighbour, as the last rumor from the neighbour advertised it
+type Route struct {
+	NextHop   *net.UDPAddr
+	SeqNumber uint32 // id of the rumor of the origin, bigger is fresher
+	HopCount  uint32 // hops from this gossiper to the origin
+	Failed    bool   // packet sent by the route got no reply or the neighbour is silent, route is not used for a while
+
+	failedAt time.Time // zero, if the route is not failed itself
+}
+
+// fresher route is better, of equally fresh ones the shorter is better
+func (r *Route) isBetterThan(other *Route) bool {
+	if r.SeqNumber != other.SeqNumber {
+		return r.SeqNumber > other.SeqNumber
+	}
+	return r.HopCount < other.HopCount
+}
+
+// Routing table in DSDV manner: every rumor of the origin advertises route to it through the neighbour, which sent
+// the rumor. For every origin up to MaxRoutesPerOrigin routes through different neighbours are kept, best is the first.
+// Best route is changed only to a strictly better one, so routes don't flap between equal neighbours
+// Packets go by the best route, which is not failed. Route fails, if packet sent by it got no reply, then it's skipped
+// until fresher advertisement comes through it or RouteFailureTimeout passes. Also all the routes through the
+// neighbour are skipped, while the neighbour doesn't answer. If all the routes failed, the best one is still tried,
+// as failure can be just a lost datagram. Packet is never sent back to the neighbour it came from
+// accessed from message-processor, file-downloading, rumor-mongering and from webserver, is hard-synchronized
+type RoutingTable struct {
+	routes        map[string][]*Route // origin -> routes through different neighbours, sorted from the best
+	missedReplies map[string]int      // neighbour address -> replies missed in a row
+
+	mux   sync.Mutex
+	clock clock.Clock // failures expire by it
+	l     *log.Entry  // logger
+}
+
+func InitRoutingTable(clock clock.Clock, l *log.Entry) *RoutingTable {
+	return &RoutingTable{routes: make(map[string][]*Route), missedReplies: make(map[string]int), clock: clock, l: l}
+}
+
+// rumor with seqNumber and hopCount (counting the last hop) came from the neighbour
+// returns true, if best next hop to the origin changed
+func (rt *RoutingTable) Update(origin string, neighbour *net.UDPAddr, seqNumber uint32, hopCount uint32) bool {
+	rt.mux.Lock()
+	defer rt.mux.Unlock()
+
+	advertised := &Route{NextHop: neighbour, SeqNumber: seqNumber, HopCount: hopCount}
+	routes := rt.routes[origin]
+	var previousBest *Route
+	if len(routes) > 0 {
+		previousBest = routes[0]
+	}
+
+	index := -1
+	for i, route := range routes {
+		if route.NextHop.String() == neighbour.String() {
+			index = i
+		}
+	}
+	if index >= 0 {
+		if !advertised.isBetterThan(routes[index]) {
+			return false // eg old rumor, which neighbour resends
+		}
+		routes[index] = advertised
+	} else {
+		routes = append(routes, advertised)
+	}
+
+	// stable sort keeps the current best first among equal routes
+	sort.SliceStable(routes, func(i, j int) bool {
+		return routes[i].isBetterThan(routes[j])
+	})
+	if previousBest != nil && routes[0] != previousBest && !routes[0].isBetterThan(previousBest) {
+		// new route is as good as the best one, but best is not replaced
+		routes = moveToFront(routes, previousBest)
+	}
+	if len(routes) > MaxRoutesPerOrigin {
+		routes = routes[:MaxRoutesPerOrigin]
+	}
+	rt.routes[origin] = routes
+
+	if previousBest != nil && routes[0].NextHop.String() == previousBest.NextHop.String() {
+		return false
+	}
+	rt.l.Debug("best route to " + origin + " is through " + neighbour.String() + ", seq " + strconv.Itoa(int(seqNumber)) + ", hops " + strconv.Itoa(int(hopCount)))
+	return true
+}
+
+// returns next hop of the best not failed route, which doesn't go back to previousHop. previousHop can be nil
+func (rt *RoutingTable) GetNextHop(origin string, previousHop *net.UDPAddr) (*net.UDPAddr, error) {
+	rt.mux.Lock()
+	defer rt.mux.Unlock()
+
+	routes := rt.routes[origin]
+	if len(routes) == 0 {
+		return nil, PeersterError{ErrorMsg: "no route to " + origin + " is known"}
+	}
+	var bestFailed *Route
+	for _, route := range routes {
+		if previousHop != nil && route.NextHop.String() == previousHop.String() {
+			continue
+		}
+		if !rt.isFailed(route) {
+			return route.NextHop, nil
+		}
+		if bestFailed == nil {
+			bestFailed = route
+		}
+	}
+	if bestFailed == nil {
+		return nil, PeersterError{ErrorMsg: "the only route to " + origin + " leads back to " + previousHop.String()}
+	}
+	return bestFailed.NextHop, nil
+}
+
+// packet to the origin, sent through the next hop, got no reply
+func (rt *RoutingTable) MarkRouteFailed(origin string, nextHop *net.UDPAddr) {
+	rt.mux.Lock()
+	defer rt.mux.Unlock()
+
+	for _, route := range rt.routes[origin] {
+		if route.NextHop.String() == nextHop.String() {
+			rt.l.Info("route to " + origin + " through " + nextHop.String() + " failed")
+			route.failedAt = rt.clock.Now()
+		}
+	}
+}
+
+// neighbour didn't answer, when it was expected to. Returns true, if the neighbour is considered silent after it
+func (rt *RoutingTable) MarkReplyMissed(neighbour *net.UDPAddr) bool {
+	rt.mux.Lock()
+	defer rt.mux.Unlock()
+
+	rt.missedReplies[neighbour.String()]++
+	return rt.missedReplies[neighbour.String()] == NeighbourMissedRepliesLimit
+}
+
+// any packet from the neighbour shows, that it's alive
+func (rt *RoutingTable) MarkHeardFrom(neighbour *net.UDPAddr) {
+	rt.mux.Lock()
+	defer rt.mux.Unlock()
+
+	delete(rt.missedReplies, neighbour.String())
+}
+
+// called under lock
+func (rt *RoutingTable) isFailed(route *Route) bool {
+	if rt.missedReplies[route.NextHop.String()] >= NeighbourMissedRepliesLimit {
+		return true
+	}
+	return !route.failedAt.IsZero() && rt.clock.Since(route.failedAt) < RouteFailureTimeout
+}
+
+// sorted names of origins, to which routes are known
+func (rt *RoutingTable) GetOrigins() []string {
+	rt.mux.Lock()
+	defer rt.mux.Unlock()
+
+	origins := make([]string, 0, len(rt.routes))
+	for origin := range rt.routes {
+		origins = append(origins, origin)
+	}
+	sort.Strings(origins)
+	return origins
+}
+
+// origin -> its routes, best is the first
+func (rt *RoutingTable) GetTableCopy() map[string][]Route {
+	rt.mux.Lock()
+	defer rt.mux.Unlock()
+
+	table := make(map[string][]Route)
+	for origin, routes := range rt.routes {
+		copied := make([]Route, 0, len(routes))
+		for _, route := range routes {
+			c := *route
+			c.Failed = rt.isFailed(route)
+			copied = append(copied, c)
+		}
+		table[origin] = copied
+	}
+	return table
+}
+
+func moveToFront(routes []*Route, route *Route) []*Route {
+	moved := []*Route{route}
+	for _, r := range routes {
+		if r != route {
+			moved = append(moved, r)
+		}
+	}
+	return moved
+}
@@ -0,0 +1,137 @@
+package routing
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	. "github.com/SubutaiBogatur/Peerster/config"
+	"github.com/SubutaiBogatur/Peerster/utils/clock"
+	log "github.com/sirupsen/logrus"
+)
+
+func neighbour(port int) *net.UDPAddr {
+	return &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: port}
+}
+
+func newTable(t *testing.T) (*RoutingTable, *clock.FakeClock) {
+	fc := clock.NewFakeClock(time.Unix(0, 0))
+	return InitRoutingTable(fc, log.WithField("test", t.Name())), fc
+}
+
+func nextHopPort(t *testing.T, rt *RoutingTable, origin string) int {
+	t.Helper()
+	nextHop, err := rt.GetNextHop(origin, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return nextHop.Port
+}
+
+func TestFresherThenShorterRouteIsBest(t *testing.T) {
+	rt, _ := newTable(t)
+
+	if !rt.Update("d", neighbour(1), 5, 3) {
+		t.Fatal("first route is not the best")
+	}
+	if !rt.Update("d", neighbour(2), 6, 7) || nextHopPort(t, rt, "d") != 2 {
+		t.Fatal("fresher route doesn't win, though it's longer")
+	}
+	if rt.Update("d", neighbour(1), 5, 1) {
+		t.Fatal("stale route wins, though it's shorter")
+	}
+	if !rt.Update("d", neighbour(3), 6, 2) || nextHopPort(t, rt, "d") != 3 {
+		t.Fatal("shorter route of the same freshness doesn't win")
+	}
+
+	routes := rt.GetTableCopy()["d"]
+	if len(routes) != 3 || routes[1].NextHop.Port != 2 || routes[2].NextHop.Port != 1 || routes[2].HopCount != 1 {
+		t.Fatalf("alternatives are not kept in order: %+v", routes)
+	}
+}
+
+func TestEqualRouteDoesNotReplaceBest(t *testing.T) {
+	rt, _ := newTable(t)
+	rt.Update("d", neighbour(1), 5, 2)
+
+	if rt.Update("d", neighbour(2), 5, 2) || nextHopPort(t, rt, "d") != 1 {
+		t.Fatal("route flaps to equally good neighbour")
+	}
+	if rt.Update("d", neighbour(1), 4, 1) {
+		t.Fatal("older rumor from the same neighbour changes the route")
+	}
+}
+
+func TestOnlyBestRoutesAreKept(t *testing.T) {
+	rt, _ := newTable(t)
+	for port := 1; port <= MaxRoutesPerOrigin+2; port++ {
+		rt.Update("d", neighbour(port), 1, uint32(port))
+	}
+
+	routes := rt.GetTableCopy()["d"]
+	if len(routes) != MaxRoutesPerOrigin || routes[len(routes)-1].HopCount != MaxRoutesPerOrigin {
+		t.Fatalf("expected %d shortest routes, got %+v", MaxRoutesPerOrigin, routes)
+	}
+	if origins := rt.GetOrigins(); len(origins) != 1 || origins[0] != "d" {
+		t.Fatal("origins are not listed")
+	}
+}
+
+func TestFailedRouteIsSkippedUntilAdvertisedOrExpired(t *testing.T) {
+	rt, fc := newTable(t)
+	rt.Update("d", neighbour(1), 5, 2)
+	rt.Update("d", neighbour(2), 5, 3)
+
+	if nextHop, _ := rt.GetNextHop("d", neighbour(1)); nextHop.Port != 2 {
+		t.Fatal("packet is sent back to the neighbour, which it came from")
+	}
+	rt.MarkRouteFailed("d", neighbour(1))
+	if nextHopPort(t, rt, "d") != 2 || !rt.GetTableCopy()["d"][0].Failed {
+		t.Fatal("failed route is still used")
+	}
+	rt.MarkRouteFailed("d", neighbour(2))
+	if nextHopPort(t, rt, "d") != 1 {
+		t.Fatal("the best route is not tried, when all the routes failed")
+	}
+
+	rt.Update("d", neighbour(3), 5, 4)
+	rt.Update("d", neighbour(2), 6, 3)
+	if nextHopPort(t, rt, "d") != 2 {
+		t.Fatal("fresher advertisement doesn't restore the route")
+	}
+	rt.MarkRouteFailed("d", neighbour(2))
+	if nextHopPort(t, rt, "d") != 3 {
+		t.Fatal("alternative route is not used")
+	}
+	fc.Advance(RouteFailureTimeout)
+	if nextHopPort(t, rt, "d") != 2 {
+		t.Fatal("failure of the route doesn't expire")
+	}
+}
+
+func TestRoutesThroughSilentNeighbourAreSkipped(t *testing.T) {
+	rt, _ := newTable(t)
+	rt.Update("d", neighbour(1), 5, 2)
+	rt.Update("d", neighbour(2), 5, 3)
+
+	for i := 1; i < NeighbourMissedRepliesLimit; i++ {
+		if rt.MarkReplyMissed(neighbour(1)) || nextHopPort(t, rt, "d") != 1 {
+			t.Fatal("neighbour is silent after single missed reply")
+		}
+	}
+	if !rt.MarkReplyMissed(neighbour(1)) || nextHopPort(t, rt, "d") != 2 {
+		t.Fatal("routes through silent neighbour are used")
+	}
+
+	rt.MarkHeardFrom(neighbour(1))
+	if nextHopPort(t, rt, "d") != 1 {
+		t.Fatal("neighbour is still silent after it's heard")
+	}
+	if _, err := rt.GetNextHop("e", nil); err == nil {
+		t.Fatal("next hop to unknown origin is given")
+	}
+	rt.Update("e", neighbour(1), 1, 1)
+	if _, err := rt.GetNextHop("e", neighbour(1)); err == nil {
+		t.Fatal("packet is sent back, as it's the only route")
+	}
+}
@@ -0,0 +1,262 @@
+package sending
+
+import (
+	. "github.com/SubutaiBogatur/Peerster/models"
+	log "github.com/sirupsen/logrus"
+	"strconv"
+	"sync"
+)
+
+// what to do, when packet is pushed to the full queue
+type OverflowPolicy int
+
+const (
+	DropOldest OverflowPolicy = iota // new packet is enqueued, the oldest packet in the queue is dropped
+	DropNewest                       // new packet is dropped, queue stays as is
+)
+
+func (p OverflowPolicy) String() string {
+	switch p {
+	case DropOldest:
+		return "drop-oldest"
+	case DropNewest:
+		return "drop-newest"
+	}
+	return "unknown-policy-" + strconv.Itoa(int(p))
+}
+
+// parses policy, as it's given in command line
+func ParseOverflowPolicy(s string) (OverflowPolicy, bool) {
+	for _, p := range []OverflowPolicy{DropOldest, DropNewest} {
+		if p.String() == s {
+			return p, true
+		}
+	}
+	return DropOldest, false
+}
+
+// state of queues to one peer, returned to the outside world
+type QueueStats struct {
+	Depth       int            // packets waiting to be sent now
+	ClassDepths map[string]int // traffic class name -> packets of the class waiting to be sent
+	Sent        uint64         // packets taken from the queues by writer
+	Dropped     uint64         // packets dropped because of overflow
+}
+
+type peerQueue struct {
+	packets [trafficClassesNumber][]*AddressedGossipPacket // fifo per traffic class, packets[c][0] is the oldest one
+	sent    uint64
+	dropped uint64
+}
+
+// Bounded outbound queues per peer and traffic class. Any thread can push without blocking (so pushing while holding
+// other locks is safe), the only peer-writer thread pops packets. Classes are chosen with weighted round-robin, so
+// statuses are not stuck behind file chunks, but chunks still move. Inside of a class peers are taken in round-robin,
+// so one slow or flooded peer doesn't delay the others
+// accessed from all the threads sending packets and from peer-writer, is hard-synchronized
+type PeerQueues struct {
+	queues   map[string]*peerQueue     // peer address -> its queues
+	order    []string                  // peers in order of first push, used for round-robin
+	nextPeer [trafficClassesNumber]int // per class index in order, where next pop starts looking
+	credits  [trafficClassesNumber]int // packets the class can still send in the current round
+	lengths  [trafficClassesNumber]int // packets of the class waiting in all the queues
+
+	capacity int // per peer and per class
+	policy   OverflowPolicy
+
+	ready chan struct{} // has a value, if some packets were pushed since the last wake-up of writer
+
+	mux sync.Mutex
+	l   *log.Entry // logger
+}
+
+func InitPeerQueues(capacity int, policy OverflowPolicy, l *log.Entry) *PeerQueues {
+	return &PeerQueues{queues: make(map[string]*peerQueue), order: make([]string, 0), capacity: capacity, policy: policy, ready: make(chan struct{}, 1), l: l}
+}
+
+// never blocks, returns false, if the packet itself is dropped
+func (pq *PeerQueues) Push(agp *AddressedGossipPacket) bool {
+	pq.mux.Lock()
+	defer pq.mux.Unlock()
+
+	address := agp.Address.String()
+	queue, ok := pq.queues[address]
+	if !ok {
+		queue = &peerQueue{}
+		pq.queues[address] = queue
+		pq.order = append(pq.order, address)
+	}
+
+	class := ClassifyPacket(agp.Packet)
+	if len(queue.packets[class]) >= pq.capacity {
+		queue.dropped++
+		switch pq.policy {
+		case DropNewest:
+			pq.l.Warn(class.String() + " queue to " + address + " is full, dropping new packet")
+			return false
+		default:
+			pq.l.Warn(class.String() + " queue to " + address + " is full, dropping the oldest packet")
+			queue.packets[class][0] = nil // not to hold the packet in underlying array
+			queue.packets[class] = queue.packets[class][1:]
+			pq.lengths[class]--
+		}
+	}
+
+	queue.packets[class] = append(queue.packets[class], agp)
+	pq.lengths[class]++
+
+	select {
+	case pq.ready <- struct{}{}:
+	default: // writer is already notified
+	}
+	return true
+}
+
+// returns nil, if all the queues are empty
+func (pq *PeerQueues) Pop() *AddressedGossipPacket {
+	pq.mux.Lock()
+	defer pq.mux.Unlock()
+
+	class, ok := pq.chooseClass()
+	if !ok {
+		return nil
+	}
+	pq.credits[class]--
+
+	for i := 0; i < len(pq.order); i++ {
+		index := (pq.nextPeer[class] + i) % len(pq.order)
+		queue := pq.queues[pq.order[index]]
+		if len(queue.packets[class]) == 0 {
+			continue
+		}
+
+		agp := queue.packets[class][0]
+		queue.packets[class][0] = nil
+		queue.packets[class] = queue.packets[class][1:]
+		queue.sent++
+		pq.lengths[class]--
+
+		pq.nextPeer[class] = (index + 1) % len(pq.order)
+		return agp
+	}
+
+	pq.l.Error("class " + class.String() + " has packets, but no queue has them, it's a bug")
+	return nil
+}
+
+// weighted round-robin: class with packets and with credits left in the current round, the most important first
+// when no such class, new round starts. Classes without packets don't keep the others waiting
+func (pq *PeerQueues) chooseClass() (TrafficClass, bool) {
+	for round := 0; round < 2; round++ {
+		for class := TrafficClass(0); class < trafficClassesNumber; class++ {
+			if pq.lengths[class] > 0 && pq.credits[class] > 0 {
+				return class, true
+			}
+		}
+
+		for class := range pq.credits {
+			pq.credits[class] = trafficClassWeights[class]
+		}
+	}
+	return 0, false
+}
+
+// returns the next packet to the given peer, more important classes first, or nil, if the peer has nothing to send
+// used to fill a batch, so doesn't affect weighted round-robin of classes
+func (pq *PeerQueues) PopTo(address string) *AddressedGossipPacket {
+	pq.mux.Lock()
+	defer pq.mux.Unlock()
+
+	queue, ok := pq.queues[address]
+	if !ok {
+		return nil
+	}
+
+	for class := TrafficClass(0); class < trafficClassesNumber; class++ {
+		if len(queue.packets[class]) == 0 {
+			continue
+		}
+
+		agp := queue.packets[class][0]
+		queue.packets[class][0] = nil
+		queue.packets[class] = queue.packets[class][1:]
+		queue.sent++
+		pq.lengths[class]--
+		return agp
+	}
+	return nil
+}
+
+// drops queues to the peer, which is not a neighbour anymore, and its place in round-robin
+// returns number of packets, which were waiting and won't be sent
+func (pq *PeerQueues) Remove(address string) int {
+	pq.mux.Lock()
+	defer pq.mux.Unlock()
+
+	queue, ok := pq.queues[address]
+	if !ok {
+		return 0
+	}
+	delete(pq.queues, address)
+
+	dropped := 0
+	for class := TrafficClass(0); class < trafficClassesNumber; class++ {
+		dropped += len(queue.packets[class])
+		pq.lengths[class] -= len(queue.packets[class])
+	}
+
+	for index, other := range pq.order {
+		if other != address {
+			continue
+		}
+		pq.order = append(pq.order[:index:index], pq.order[index+1:]...)
+		for class := range pq.nextPeer {
+			if pq.nextPeer[class] > index {
+				pq.nextPeer[class]-- // the same peer is the next one
+			}
+			if pq.nextPeer[class] >= len(pq.order) {
+				pq.nextPeer[class] = 0
+			}
+		}
+		break
+	}
+
+	if dropped > 0 {
+		pq.l.Info("dropping " + strconv.Itoa(dropped) + " packets to removed peer " + address)
+	}
+	return dropped
+}
+
+// number of packets waiting in all the queues
+func (pq *PeerQueues) Len() int {
+	pq.mux.Lock()
+	defer pq.mux.Unlock()
+
+	length := 0
+	for _, classLength := range pq.lengths {
+		length += classLength
+	}
+	return length
+}
+
+// receives a value, when new packets are pushed. Writer should Pop until nil after every receive
+func (pq *PeerQueues) Ready() <-chan struct{} {
+	return pq.ready
+}
+
+// peer address -> stats of its queue
+func (pq *PeerQueues) GetStats() map[string]QueueStats {
+	pq.mux.Lock()
+	defer pq.mux.Unlock()
+
+	stats := make(map[string]QueueStats)
+	for address, queue := range pq.queues {
+		peerStats := QueueStats{ClassDepths: make(map[string]int), Sent: queue.sent, Dropped: queue.dropped}
+		for class := TrafficClass(0); class < trafficClassesNumber; class++ {
+			peerStats.ClassDepths[class.String()] = len(queue.packets[class])
+			peerStats.Depth += len(queue.packets[class])
+		}
+		stats[address] = peerStats
+	}
+	return stats
+}
@@ -0,0 +1,190 @@
+package sending
+
+import (
+	"net"
+	"testing"
+
+	. "github.com/SubutaiBogatur/Peerster/models"
+	log "github.com/sirupsen/logrus"
+)
+
+func packetTo(t *testing.T, address string, text string) *AddressedGossipPacket {
+	addr, err := net.ResolveUDPAddr("udp4", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &AddressedGossipPacket{Address: addr, Packet: &GossipPacket{Simple: &SimpleMessage{Text: text}}}
+}
+
+func popTexts(pq *PeerQueues) []string {
+	texts := make([]string, 0)
+	for agp := pq.Pop(); agp != nil; agp = pq.Pop() {
+		texts = append(texts, agp.Packet.Simple.Text)
+	}
+	return texts
+}
+
+func assertTexts(t *testing.T, actual []string, expected ...string) {
+	t.Helper()
+	if len(actual) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, actual)
+	}
+	for i := range expected {
+		if actual[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, actual)
+		}
+	}
+}
+
+func TestOverflowPolicies(t *testing.T) {
+	for _, policy := range []OverflowPolicy{DropOldest, DropNewest} {
+		pq := InitPeerQueues(2, policy, log.WithField("test", t.Name()))
+		pushed := 0
+		for _, text := range []string{"1", "2", "3"} {
+			if pq.Push(packetTo(t, "10.0.0.1:5000", text)) {
+				pushed++
+			}
+		}
+
+		stats := pq.GetStats()["10.0.0.1:5000"]
+		if stats.Depth != 2 || stats.Dropped != 1 {
+			t.Fatalf("%s: unexpected stats %+v", policy, stats)
+		}
+
+		if policy == DropOldest {
+			assertTexts(t, popTexts(pq), "2", "3")
+			if pushed != 3 {
+				t.Fatal("drop-oldest rejected new packet")
+			}
+		} else {
+			assertTexts(t, popTexts(pq), "1", "2")
+			if pushed != 2 {
+				t.Fatal("drop-newest accepted packet to the full queue")
+			}
+		}
+
+		stats = pq.GetStats()["10.0.0.1:5000"]
+		if stats.Depth != 0 || stats.Sent != 2 {
+			t.Fatalf("%s: unexpected stats after popping %+v", policy, stats)
+		}
+	}
+}
+
+func TestPeersAreServedInRoundRobin(t *testing.T) {
+	pq := InitPeerQueues(10, DropOldest, log.WithField("test", t.Name()))
+
+	// flooded peer doesn't delay the others
+	for _, text := range []string{"a1", "a2", "a3", "a4"} {
+		pq.Push(packetTo(t, "10.0.0.1:5000", text))
+	}
+	pq.Push(packetTo(t, "10.0.0.2:5000", "b1"))
+	pq.Push(packetTo(t, "10.0.0.3:5000", "c1"))
+	pq.Push(packetTo(t, "10.0.0.2:5000", "b2"))
+
+	select {
+	case <-pq.Ready():
+	default:
+		t.Fatal("writer is not notified about pushed packets")
+	}
+
+	assertTexts(t, popTexts(pq), "a1", "b1", "c1", "a2", "b2", "a3", "a4")
+}
+
+func TestClassesAreScheduledWithWeights(t *testing.T) {
+	pq := InitPeerQueues(100, DropOldest, log.WithField("test", t.Name()))
+	addr, _ := net.ResolveUDPAddr("udp4", "10.0.0.1:5000")
+
+	for i := 0; i < 20; i++ {
+		pq.Push(&AddressedGossipPacket{Address: addr, Packet: &GossipPacket{DataReply: &DataReply{}}})
+		pq.Push(&AddressedGossipPacket{Address: addr, Packet: &GossipPacket{Private: &PrivateMessage{}}})
+		pq.Push(&AddressedGossipPacket{Address: addr, Packet: &GossipPacket{Status: &StatusPacket{}}})
+	}
+
+	stats := pq.GetStats()["10.0.0.1:5000"]
+	if stats.Depth != 60 || stats.ClassDepths[BulkClass.String()] != 20 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	// when everything is backlogged, classes share the link according to their weights
+	round := trafficClassWeights[ControlClass] + trafficClassWeights[MessageClass] + trafficClassWeights[BulkClass]
+	popped := make(map[TrafficClass]int)
+	for i := 0; i < round; i++ {
+		popped[ClassifyPacket(pq.Pop().Packet)]++
+	}
+	for class := TrafficClass(0); class < trafficClassesNumber; class++ {
+		if popped[class] != trafficClassWeights[class] {
+			t.Fatalf("class %s sent %d packets in a round instead of %d", class, popped[class], trafficClassWeights[class])
+		}
+	}
+
+	// when other classes are empty, bulk uses the whole link
+	for i := 0; i < 60-round; i++ {
+		pq.Pop()
+	}
+	if pq.Pop() != nil {
+		t.Fatal("packets are lost in queues")
+	}
+	pq.Push(&AddressedGossipPacket{Address: addr, Packet: &GossipPacket{DataReply: &DataReply{}}})
+	pq.Push(&AddressedGossipPacket{Address: addr, Packet: &GossipPacket{DataReply: &DataReply{}}})
+	if pq.Pop() == nil || pq.Pop() == nil {
+		t.Fatal("bulk class waits, though nothing else is sent")
+	}
+}
+
+func TestRouteRumorsAreControlTraffic(t *testing.T) {
+	if ClassifyPacket(&GossipPacket{Rumor: &RumorMessage{Text: ""}}) != ControlClass {
+		t.Fatal("route rumor is not control traffic")
+	}
+	if ClassifyPacket(&GossipPacket{Rumor: &RumorMessage{Text: "hi"}}) != MessageClass {
+		t.Fatal("rumor with text is not message traffic")
+	}
+}
+
+func TestRumorBundlesAreControlTraffic(t *testing.T) {
+	if ClassifyPacket(&GossipPacket{RumorBundle: &RumorBundle{}}) != ControlClass {
+		t.Fatal("rumor bundle is not control traffic")
+	}
+}
+
+func TestPopToTakesOnlyPacketsOfThePeer(t *testing.T) {
+	pq := InitPeerQueues(10, DropOldest, log.WithField("test", t.Name()))
+	pq.Push(packetTo(t, "10.0.0.1:5000", "a1"))
+	pq.Push(packetTo(t, "10.0.0.2:5000", "b1"))
+	pq.Push(packetTo(t, "10.0.0.1:5000", "a2"))
+
+	if agp := pq.PopTo("10.0.0.1:5000"); agp == nil || agp.Packet.Simple.Text != "a1" {
+		t.Fatal("first packet to the peer is not popped")
+	}
+	if agp := pq.PopTo("10.0.0.1:5000"); agp == nil || agp.Packet.Simple.Text != "a2" {
+		t.Fatal("second packet to the peer is not popped")
+	}
+	if pq.PopTo("10.0.0.1:5000") != nil || pq.PopTo("10.0.0.3:5000") != nil {
+		t.Fatal("packet is popped from empty queue")
+	}
+	if pq.Len() != 1 {
+		t.Fatalf("expected 1 packet left, got %d", pq.Len())
+	}
+}
+
+func TestRemovedPeerLeavesRoundRobin(t *testing.T) {
+	pq := InitPeerQueues(10, DropOldest, log.WithField("test", t.Name()))
+	for _, text := range []string{"a1", "a2"} {
+		pq.Push(packetTo(t, "10.0.0.1:5000", text))
+	}
+	for _, text := range []string{"b1", "b2", "b3"} {
+		pq.Push(packetTo(t, "10.0.0.2:5000", text))
+	}
+	pq.Push(packetTo(t, "10.0.0.3:5000", "c1"))
+	assertTexts(t, []string{pq.Pop().Packet.Simple.Text, pq.Pop().Packet.Simple.Text}, "a1", "b1")
+
+	if dropped := pq.Remove("10.0.0.2:5000"); dropped != 2 {
+		t.Fatalf("expected 2 dropped packets, got %d", dropped)
+	}
+	if pq.Remove("10.0.0.2:5000") != 0 || pq.Remove("10.0.0.4:5000") != 0 {
+		t.Fatal("packets are dropped for unknown peer")
+	}
+	if _, ok := pq.GetStats()["10.0.0.2:5000"]; ok || len(pq.order) != 2 {
+		t.Fatal("removed peer is still known")
+	}
+	assertTexts(t, popTexts(pq), "c1", "a2")
+}
@@ -0,0 +1,48 @@
+package sending
+
+import (
+	. "github.com/SubutaiBogatur/Peerster/config"
+	. "github.com/SubutaiBogatur/Peerster/models"
+	"strconv"
+)
+
+// outbound packets are scheduled by class, so small control packets are not stuck behind file chunks
+type TrafficClass int
+
+const (
+	ControlClass TrafficClass = iota // statuses, rumor bundles, route rumors, transactions, blocks and liveness: keeps gossip converging
+	MessageClass                     // rumors, private messages, searches and everything not classified else
+	BulkClass                        // file chunks
+
+	trafficClassesNumber = iota
+)
+
+// how many packets of the class are sent in one round of weighted round-robin, when all the classes are backlogged
+var trafficClassWeights = [trafficClassesNumber]int{ControlTrafficWeight, MessageTrafficWeight, BulkTrafficWeight}
+
+func (c TrafficClass) String() string {
+	switch c {
+	case ControlClass:
+		return "control"
+	case MessageClass:
+		return "message"
+	case BulkClass:
+		return "bulk"
+	}
+	return "unknown-class-" + strconv.Itoa(int(c))
+}
+
+func ClassifyPacket(gp *GossipPacket) TrafficClass {
+	switch {
+	case gp.Status != nil, gp.Hello != nil, gp.TxPublish != nil, gp.BlockPublish != nil, gp.Liveness != nil,
+		gp.PeerExchange != nil:
+		return ControlClass
+	case gp.Rumor != nil && gp.Rumor.Text == "": // route rumor
+		return ControlClass
+	case gp.RumorBundle != nil: // answers status, so peer catches up, even when file chunks are sent to it
+		return ControlClass
+	case gp.DataReply != nil:
+		return BulkClass
+	}
+	return MessageClass
+}
@@ -0,0 +1,402 @@
+package transport
+
+import (
+	. "github.com/SubutaiBogatur/Peerster/utils"
+	"github.com/SubutaiBogatur/Peerster/utils/clock"
+	"math/rand"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// In-memory network of transports, used to test gossipers without real sockets
+// Every directed link (from -> to) can be configured to delay, lose, duplicate and reorder datagrams, also
+// network can be split into partitions, which don't hear each other. All the random decisions are made
+// with one seeded generator, so same seed and same sequence of writes give same fate of datagrams. Delays are
+// measured with the clock, so with FakeClock datagrams arrive, when test moves time forward. Delayed datagrams are
+// delivered by one scheduler goroutine, it stops, when network is closed
+
+const simulatedInboxSize = 1024 // datagrams, which are not read yet. If inbox is full, datagram is lost, as in udp
+
+// describes behaviour of a directed link, zero value is a perfect link
+type LinkConfig struct {
+	Latency time.Duration // every datagram is delayed at least for latency
+	Jitter  time.Duration // additional random delay in [0, Jitter)
+
+	Loss        float64 // probability, that datagram is lost
+	Duplication float64 // probability, that datagram is delivered twice
+
+	Reordering   float64       // probability, that datagram is additionally delayed, so later datagrams outrun it
+	ReorderDelay time.Duration // additional delay of reordered datagrams
+}
+
+// counters of what happened with datagrams in the network
+type NetworkStats struct {
+	Sent        int
+	Delivered   int
+	Lost        int // lost because of link loss or because inbox was full
+	Partitioned int // not delivered because of partition
+	Duplicated  int
+	Reordered   int
+}
+
+// is called for every delivered datagram, under network lock, so it must be quick and must not use the network
+type DeliveryObserver func(from *net.UDPAddr, to *net.UDPAddr, data []byte)
+
+type datagram struct {
+	data []byte
+	from *net.UDPAddr
+}
+
+// datagram, which waits for its latency to pass
+type delayedDatagram struct {
+	datagram
+	destination *SimulatedTransport
+	deadline    time.Time
+	seq         uint64 // datagrams with the same deadline are delivered in order of sending
+}
+
+type linkKey struct {
+	from string
+	to   string
+}
+
+type SimulatedNetwork struct {
+	transports map[string]*SimulatedTransport // address -> transport
+
+	defaultLink LinkConfig
+	links       map[linkKey]LinkConfig // overrides default link for some directed links
+	partitions  map[string]int         // address -> partition number, absent addresses are in partition 0
+
+	rand     *rand.Rand
+	stats    NetworkStats
+	nextPort int
+
+	observer DeliveryObserver // can be nil
+
+	delayed   []*delayedDatagram // by deadline, then by seq
+	nextSeq   uint64
+	wake      chan struct{} // has a value, if scheduler should look at delayed datagrams again
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	clock clock.Clock
+	mux   sync.Mutex
+}
+
+// network should be closed, when not needed, to stop its scheduler
+func NewSimulatedNetwork(seed int64, clock clock.Clock) *SimulatedNetwork {
+	sn := &SimulatedNetwork{
+		transports: make(map[string]*SimulatedTransport),
+		links:      make(map[linkKey]LinkConfig),
+		partitions: make(map[string]int),
+		rand:       rand.New(rand.NewSource(seed)),
+		nextPort:   5000,
+		wake:       make(chan struct{}, 1),
+		closed:     make(chan struct{}),
+		clock:      clock,
+	}
+	go sn.startScheduler()
+	return sn
+}
+
+// stops the scheduler, delayed datagrams are lost. Transports are not closed, their owners close them
+func (sn *SimulatedNetwork) Close() {
+	sn.closeOnce.Do(func() {
+		close(sn.closed)
+
+		sn.mux.Lock()
+		defer sn.mux.Unlock()
+		sn.stats.Lost += len(sn.delayed)
+		sn.delayed = nil
+	})
+}
+
+// creates new endpoint in the network. If address is "", then some unique address is chosen
+func (sn *SimulatedNetwork) Listen(address string) (*SimulatedTransport, error) {
+	sn.mux.Lock()
+	defer sn.mux.Unlock()
+
+	if address == "" {
+		for {
+			address = "10.0.0.1:" + strconv.Itoa(sn.nextPort)
+			sn.nextPort++
+			if _, ok := sn.transports[address]; !ok {
+				break
+			}
+		}
+	}
+
+	udpAddr, err := net.ResolveUDPAddr("udp4", address)
+	if err != nil {
+		return nil, err
+	}
+	address = udpAddr.String() // normalized form
+
+	if _, ok := sn.transports[address]; ok {
+		return nil, PeersterError{ErrorMsg: "address " + address + " is already in use in simulated network"}
+	}
+
+	st := &SimulatedTransport{network: sn, addr: udpAddr, inbox: make(chan datagram, simulatedInboxSize), closed: make(chan struct{})}
+	sn.transports[address] = st
+	return st, nil
+}
+
+// sets config for all the links, which are not configured explicitly
+func (sn *SimulatedNetwork) SetDefaultLink(config LinkConfig) {
+	sn.mux.Lock()
+	defer sn.mux.Unlock()
+
+	sn.defaultLink = config
+}
+
+// configures directed link from -> to
+func (sn *SimulatedNetwork) SetLink(from string, to string, config LinkConfig) {
+	sn.mux.Lock()
+	defer sn.mux.Unlock()
+
+	sn.links[linkKey{from: from, to: to}] = config
+}
+
+// configures both directions between a and b
+func (sn *SimulatedNetwork) SetBidirectionalLink(a string, b string, config LinkConfig) {
+	sn.SetLink(a, b, config)
+	sn.SetLink(b, a, config)
+}
+
+// splits the network: datagrams are delivered only inside of a group. Addresses, not mentioned in any group, form one more group
+func (sn *SimulatedNetwork) Partition(groups ...[]string) {
+	sn.mux.Lock()
+	defer sn.mux.Unlock()
+
+	sn.partitions = make(map[string]int)
+	for i, group := range groups {
+		for _, address := range group {
+			sn.partitions[address] = i + 1
+		}
+	}
+}
+
+// removes all the partitions
+func (sn *SimulatedNetwork) Heal() {
+	sn.Partition()
+}
+
+// observer sees every datagram, which reached its destination. Used by tests to watch what nodes say to each other
+func (sn *SimulatedNetwork) SetObserver(observer DeliveryObserver) {
+	sn.mux.Lock()
+	defer sn.mux.Unlock()
+
+	sn.observer = observer
+}
+
+func (sn *SimulatedNetwork) GetStats() NetworkStats {
+	sn.mux.Lock()
+	defer sn.mux.Unlock()
+
+	return sn.stats
+}
+
+func (sn *SimulatedNetwork) getLink(from string, to string) LinkConfig {
+	if config, ok := sn.links[linkKey{from: from, to: to}]; ok {
+		return config
+	}
+	return sn.defaultLink
+}
+
+// decides on datagram fate and schedules its delivery
+func (sn *SimulatedNetwork) send(data []byte, from *net.UDPAddr, to *net.UDPAddr) {
+	sn.mux.Lock()
+	defer sn.mux.Unlock()
+
+	sn.stats.Sent++
+
+	fromAddress := from.String()
+	toAddress := to.String()
+
+	if sn.partitions[fromAddress] != sn.partitions[toAddress] {
+		sn.stats.Partitioned++
+		return
+	}
+
+	destination, ok := sn.transports[toAddress]
+	if !ok {
+		sn.stats.Lost++ // nobody listens there
+		return
+	}
+
+	link := sn.getLink(fromAddress, toAddress)
+	if sn.rand.Float64() < link.Loss {
+		sn.stats.Lost++
+		return
+	}
+
+	copies := 1
+	if sn.rand.Float64() < link.Duplication {
+		sn.stats.Duplicated++
+		copies++
+	}
+
+	for i := 0; i < copies; i++ {
+		delay := link.Latency
+		if link.Jitter > 0 {
+			delay += time.Duration(sn.rand.Int63n(int64(link.Jitter)))
+		}
+		if sn.rand.Float64() < link.Reordering {
+			sn.stats.Reordered++
+			delay += link.ReorderDelay
+		}
+
+		// every copy is independent, receiver can spoil its buffer
+		dataCopy := make([]byte, len(data))
+		copy(dataCopy, data)
+		d := datagram{data: dataCopy, from: from}
+
+		if delay <= 0 {
+			sn.deliver(destination, d)
+		} else {
+			sn.delay(destination, d, delay)
+		}
+	}
+}
+
+// is called under lock
+func (sn *SimulatedNetwork) delay(destination *SimulatedTransport, d datagram, delay time.Duration) {
+	select {
+	case <-sn.closed:
+		sn.stats.Lost++
+		return
+	default:
+	}
+
+	sn.nextSeq++
+	dd := &delayedDatagram{datagram: d, destination: destination, deadline: sn.clock.Now().Add(delay), seq: sn.nextSeq}
+	// seq only grows, so datagram goes after all the others with the same deadline
+	index := sort.Search(len(sn.delayed), func(i int) bool { return sn.delayed[i].deadline.After(dd.deadline) })
+	sn.delayed = append(sn.delayed, nil)
+	copy(sn.delayed[index+1:], sn.delayed[index:])
+	sn.delayed[index] = dd
+
+	if index == 0 {
+		select {
+		case sn.wake <- struct{}{}:
+		default: // scheduler is already woken
+		}
+	}
+}
+
+// sleeps until the earliest deadline of delayed datagrams or until earlier datagram is delayed
+func (sn *SimulatedNetwork) startScheduler() {
+	for {
+		sn.mux.Lock()
+		sn.deliverDue()
+		var timer clock.Timer
+		var fired <-chan time.Time // nil, if nothing is delayed, so never fires
+		if len(sn.delayed) > 0 {
+			timer = sn.clock.NewTimer(sn.delayed[0].deadline.Sub(sn.clock.Now()))
+			fired = timer.C()
+		}
+		sn.mux.Unlock()
+
+		select {
+		case <-fired:
+		case <-sn.wake:
+		case <-sn.closed:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+
+		select {
+		case <-sn.closed:
+			return
+		default:
+		}
+	}
+}
+
+// delivers all the datagrams, whose deadline has come, in order of deadlines
+// is called under lock
+func (sn *SimulatedNetwork) deliverDue() {
+	now := sn.clock.Now()
+	due := 0
+	for due < len(sn.delayed) && !sn.delayed[due].deadline.After(now) {
+		sn.deliver(sn.delayed[due].destination, sn.delayed[due].datagram)
+		due++
+	}
+	sn.delayed = sn.delayed[due:]
+}
+
+// is called under lock
+func (sn *SimulatedNetwork) deliver(destination *SimulatedTransport, d datagram) {
+	select {
+	case <-destination.closed:
+		sn.stats.Lost++
+		return
+	default:
+	}
+
+	select {
+	case destination.inbox <- d:
+		sn.stats.Delivered++
+		if sn.observer != nil {
+			sn.observer(d.from, destination.addr, d.data)
+		}
+	default:
+		sn.stats.Lost++ // inbox is full
+	}
+}
+
+func (sn *SimulatedNetwork) remove(st *SimulatedTransport) {
+	sn.mux.Lock()
+	defer sn.mux.Unlock()
+
+	if sn.transports[st.addr.String()] == st {
+		delete(sn.transports, st.addr.String())
+	}
+}
+
+// endpoint of the simulated network
+type SimulatedTransport struct {
+	network *SimulatedNetwork
+	addr    *net.UDPAddr
+
+	inbox     chan datagram
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func (st *SimulatedTransport) ReadFrom(buffer []byte) (int, *net.UDPAddr, error) {
+	select {
+	case d := <-st.inbox:
+		n := copy(buffer, d.data)
+		return n, d.from, nil
+	case <-st.closed:
+		return 0, nil, PeersterError{ErrorMsg: "simulated transport is closed"}
+	}
+}
+
+func (st *SimulatedTransport) WriteTo(data []byte, addr *net.UDPAddr) (int, error) {
+	select {
+	case <-st.closed:
+		return 0, PeersterError{ErrorMsg: "simulated transport is closed"}
+	default:
+	}
+
+	st.network.send(data, st.addr, addr)
+	return len(data), nil
+}
+
+func (st *SimulatedTransport) LocalAddr() *net.UDPAddr {
+	return st.addr
+}
+
+func (st *SimulatedTransport) Close() error {
+	st.closeOnce.Do(func() {
+		close(st.closed)
+		st.network.remove(st)
+	})
+	return nil
+}
@@ -0,0 +1,149 @@
+package transport
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/SubutaiBogatur/Peerster/utils/clock"
+)
+
+func listen(t *testing.T, sn *SimulatedNetwork) *SimulatedTransport {
+	t.Helper()
+	st, err := sn.Listen("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { st.Close() })
+	return st
+}
+
+// datagrams, which are already in the inbox, reading never blocks
+func inbox(st *SimulatedTransport) []string {
+	received := make([]string, 0)
+	buffer := make([]byte, 64)
+	for len(st.inbox) > 0 {
+		n, _, _ := st.ReadFrom(buffer)
+		received = append(received, string(buffer[:n]))
+	}
+	return received
+}
+
+// waits, until goroutines of fired timers deliver datagrams
+func waitForDelivered(t *testing.T, sn *SimulatedNetwork, delivered int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for sn.GetStats().Delivered < delivered {
+		if time.Now().After(deadline) {
+			t.Fatal("datagrams are not delivered")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestLatencyIsMeasuredWithClock(t *testing.T) {
+	fc := clock.NewFakeClock(time.Unix(0, 0))
+	sn := NewSimulatedNetwork(1, fc)
+	defer sn.Close()
+	sn.SetDefaultLink(LinkConfig{Latency: time.Second})
+	a, b := listen(t, sn), listen(t, sn)
+
+	for _, text := range []string{"1", "2", "3"} {
+		a.WriteTo([]byte(text), b.LocalAddr())
+	}
+	fc.Advance(999 * time.Millisecond)
+	sn.mux.Lock()
+	sn.deliverDue() // as scheduler would do, whenever it wakes up
+	pending := len(sn.delayed)
+	sn.mux.Unlock()
+	if pending != 3 || sn.GetStats().Delivered != 0 {
+		t.Fatal("datagram is delivered before latency passed")
+	}
+
+	fc.Advance(time.Millisecond)
+	waitForDelivered(t, sn, 3)
+	received := inbox(b)
+	if len(received) != 3 || received[0] != "1" || received[1] != "2" || received[2] != "3" {
+		t.Fatal("datagrams with the same latency are reordered")
+	}
+}
+
+func TestClosedNetworkDropsDelayedDatagrams(t *testing.T) {
+	fc := clock.NewFakeClock(time.Unix(0, 0))
+	sn := NewSimulatedNetwork(5, fc)
+	sn.SetDefaultLink(LinkConfig{Latency: time.Second})
+	a, b := listen(t, sn), listen(t, sn)
+
+	a.WriteTo([]byte("before"), b.LocalAddr())
+	sn.Close()
+	sn.Close()
+	a.WriteTo([]byte("after"), b.LocalAddr())
+	fc.Advance(time.Second)
+
+	if stats := sn.GetStats(); stats.Lost != 2 || stats.Delivered != 0 || len(sn.delayed) != 0 {
+		t.Fatal("delayed datagrams are not dropped by closed network")
+	}
+}
+
+func TestLossIsDecidedBySeed(t *testing.T) {
+	lost := func() int {
+		sn := NewSimulatedNetwork(2, clock.NewRealClock())
+		defer sn.Close()
+		sn.SetDefaultLink(LinkConfig{Loss: 0.5})
+		a, b := listen(t, sn), listen(t, sn)
+		for i := 0; i < 100; i++ {
+			a.WriteTo([]byte("x"), b.LocalAddr())
+		}
+		stats := sn.GetStats()
+		if stats.Lost+stats.Delivered != 100 || len(inbox(b)) != stats.Delivered {
+			t.Fatal("datagrams are counted wrong")
+		}
+		return stats.Lost
+	}
+
+	first := lost()
+	if first == 0 || first == 100 {
+		t.Fatal("loss doesn't work")
+	}
+	if lost() != first {
+		t.Fatal("same seed gives different losses")
+	}
+}
+
+func TestPartitionsDontHearEachOther(t *testing.T) {
+	sn := NewSimulatedNetwork(3, clock.NewRealClock())
+	defer sn.Close()
+	a, b, c := listen(t, sn), listen(t, sn), listen(t, sn)
+
+	sn.Partition([]string{a.LocalAddr().String()}, []string{b.LocalAddr().String()})
+	a.WriteTo([]byte("a->b"), b.LocalAddr())
+	b.WriteTo([]byte("b->c"), c.LocalAddr()) // c is in the group of not mentioned addresses
+	c.WriteTo([]byte("c->c"), c.LocalAddr())
+	if sn.GetStats().Partitioned != 2 || len(inbox(b)) != 0 || len(inbox(c)) != 1 {
+		t.Fatal("datagram crosses partition")
+	}
+
+	sn.Heal()
+	a.WriteTo([]byte("a->b"), b.LocalAddr())
+	if received := inbox(b); len(received) != 1 || received[0] != "a->b" {
+		t.Fatal("datagram is not delivered after heal")
+	}
+}
+
+func TestObserverSeesDeliveredDatagrams(t *testing.T) {
+	sn := NewSimulatedNetwork(4, clock.NewRealClock())
+	defer sn.Close()
+	a, b := listen(t, sn), listen(t, sn)
+	sn.SetLink(a.LocalAddr().String(), b.LocalAddr().String(), LinkConfig{Loss: 1})
+
+	observed := make([]string, 0)
+	sn.SetObserver(func(from *net.UDPAddr, to *net.UDPAddr, data []byte) {
+		observed = append(observed, from.String()+">"+to.String()+":"+string(data))
+	})
+
+	a.WriteTo([]byte("lost"), b.LocalAddr())
+	b.WriteTo([]byte("hi"), a.LocalAddr())
+	if len(observed) != 1 || observed[0] != b.LocalAddr().String()+">"+a.LocalAddr().String()+":hi" {
+		t.Fatal("observer doesn't see exactly delivered datagrams")
+	}
+}
@@ -0,0 +1,23 @@
+package transport
+
+import (
+	"net"
+)
+
+// datagram-oriented connection, which gossiper uses to talk with peers
+// it can be real udp socket or in-memory endpoint of the simulated network
+// implementation must be safe for one reader and one writer working simultaneously
+type Transport interface {
+	// blocks until datagram arrives, then copies it to the buffer. If datagram is larger than the buffer, the rest is discarded
+	// returns error, when transport is closed
+	ReadFrom(buffer []byte) (int, *net.UDPAddr, error)
+
+	// sends datagram to the given address, never blocks for long
+	WriteTo(data []byte, addr *net.UDPAddr) (int, error)
+
+	// address, under which other peers know this transport
+	LocalAddr() *net.UDPAddr
+
+	// unblocks ReadFrom, after that transport cannot be used anymore
+	Close() error
+}
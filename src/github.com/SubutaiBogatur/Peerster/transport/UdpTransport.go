@@ -0,0 +1,41 @@
+package transport
+
+import (
+	"net"
+)
+
+// transport over real udp socket
+type UdpTransport struct {
+	conn *net.UDPConn
+}
+
+// address is ip:port, port 0 means os chooses free port
+func NewUdpTransport(address string) (*UdpTransport, error) {
+	udpAddr, err := net.ResolveUDPAddr("udp4", address)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.ListenUDP("udp4", udpAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UdpTransport{conn: conn}, nil
+}
+
+func (ut *UdpTransport) ReadFrom(buffer []byte) (int, *net.UDPAddr, error) {
+	return ut.conn.ReadFromUDP(buffer)
+}
+
+func (ut *UdpTransport) WriteTo(data []byte, addr *net.UDPAddr) (int, error) {
+	return ut.conn.WriteToUDP(data, addr)
+}
+
+func (ut *UdpTransport) LocalAddr() *net.UDPAddr {
+	return ut.conn.LocalAddr().(*net.UDPAddr)
+}
+
+func (ut *UdpTransport) Close() error {
+	return ut.conn.Close()
+}
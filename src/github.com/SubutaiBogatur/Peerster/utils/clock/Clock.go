@@ -0,0 +1,85 @@
+package clock
+
+import "time"
+
+// All the protocol timeouts are measured with a Clock, so tests can substitute real time with FakeClock
+// and move time forward manually instead of waiting for it
+
+type Clock interface {
+	Now() time.Time
+	Since(t time.Time) time.Duration
+	After(d time.Duration) <-chan time.Time
+	NewTimer(d time.Duration) Timer
+	NewTicker(d time.Duration) Ticker
+}
+
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+	Reset(d time.Duration) // next tick is in d, then every d
+}
+
+// clock of the os, used when nothing else is configured
+type RealClock struct{}
+
+func NewRealClock() *RealClock {
+	return &RealClock{}
+}
+
+func (rc *RealClock) Now() time.Time {
+	return time.Now()
+}
+
+func (rc *RealClock) Since(t time.Time) time.Duration {
+	return time.Since(t)
+}
+
+func (rc *RealClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (rc *RealClock) NewTimer(d time.Duration) Timer {
+	return &realTimer{timer: time.NewTimer(d)}
+}
+
+func (rc *RealClock) NewTicker(d time.Duration) Ticker {
+	return &realTicker{ticker: time.NewTicker(d)}
+}
+
+type realTimer struct {
+	timer *time.Timer
+}
+
+func (rt *realTimer) C() <-chan time.Time {
+	return rt.timer.C
+}
+
+func (rt *realTimer) Stop() bool {
+	return rt.timer.Stop()
+}
+
+func (rt *realTimer) Reset(d time.Duration) bool {
+	return rt.timer.Reset(d)
+}
+
+type realTicker struct {
+	ticker *time.Ticker
+}
+
+func (rt *realTicker) C() <-chan time.Time {
+	return rt.ticker.C
+}
+
+func (rt *realTicker) Stop() {
+	rt.ticker.Stop()
+}
+
+func (rt *realTicker) Reset(d time.Duration) {
+	rt.ticker.Reset(d)
+}
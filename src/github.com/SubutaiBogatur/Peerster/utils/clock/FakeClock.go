@@ -0,0 +1,190 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock, which stands still until Advance is called. Timers and tickers fire during Advance in order of their
+// deadlines, channels are buffered with size 1 as in time package, so slow reader misses ticks, but never blocks the clock
+type FakeClock struct {
+	now     time.Time
+	waiters []*fakeWaiter // active timers and tickers
+
+	mux sync.Mutex
+}
+
+type fakeWaiter struct {
+	clock    *FakeClock
+	deadline time.Time
+	period   time.Duration // 0 for timers
+	ch       chan time.Time
+}
+
+func NewFakeClock(start time.Time) *FakeClock {
+	return &FakeClock{now: start}
+}
+
+func (fc *FakeClock) Now() time.Time {
+	fc.mux.Lock()
+	defer fc.mux.Unlock()
+
+	return fc.now
+}
+
+func (fc *FakeClock) Since(t time.Time) time.Duration {
+	return fc.Now().Sub(t)
+}
+
+func (fc *FakeClock) After(d time.Duration) <-chan time.Time {
+	return fc.NewTimer(d).C()
+}
+
+func (fc *FakeClock) NewTimer(d time.Duration) Timer {
+	fc.mux.Lock()
+	defer fc.mux.Unlock()
+
+	fw := &fakeWaiter{clock: fc, ch: make(chan time.Time, 1)}
+	fc.schedule(fw, d)
+	return &fakeTimer{fw}
+}
+
+func (fc *FakeClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+
+	fc.mux.Lock()
+	defer fc.mux.Unlock()
+
+	fw := &fakeWaiter{clock: fc, period: d, ch: make(chan time.Time, 1)}
+	fc.schedule(fw, d)
+	return &fakeTicker{fw}
+}
+
+// number of timers and tickers, which are waiting to fire
+func (fc *FakeClock) WaitersNumber() int {
+	fc.mux.Lock()
+	defer fc.mux.Unlock()
+
+	return len(fc.waiters)
+}
+
+// moves time forward, firing everything, whose deadline has come
+func (fc *FakeClock) Advance(d time.Duration) {
+	fc.mux.Lock()
+	defer fc.mux.Unlock()
+
+	target := fc.now.Add(d)
+	for {
+		next := fc.earliest()
+		if next == nil || next.deadline.After(target) {
+			break
+		}
+
+		fc.now = next.deadline
+		select {
+		case next.ch <- fc.now:
+		default: // previous value is not read yet, drop this one
+		}
+
+		if next.period > 0 {
+			next.deadline = next.deadline.Add(next.period)
+		} else {
+			fc.unschedule(next)
+		}
+	}
+	fc.now = target
+}
+
+// are called under lock:
+
+func (fc *FakeClock) earliest() *fakeWaiter {
+	var ret *fakeWaiter
+	for _, fw := range fc.waiters {
+		if ret == nil || fw.deadline.Before(ret.deadline) {
+			ret = fw
+		}
+	}
+	return ret
+}
+
+func (fc *FakeClock) schedule(fw *fakeWaiter, d time.Duration) {
+	fc.unschedule(fw) // if it was scheduled before
+	fw.deadline = fc.now.Add(d)
+	if d <= 0 && fw.period == 0 {
+		// timer for zero duration fires at once, as in time package
+		select {
+		case fw.ch <- fc.now:
+		default:
+		}
+		return
+	}
+	fc.waiters = append(fc.waiters, fw)
+}
+
+// returns true, if waiter was scheduled
+func (fc *FakeClock) unschedule(fw *fakeWaiter) bool {
+	for i, other := range fc.waiters {
+		if other == fw {
+			fc.waiters = append(fc.waiters[:i], fc.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+type fakeTimer struct {
+	fw *fakeWaiter
+}
+
+func (ft *fakeTimer) C() <-chan time.Time {
+	return ft.fw.ch
+}
+
+func (ft *fakeTimer) Stop() bool {
+	fc := ft.fw.clock
+	fc.mux.Lock()
+	defer fc.mux.Unlock()
+
+	return fc.unschedule(ft.fw)
+}
+
+func (ft *fakeTimer) Reset(d time.Duration) bool {
+	fc := ft.fw.clock
+	fc.mux.Lock()
+	defer fc.mux.Unlock()
+
+	wasActive := fc.unschedule(ft.fw)
+	fc.schedule(ft.fw, d)
+	return wasActive
+}
+
+type fakeTicker struct {
+	fw *fakeWaiter
+}
+
+func (ft *fakeTicker) C() <-chan time.Time {
+	return ft.fw.ch
+}
+
+func (ft *fakeTicker) Stop() {
+	fc := ft.fw.clock
+	fc.mux.Lock()
+	defer fc.mux.Unlock()
+
+	fc.unschedule(ft.fw)
+}
+
+func (ft *fakeTicker) Reset(d time.Duration) {
+	if d <= 0 {
+		panic("non-positive interval for Ticker.Reset")
+	}
+
+	fc := ft.fw.clock
+	fc.mux.Lock()
+	defer fc.mux.Unlock()
+
+	ft.fw.period = d
+	fc.schedule(ft.fw, d)
+}
@@ -0,0 +1,67 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeClockFiresInOrderOfDeadlines(t *testing.T) {
+	fc := NewFakeClock(time.Unix(0, 0))
+	late := fc.NewTimer(3 * time.Second)
+	early := fc.NewTimer(1 * time.Second)
+	ticker := fc.NewTicker(2 * time.Second)
+
+	fc.Advance(1500 * time.Millisecond)
+	select {
+	case now := <-early.C():
+		if now != time.Unix(1, 0) {
+			t.Fatal("timer fired with wrong time: ", now)
+		}
+	default:
+		t.Fatal("early timer didn't fire")
+	}
+	select {
+	case <-late.C():
+		t.Fatal("late timer fired too early")
+	case <-ticker.C():
+		t.Fatal("ticker fired too early")
+	default:
+	}
+
+	fc.Advance(3 * time.Second) // now is 4.5s, ticker shot at 2s and 4s, but the second tick is dropped
+	if tick := <-late.C(); !tick.Equal(time.Unix(3, 0)) {
+		t.Fatal("late timer fired with wrong time: ", tick)
+	}
+	if tick := <-ticker.C(); !tick.Equal(time.Unix(2, 0)) {
+		t.Fatal("ticker fired with wrong time: ", tick)
+	}
+	if fc.WaitersNumber() != 1 {
+		t.Fatal("only ticker should be waiting, but waiters: ", fc.WaitersNumber())
+	}
+}
+
+func TestFakeTimerStopAndReset(t *testing.T) {
+	fc := NewFakeClock(time.Unix(0, 0))
+	timer := fc.NewTimer(time.Second)
+	if !timer.Stop() {
+		t.Fatal("active timer should be stopped")
+	}
+	fc.Advance(2 * time.Second)
+	select {
+	case <-timer.C():
+		t.Fatal("stopped timer fired")
+	default:
+	}
+
+	timer.Reset(time.Second)
+	fc.Advance(time.Second)
+	if tick := <-timer.C(); !tick.Equal(time.Unix(3, 0)) {
+		t.Fatal("reset timer fired with wrong time: ", tick)
+	}
+
+	select {
+	case <-fc.NewTimer(0).C():
+	default:
+		t.Fatal("zero timer should fire at once")
+	}
+}
@@ -86,6 +86,13 @@ func SendToSearchMessaageToLocalPort(keywords []string, budget uint64, port int,
 	sendMessageToLocalPort(csmsg, port, logger)
 }
 
+func SendToProbeMessageToLocalPort(destination string, isTraceroute bool, port int, logger *log.Entry) {
+	logDebug("sending to-probe msg to local client port", logger)
+	tpmsg := &ClientToProbeMessage{Destination: destination, IsTraceroute: isTraceroute}
+	cmsg := &ClientMessage{ToProbe: tpmsg}
+	sendMessageToLocalPort(cmsg, port, logger)
+}
+
 func sendMessageToLocalPort(cmsg *ClientMessage, port int, logger *log.Entry) {
 	packetBytes, err := protobuf.Encode(cmsg)
 	if err != nil {
@@ -104,7 +111,9 @@ func sendMessageToLocalPort(cmsg *ClientMessage, port int, logger *log.Entry) {
 	connToGossiper, err := Dial("udp4", gossiperAddr.String())
 	if err != nil {
 		logError("error dialing: "+err.Error(), logger)
+		return
 	}
+	defer connToGossiper.Close()
 
 	n, err := connToGossiper.Write(packetBytes)
 	if err != nil {
@@ -2,7 +2,6 @@ package webserver
 
 import (
 	"encoding/json"
-	. "github.com/SubutaiBogatur/Peerster/config"
 	. "github.com/SubutaiBogatur/Peerster/gossiper"
 	. "github.com/SubutaiBogatur/Peerster/utils"
 	. "github.com/SubutaiBogatur/Peerster/utils/send-utils"
@@ -19,21 +18,57 @@ import (
 
 // * webserver is a thread, that listens on a given port for http-requests from frontend (ie browser). Frontend
 //    regularly asks for new information about peers and sometimes makes new orders for a gossiper
+// * one webserver can serve many gossipers living in the same process. Gossiper is chosen with "node" query
+//    parameter, which is either peer address or name of the gossiper. If parameter is absent, first gossiper is used
 
-var (
-	g *Gossiper = nil // careful, it's shared by many threads
+var logger = log.WithField("bin", "webs")
 
-	webserverPort = 8080
+type webserver struct {
+	gossipers []*Gossiper // careful, they are shared by many threads, but gossiper methods are thread-safe
+}
 
-	logger = log.WithField("bin", "webs")
-)
+// handler, which works with a gossiper chosen for the request
+type gossiperHandler func(g *Gossiper, w http.ResponseWriter, r *http.Request)
+
+func (ws *webserver) getGossiper(r *http.Request) *Gossiper {
+	node := r.URL.Query().Get("node")
+	if node == "" {
+		return ws.gossipers[0]
+	}
+
+	for _, g := range ws.gossipers {
+		if g.GetPeerAddress().String() == node || g.GetName() == node {
+			return g
+		}
+	}
+	return nil
+}
 
-func getGossiperName(w http.ResponseWriter, r *http.Request) {
+func (ws *webserver) handle(handler gossiperHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		g := ws.getGossiper(r)
+		if g == nil {
+			http.Error(w, "unknown node", http.StatusNotFound)
+			return
+		}
+		handler(g, w, r)
+	}
+}
+
+func (ws *webserver) getNodes(w http.ResponseWriter, r *http.Request) {
+	nodes := make([]map[string]string, 0, len(ws.gossipers))
+	for _, g := range ws.gossipers {
+		nodes = append(nodes, map[string]string{"name": g.GetName(), "address": g.GetPeerAddress().String()})
+	}
+	writeJsonResponse(w, nodes)
+}
+
+func getGossiperName(g *Gossiper, w http.ResponseWriter, r *http.Request) {
 	//logger.Debug("get gossiper name")
 	writeJsonResponse(w, g.GetName())
 }
 
-func setGossiperName(w http.ResponseWriter, r *http.Request) {
+func setGossiperName(g *Gossiper, w http.ResponseWriter, r *http.Request) {
 	body, err := ioutil.ReadAll(r.Body)
 	CheckError(err, logger)
 
@@ -45,7 +80,7 @@ func setGossiperName(w http.ResponseWriter, r *http.Request) {
 	g.SetName(name)
 }
 
-func addPeer(w http.ResponseWriter, r *http.Request) {
+func addPeer(g *Gossiper, w http.ResponseWriter, r *http.Request) {
 	body, err := ioutil.ReadAll(r.Body)
 	CheckError(err, logger)
 
@@ -57,24 +92,24 @@ func addPeer(w http.ResponseWriter, r *http.Request) {
 	g.UpdatePeersIfNeeded(udpAddress)
 }
 
-func getGossiperID(w http.ResponseWriter, r *http.Request) {
+func getGossiperID(g *Gossiper, w http.ResponseWriter, r *http.Request) {
 	//logger.Debug("get id")
 	writeJsonResponse(w, g.GetID(g.GetName()))
 }
 
-func getPeers(w http.ResponseWriter, r *http.Request) {
+func getPeers(g *Gossiper, w http.ResponseWriter, r *http.Request) {
 	//logger.Debug("get peers")
 	peers := g.GetPeersCopy()
 	writeJsonResponse(w, peers)
 }
 
-func getOrigins(w http.ResponseWriter, r *http.Request) {
+func getOrigins(g *Gossiper, w http.ResponseWriter, r *http.Request) {
 	//logger.Debug("get origins")
 	origins := g.GetOriginsCopy()
 	writeJsonResponse(w, origins)
 }
 
-func sendRumorMessage(w http.ResponseWriter, r *http.Request) {
+func sendRumorMessage(g *Gossiper, w http.ResponseWriter, r *http.Request) {
 	logger.Debug("post: send rumor message")
 	body, err := ioutil.ReadAll(r.Body)
 	CheckError(err, logger)
@@ -84,7 +119,7 @@ func sendRumorMessage(w http.ResponseWriter, r *http.Request) {
 	SendRumorMessageToLocalPort(msg, g.GetClientAddress().Port, logger)
 }
 
-func sendPrivateMessage(w http.ResponseWriter, r *http.Request) {
+func sendPrivateMessage(g *Gossiper, w http.ResponseWriter, r *http.Request) {
 	logger.Debug("post: send private message")
 	body, err := ioutil.ReadAll(r.Body)
 	CheckError(err, logger)
@@ -96,7 +131,7 @@ func sendPrivateMessage(w http.ResponseWriter, r *http.Request) {
 	SendPrivateMessageToLocalPort(text, dest, g.GetClientAddress().Port, logger)
 }
 
-func getMessages(w http.ResponseWriter, r *http.Request) {
+func getMessages(g *Gossiper, w http.ResponseWriter, r *http.Request) {
 	//logger.Debug("get messages")
 	rmsgs := g.GetRumorMessages()
 	pmsgs := g.GetPrivateMessages()
@@ -105,7 +140,7 @@ func getMessages(w http.ResponseWriter, r *http.Request) {
 	writeJsonResponse(w, msgs)
 }
 
-func shareFile(w http.ResponseWriter, r *http.Request) {
+func shareFile(g *Gossiper, w http.ResponseWriter, r *http.Request) {
 	logger.Debug("post: share file")
 	body, err := ioutil.ReadAll(r.Body)
 	CheckError(err, logger)
@@ -114,11 +149,11 @@ func shareFile(w http.ResponseWriter, r *http.Request) {
 	SendToShareMessageToLocalPort(path, g.GetClientAddress().Port, logger)
 }
 
-func getSharedFiles(w http.ResponseWriter, r *http.Request) {
+func getSharedFiles(g *Gossiper, w http.ResponseWriter, r *http.Request) {
 	writeJsonResponse(w, g.GetSharedFiles())
 }
 
-func requestFile(w http.ResponseWriter, r *http.Request) {
+func requestFile(g *Gossiper, w http.ResponseWriter, r *http.Request) {
 	logger.Debug("post: request file")
 	body, err := ioutil.ReadAll(r.Body)
 	CheckError(err, logger)
@@ -130,7 +165,7 @@ func requestFile(w http.ResponseWriter, r *http.Request) {
 	// get name
 	filenumber := 0
 	for i := 1; ; i++ {
-		if _, err := os.Stat(filepath.Join(DownloadsPath, dest+"-"+strconv.Itoa(i))); os.IsNotExist(err) {
+		if _, err := os.Stat(filepath.Join(g.GetDownloadsPath(), dest+"-"+strconv.Itoa(i))); os.IsNotExist(err) {
 			filenumber = i
 			break
 		}
@@ -139,7 +174,7 @@ func requestFile(w http.ResponseWriter, r *http.Request) {
 	SendToDownloadMessageToLocalPort(dest+"-"+strconv.Itoa(filenumber), hash, dest, g.GetClientAddress().Port, logger)
 }
 
-func search(w http.ResponseWriter, r *http.Request) {
+func search(g *Gossiper, w http.ResponseWriter, r *http.Request) {
 	logger.Debug("post: search")
 	body, err := ioutil.ReadAll(r.Body)
 	CheckError(err, logger)
@@ -148,7 +183,7 @@ func search(w http.ResponseWriter, r *http.Request) {
 	SendToSearchMessaageToLocalPort(keywords, 0, g.GetClientAddress().Port, logger)
 }
 
-func downloadFound(w http.ResponseWriter, r *http.Request) {
+func downloadFound(g *Gossiper, w http.ResponseWriter, r *http.Request) {
 	logger.Debug("post: download found")
 	body, err := ioutil.ReadAll(r.Body)
 	CheckError(err, logger)
@@ -157,10 +192,51 @@ func downloadFound(w http.ResponseWriter, r *http.Request) {
 	g.DownloadFoundByName(name)
 }
 
-func getSearchMatches(w http.ResponseWriter, r *http.Request) {
+func getSearchMatches(g *Gossiper, w http.ResponseWriter, r *http.Request) {
 	writeJsonResponse(w, g.GetFullSearchMatches())
 }
 
+func probe(g *Gossiper, w http.ResponseWriter, r *http.Request) {
+	logger.Debug("post: probe")
+	body, err := ioutil.ReadAll(r.Body)
+	CheckError(err, logger)
+
+	s := strings.Split(string(body), "|") // terrible, sorry
+	SendToProbeMessageToLocalPort(s[0], len(s) > 1 && s[1] == "traceroute", g.GetClientAddress().Port, logger)
+}
+
+func getProbes(g *Gossiper, w http.ResponseWriter, r *http.Request) {
+	writeJsonResponse(w, g.GetProbes())
+}
+
+func getSendQueues(g *Gossiper, w http.ResponseWriter, r *http.Request) {
+	writeJsonResponse(w, g.GetSendQueuesStats())
+}
+
+func getKeys(g *Gossiper, w http.ResponseWriter, r *http.Request) {
+	writeJsonResponse(w, map[string]interface{}{"own-key": g.GetPublicKey(), "known-keys": g.GetKnownKeys()})
+}
+
+func getLinks(g *Gossiper, w http.ResponseWriter, r *http.Request) {
+	writeJsonResponse(w, g.GetLinks())
+}
+
+func getRoutes(g *Gossiper, w http.ResponseWriter, r *http.Request) {
+	writeJsonResponse(w, g.GetRoutingTable())
+}
+
+func getAddressBook(g *Gossiper, w http.ResponseWriter, r *http.Request) {
+	writeJsonResponse(w, g.GetAddressBook())
+}
+
+func getLiveness(g *Gossiper, w http.ResponseWriter, r *http.Request) {
+	writeJsonResponse(w, g.GetPeersLiveness())
+}
+
+func getCapabilities(g *Gossiper, w http.ResponseWriter, r *http.Request) {
+	writeJsonResponse(w, g.GetPeersCapabilities())
+}
+
 func writeJsonResponse(w http.ResponseWriter, data interface{}) {
 	bytes, err := json.Marshal(data)
 	if err != nil {
@@ -170,31 +246,46 @@ func writeJsonResponse(w http.ResponseWriter, data interface{}) {
 	w.Write(bytes)
 }
 
-func StartWebserver(gossiper *Gossiper) {
-	g = gossiper
-	logger = logger.WithField("a", g.GetPeerAddress().String())
+// serves all the given gossipers on the given port, blocks
+func StartWebserver(port int, gossipers ...*Gossiper) {
+	if len(gossipers) == 0 {
+		logger.Error("no gossipers to serve are given")
+		return
+	}
+	ws := &webserver{gossipers: gossipers}
+	l := logger.WithField("a", gossipers[0].GetPeerAddress().String())
 
-	logger.Debug("started web-server thread")
+	l.Debug("started web-server thread")
 
 	r := mux.NewRouter()
 
-	r.Methods("GET").Subrouter().HandleFunc("/getGossiperName", getGossiperName)
-	r.Methods("POST").Subrouter().HandleFunc("/setGossiperName", setGossiperName)
-	r.Methods("GET").Subrouter().HandleFunc("/getGossiperID", getGossiperID)
-	r.Methods("GET").Subrouter().HandleFunc("/getPeers", getPeers)
-	r.Methods("POST").Subrouter().HandleFunc("/addPeer", addPeer)
-	r.Methods("GET").Subrouter().HandleFunc("/getOrigins", getOrigins)
-	r.Methods("POST").Subrouter().HandleFunc("/sendRumorMessage", sendRumorMessage)
-	r.Methods("POST").Subrouter().HandleFunc("/sendPrivateMessage", sendPrivateMessage)
-	r.Methods("GET").Subrouter().HandleFunc("/getMessages", getMessages)
-	r.Methods("POST").Subrouter().HandleFunc("/shareFile", shareFile)
-	r.Methods("GET").Subrouter().HandleFunc("/getSharedFiles", getSharedFiles)
-	r.Methods("POST").Subrouter().HandleFunc("/requestFile", requestFile)
-	r.Methods("POST").Subrouter().HandleFunc("/search", search)
-	r.Methods("GET").Subrouter().HandleFunc("/getSearchMatches", getSearchMatches)
-	r.Methods("POST").Subrouter().HandleFunc("/downloadFound", downloadFound)
+	r.Methods("GET").Subrouter().HandleFunc("/getNodes", ws.getNodes)
+	r.Methods("GET").Subrouter().HandleFunc("/getGossiperName", ws.handle(getGossiperName))
+	r.Methods("POST").Subrouter().HandleFunc("/setGossiperName", ws.handle(setGossiperName))
+	r.Methods("GET").Subrouter().HandleFunc("/getGossiperID", ws.handle(getGossiperID))
+	r.Methods("GET").Subrouter().HandleFunc("/getPeers", ws.handle(getPeers))
+	r.Methods("POST").Subrouter().HandleFunc("/addPeer", ws.handle(addPeer))
+	r.Methods("GET").Subrouter().HandleFunc("/getOrigins", ws.handle(getOrigins))
+	r.Methods("POST").Subrouter().HandleFunc("/sendRumorMessage", ws.handle(sendRumorMessage))
+	r.Methods("POST").Subrouter().HandleFunc("/sendPrivateMessage", ws.handle(sendPrivateMessage))
+	r.Methods("GET").Subrouter().HandleFunc("/getMessages", ws.handle(getMessages))
+	r.Methods("POST").Subrouter().HandleFunc("/shareFile", ws.handle(shareFile))
+	r.Methods("GET").Subrouter().HandleFunc("/getSharedFiles", ws.handle(getSharedFiles))
+	r.Methods("POST").Subrouter().HandleFunc("/requestFile", ws.handle(requestFile))
+	r.Methods("POST").Subrouter().HandleFunc("/search", ws.handle(search))
+	r.Methods("GET").Subrouter().HandleFunc("/getSearchMatches", ws.handle(getSearchMatches))
+	r.Methods("POST").Subrouter().HandleFunc("/downloadFound", ws.handle(downloadFound))
+	r.Methods("GET").Subrouter().HandleFunc("/getSendQueues", ws.handle(getSendQueues))
+	r.Methods("GET").Subrouter().HandleFunc("/getKeys", ws.handle(getKeys))
+	r.Methods("GET").Subrouter().HandleFunc("/getLinks", ws.handle(getLinks))
+	r.Methods("GET").Subrouter().HandleFunc("/getCapabilities", ws.handle(getCapabilities))
+	r.Methods("GET").Subrouter().HandleFunc("/getRoutes", ws.handle(getRoutes))
+	r.Methods("GET").Subrouter().HandleFunc("/getLiveness", ws.handle(getLiveness))
+	r.Methods("GET").Subrouter().HandleFunc("/getAddressBook", ws.handle(getAddressBook))
+	r.Methods("POST").Subrouter().HandleFunc("/probe", ws.handle(probe))
+	r.Methods("GET").Subrouter().HandleFunc("/getProbes", ws.handle(getProbes))
 
 	r.Handle("/", http.FileServer(http.Dir("./webserver/static"))) // relative path for main.go
 
-	log.Println(http.ListenAndServe(":"+strconv.Itoa(webserverPort), r))
+	l.Println(http.ListenAndServe(":"+strconv.Itoa(port), r))
 }